@@ -1,42 +1,79 @@
 package api
 
 import (
-	"ChessApp/service/user"
+	"ChessApp/config"
+	"ChessApp/service/analysis"
 	"ChessApp/service/app"
+	"ChessApp/service/auth"
+	"ChessApp/service/challenge"
+	"ChessApp/service/lobby"
+	"ChessApp/service/match"
+	"ChessApp/service/rating"
+	"ChessApp/service/user"
 	"database/sql"
 	"log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
-	
-
 
 type APIServer struct {
 	addr string
-	db *sql.DB 
+	db   *sql.DB
 }
 
 func NewAPIServer(addr string, db *sql.DB) *APIServer {
-	return &APIServer {
+	return &APIServer{
 		addr: addr,
-		db: db,
+		db:   db,
 	}
 }
 
-func (s* APIServer) Run() error {
+func (s *APIServer) Run() error {
 	router := mux.NewRouter()
 	subrouter := router.PathPrefix("/api/v1").Subrouter()
 
 	userApp := user.NewApp(s.db)
+	subrouter.Use(auth.Middleware(userApp))
+
 	userHandler := user.NewHandler(userApp)
 	userHandler.RegisterRoutes(subrouter)
 
-	chessApp := app.NewApp()
-	chessHandler := app.NewHandler(chessApp, userApp)
+	ratingApp := rating.NewApp(s.db, userApp)
+	ratingHandler := rating.NewHandler(ratingApp)
+	ratingHandler.RegisterRoutes(subrouter)
+
+	hub := lobby.NewHub()
+
+	chessApp := app.NewApp(s.db, ratingApp)
+	if err := app.LoadGames(chessApp, ratingApp, hub); err != nil {
+		return err
+	}
+
+	lobbyHandler := lobby.NewHandler(hub)
+	lobbyHandler.RegisterRoutes(subrouter)
+
+	chessHandler := app.NewHandler(chessApp, userApp, ratingApp, hub)
 	chessHandler.RegisterRoutes(subrouter)
 
+	matchmaker := match.NewMatchmaker(chessApp, ratingApp, hub)
+	go matchmaker.Run(time.Second, nil)
+
+	matchHandler := match.NewHandler(matchmaker)
+	matchHandler.RegisterRoutes(subrouter)
+
+	challengeApp := challenge.NewApp(chessApp, userApp, hub, time.Duration(config.Envs.ChallengeExpirationInSeconds)*time.Second)
+	challengeHandler := challenge.NewHandler(challengeApp)
+	challengeHandler.RegisterRoutes(subrouter)
+
+	analysisApp := analysis.NewApp(analysis.NewStore(s.db), chessApp)
+	go analysisApp.Run(nil)
+
+	analysisHandler := analysis.NewHandler(analysisApp)
+	analysisHandler.RegisterRoutes(subrouter)
+
 	log.Println("Listening on", s.addr)
 
 	return http.ListenAndServe(s.addr, router)
-} 
\ No newline at end of file
+}
@@ -6,16 +6,28 @@ import (
 )
 
 type Config struct {
-	JWTExpirationInSeconds int64
-	JWTSecret              string
+	JWTExpirationInSeconds          int64
+	RefreshTokenExpirationInSeconds int64
+	JWTSecret                       string
+	JWTIssuer                       string
+	JWTAudience                     string
+	DisconnectGraceInSeconds        int64
+	ChallengeExpirationInSeconds    int64
+	EnginePath                      string
 }
 
 var Envs = initConfig()
 
 func initConfig() Config {
 	return Config{
-		JWTExpirationInSeconds: getEnvAsInt("JWT_EXP_SECONDS", 3600*24*7),
-		JWTSecret:              getEnv("JWT_SECRET", "SECRET"),
+		JWTExpirationInSeconds:          getEnvAsInt("JWT_EXP_SECONDS", 60*15),
+		RefreshTokenExpirationInSeconds: getEnvAsInt("REFRESH_TOKEN_EXP_SECONDS", 3600*24*30),
+		JWTSecret:                       getEnv("JWT_SECRET", "SECRET"),
+		JWTIssuer:                       getEnv("JWT_ISSUER", "ChessApp"),
+		JWTAudience:                     getEnv("JWT_AUDIENCE", "ChessApp"),
+		DisconnectGraceInSeconds:        getEnvAsInt("DISCONNECT_GRACE_SECONDS", 60),
+		ChallengeExpirationInSeconds:    getEnvAsInt("CHALLENGE_EXP_SECONDS", 60*10),
+		EnginePath:                      getEnv("ENGINE_PATH", "stockfish"),
 	}
 }
 
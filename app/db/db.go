@@ -2,19 +2,32 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
 	"log"
 
 	_ "github.com/ncruces/go-sqlite3/driver"
 	_ "github.com/ncruces/go-sqlite3/embed"
 )
 
-func init() {
+// gameColumns were added to the games table after it was first created.
+// Migrate adds the ones an older database lacks, in this order, so that
+// SELECT * still lines up with the scans.
+var gameColumns = []struct {
+	name       string
+	definition string
+}{
+	{"rated", "INTEGER NOT NULL DEFAULT 0"},
+	{"owner", "TEXT NOT NULL DEFAULT ''"},
+	{"spectator_delay", "INTEGER NOT NULL DEFAULT 0"},
+	{"variant", "TEXT NOT NULL DEFAULT 'standard'"},
+	{"initial_fen", "TEXT NOT NULL DEFAULT ''"},
+	{"bot_level", "INTEGER NOT NULL DEFAULT 0"},
+	{"version", "INTEGER NOT NULL DEFAULT 0"},
+}
 
-	db, err := sql.Open("sqlite3", "file:demo.db")
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer db.Close()
+// Migrate creates the tables that do not exist yet and brings older ones up
+// to date.
+func Migrate(db *sql.DB) error {
 
 	userTable := `
 		CREATE TABLE IF NOT EXISTS users (
@@ -25,11 +38,128 @@ func init() {
 		);
 	`
 
-	_, err = db.Exec(userTable)
+	gameTable := `
+		CREATE TABLE IF NOT EXISTS games (
+			id TEXT PRIMARY KEY NOT NULL,
+			player_white TEXT NOT NULL DEFAULT '',
+			player_black TEXT NOT NULL DEFAULT '',
+			color TEXT NOT NULL,
+			initial_time INTEGER NOT NULL,
+			time_control INTEGER NOT NULL,
+			status TEXT NOT NULL,
+			outcome TEXT NOT NULL DEFAULT '',
+			method TEXT NOT NULL DEFAULT '',
+			white_time INTEGER NOT NULL DEFAULT 0,
+			black_time INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		);
+	`
 
-	if err != nil {
-		log.Fatal(err)
+	moveTable := `
+		CREATE TABLE IF NOT EXISTS moves (
+			game_id TEXT NOT NULL REFERENCES games(id),
+			ply INTEGER NOT NULL,
+			san TEXT NOT NULL,
+			uci TEXT NOT NULL,
+			fen TEXT NOT NULL,
+			white_time INTEGER NOT NULL,
+			black_time INTEGER NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (game_id, ply)
+		);
+	`
+
+	refreshTokenTable := `
+		CREATE TABLE IF NOT EXISTS refresh_tokens (
+			id TEXT PRIMARY KEY NOT NULL,
+			user_id TEXT NOT NULL REFERENCES users(id),
+			family_id TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			expires_at DATETIME NOT NULL,
+			used INTEGER NOT NULL DEFAULT 0,
+			revoked INTEGER NOT NULL DEFAULT 0,
+			created_at DATETIME NOT NULL
+		);
+	`
+
+	ratingTable := `
+		CREATE TABLE IF NOT EXISTS ratings (
+			user_id TEXT NOT NULL REFERENCES users(id),
+			time_class TEXT NOT NULL,
+			rating REAL NOT NULL,
+			deviation REAL NOT NULL,
+			volatility REAL NOT NULL,
+			games INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY (user_id, time_class)
+		);
+	`
+
+	ratingHistoryTable := `
+		CREATE TABLE IF NOT EXISTS rating_history (
+			game_id TEXT NOT NULL REFERENCES games(id),
+			user_id TEXT NOT NULL REFERENCES users(id),
+			time_class TEXT NOT NULL,
+			rating_before REAL NOT NULL,
+			rating_after REAL NOT NULL,
+			deviation_after REAL NOT NULL,
+			created_at DATETIME NOT NULL
+		);
+	`
+
+	analysisTable := `
+		CREATE TABLE IF NOT EXISTS analyses (
+			game_id TEXT PRIMARY KEY NOT NULL REFERENCES games(id),
+			status TEXT NOT NULL,
+			white_accuracy REAL NOT NULL DEFAULT 0,
+			black_accuracy REAL NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL
+		);
+	`
+
+	analysisMoveTable := `
+		CREATE TABLE IF NOT EXISTS analysis_moves (
+			game_id TEXT NOT NULL REFERENCES analyses(game_id),
+			ply INTEGER NOT NULL,
+			san TEXT NOT NULL,
+			eval INTEGER NOT NULL,
+			mate INTEGER NOT NULL,
+			best_move TEXT NOT NULL,
+			pv TEXT NOT NULL,
+			classification TEXT NOT NULL,
+			loss INTEGER NOT NULL,
+			accuracy REAL NOT NULL,
+			PRIMARY KEY (game_id, ply)
+		);
+	`
+
+	tables := []string{userTable, gameTable, moveTable, refreshTokenTable, ratingTable, ratingHistoryTable, analysisTable, analysisMoveTable}
+
+	for _, table := range tables {
+		if _, err := db.Exec(table); err != nil {
+			return err
+		}
+	}
+
+	for _, column := range gameColumns {
+		var count int
+		err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('games') WHERE name = ?", column.name).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE games ADD COLUMN %s %s", column.name, column.definition)); err != nil {
+			return fmt.Errorf("failed to add column %s to games: %v", column.name, err)
+		}
 	}
+
+	return nil
 }
 
 func NewSQLiteStorage() (*sql.DB, error) {
@@ -39,5 +169,9 @@ func NewSQLiteStorage() (*sql.DB, error) {
 		return nil, err
 	}
 
+	if err := Migrate(db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
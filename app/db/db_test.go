@@ -0,0 +1,95 @@
+package db
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	databases := []struct {
+		Name   string
+		Schema string
+	}{
+		{Name: "New Database"},
+		{
+			Name: "Old Games Table",
+			Schema: `
+				CREATE TABLE games (
+					id TEXT PRIMARY KEY NOT NULL,
+					player_white TEXT NOT NULL DEFAULT '',
+					player_black TEXT NOT NULL DEFAULT '',
+					color TEXT NOT NULL,
+					initial_time INTEGER NOT NULL,
+					time_control INTEGER NOT NULL,
+					status TEXT NOT NULL,
+					outcome TEXT NOT NULL DEFAULT '',
+					method TEXT NOT NULL DEFAULT '',
+					white_time INTEGER NOT NULL DEFAULT 0,
+					black_time INTEGER NOT NULL DEFAULT 0,
+					created_at DATETIME NOT NULL,
+					updated_at DATETIME NOT NULL
+				);
+				INSERT INTO games (id, color, initial_time, time_control, status, created_at, updated_at)
+				VALUES ('old', 'white', 5, 0, 'playing', '2024-01-01 00:00:00', '2024-01-01 00:00:00');
+			`,
+		},
+	}
+
+	for _, tc := range databases {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			db, err := sql.Open("sqlite3", ":memory:")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+
+			if tc.Schema != "" {
+				if _, err := db.Exec(tc.Schema); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Running it again must find nothing left to do
+			for i := 0; i < 2; i++ {
+				if err := Migrate(db); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			rows, err := db.Query("SELECT * FROM games")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+
+			columns, err := rows.Columns()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(columns) != 13+len(gameColumns) {
+				t.Fatalf("expected %d columns, got %v", 13+len(gameColumns), columns)
+			}
+			for i, column := range gameColumns {
+				if columns[13+i] != column.name {
+					t.Errorf("expected column %d to be %s, got %s", 13+i, column.name, columns[13+i])
+				}
+			}
+			rows.Close()
+
+			if tc.Schema == "" {
+				return
+			}
+
+			var variant string
+			var botLevel int
+			if err := db.QueryRow("SELECT variant, bot_level FROM games WHERE id = 'old'").Scan(&variant, &botLevel); err != nil {
+				t.Fatal(err)
+			}
+			if variant != "standard" || botLevel != 0 {
+				t.Errorf("expected an old game to be a standard game without a bot, got %s at level %d", variant, botLevel)
+			}
+		})
+	}
+}
@@ -0,0 +1,257 @@
+// Package engine is a small chess engine written in Go on top of the
+// notnil/chess positions: alpha-beta search with iterative deepening, a
+// transposition table and quiescence search over a material and
+// piece-square evaluation. Bots fall back to it when no UCI engine can be
+// started.
+package engine
+
+import (
+	"ChessApp/uci"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// maxDepth bounds iterative deepening when the search has no depth limit.
+const maxDepth = 64
+
+// Engine searches positions for the best move. It keeps its transposition
+// table between searches, so one Engine should play one game at a time.
+type Engine struct {
+	table   *table
+	killers [maxPly][2]*chess.Move
+	history [64][64]int
+
+	// Keys of the positions played before the search and on its current
+	// line, to spot repetitions
+	path []uint64
+
+	nodes    int
+	maxNodes int
+	deadline time.Time
+	stopped  bool
+	closed   atomic.Bool
+}
+
+// Result is what a search found. Score is in centipawns from the point of
+// view of the side to move.
+type Result struct {
+	Move  *chess.Move
+	Score int
+	Depth int
+	Nodes int
+}
+
+func New() *Engine {
+	return &Engine{table: newTable(1 << 18)}
+}
+
+// Search looks for the best move in pos, which was reached through history,
+// oldest first. It searches one ply deeper at a time until limits or the
+// clock run out, and returns the result of the deepest completed search.
+func (e *Engine) Search(pos *chess.Position, history []*chess.Position, limits uci.Limits) Result {
+	e.path = e.path[:0]
+	for _, previous := range history {
+		e.path = append(e.path, hash(previous))
+	}
+	e.nodes = 0
+	e.maxNodes = limits.Nodes
+	e.stopped = false
+	e.killers = [maxPly][2]*chess.Move{}
+	e.history = [64][64]int{}
+
+	start := time.Now()
+	budget := timeBudget(pos.Turn(), limits)
+	e.deadline = time.Time{}
+	if budget > 0 {
+		e.deadline = start.Add(budget)
+	}
+
+	depthLimit := maxDepth
+	if limits.Depth > 0 {
+		depthLimit = min(limits.Depth, maxDepth)
+	}
+
+	moves := pos.ValidMoves()
+	result := Result{}
+	if len(moves) == 0 {
+		return result
+	}
+	result.Move = moves[0]
+
+	for depth := 1; depth <= depthLimit; depth++ {
+		score := e.negamax(pos, depth, 0, -infinity, infinity, false)
+		if e.stopped {
+			break
+		}
+
+		if entry, ok := e.table.get(hash(pos)); ok && entry.move != nil {
+			result.Move = entry.move
+		}
+		result.Score, result.Depth = score, depth
+
+		// A found mate will not get any better, and another iteration
+		// would most likely not finish in the time left
+		if abs(score) >= mateScore-maxPly {
+			break
+		}
+		if budget > 0 && time.Since(start) > budget/2 {
+			break
+		}
+	}
+
+	result.Nodes = e.nodes
+	return result
+}
+
+// timeBudget is how long to think about a move. A fixed move time is used
+// as is; otherwise a slice of the remaining clock plus half the increment.
+// Zero means only the depth or node limit ends the search.
+func timeBudget(turn chess.Color, limits uci.Limits) time.Duration {
+	if limits.MoveTime > 0 {
+		return limits.MoveTime
+	}
+
+	remaining, increment := limits.WhiteTime, limits.WhiteInc
+	if turn == chess.Black {
+		remaining, increment = limits.BlackTime, limits.BlackInc
+	}
+	if remaining <= 0 {
+		return 0
+	}
+
+	budget := remaining/30 + increment/2
+	return max(time.Millisecond, min(budget, remaining/2))
+}
+
+// BestMove plays the same part as uci.Engine.BestMove, so a bot can use
+// either: it searches the position reached from fen by moves and returns
+// the move in UCI notation.
+func (e *Engine) BestMove(fen string, moves []string, limits uci.Limits, timeout time.Duration) (string, error) {
+	analysis, err := e.Analyse(fen, moves, limits, timeout)
+	if err != nil {
+		return "", err
+	}
+	return analysis.BestMove, nil
+}
+
+// Analyse is the counterpart of uci.Engine.Analyse. The principal variation
+// is read back from the transposition table.
+func (e *Engine) Analyse(fen string, moves []string, limits uci.Limits, timeout time.Duration) (uci.Analysis, error) {
+	if e.closed.Load() {
+		return uci.Analysis{}, fmt.Errorf("engine is closed")
+	}
+
+	if fen == "" {
+		fen = chess.StartingPosition().String()
+	}
+	// Chess960 and variant FENs are not supported, only the standard fields
+	fields := strings.Fields(fen)
+	if len(fields) > 6 {
+		fen = strings.Join(fields[:6], " ")
+	}
+
+	start, err := chess.FEN(fen)
+	if err != nil {
+		return uci.Analysis{}, err
+	}
+
+	// Moves are looked up among the legal ones, since a decoded move lacks
+	// the check tag the next position needs to know it is in check
+	game := chess.NewGame(start)
+	for _, s := range moves {
+		var played *chess.Move
+		for _, move := range game.Position().ValidMoves() {
+			if (chess.UCINotation{}).Encode(game.Position(), move) == s {
+				played = move
+				break
+			}
+		}
+		if played == nil {
+			return uci.Analysis{}, fmt.Errorf("illegal move %s", s)
+		}
+		if err := game.Move(played); err != nil {
+			return uci.Analysis{}, err
+		}
+	}
+
+	// Never think past the caller's timeout
+	if budget := timeBudget(game.Position().Turn(), limits); budget == 0 || budget > timeout {
+		limits.MoveTime = timeout
+	}
+
+	pos := game.Position()
+	positions := game.Positions()
+	result := e.Search(pos, positions[:len(positions)-1], limits)
+	if result.Move == nil {
+		return uci.Analysis{}, fmt.Errorf("engine found no move")
+	}
+
+	analysis := uci.Analysis{
+		BestMove: chess.UCINotation{}.Encode(pos, result.Move),
+		Score:    result.Score,
+		Depth:    result.Depth,
+		PV:       e.principalVariation(pos, result.Move, max(result.Depth, 1)),
+	}
+
+	// Mates are counted in moves, as UCI engines report them
+	switch {
+	case result.Score >= mateScore-maxPly:
+		analysis.Score, analysis.Mate = 0, (mateScore-result.Score+1)/2
+	case result.Score <= -mateScore+maxPly:
+		analysis.Score, analysis.Mate = 0, -(mateScore+result.Score+1)/2
+	}
+
+	return analysis, nil
+}
+
+// principalVariation follows the best moves stored in the transposition
+// table from pos, starting with best, for at most length moves. It stops
+// at a move that is not legal, which a key collision could leave behind,
+// or when the line repeats.
+func (e *Engine) principalVariation(pos *chess.Position, best *chess.Move, length int) []string {
+	line := []string{}
+	seen := map[uint64]bool{}
+
+	move := best
+	for len(line) < length && move != nil && !seen[hash(pos)] {
+		seen[hash(pos)] = true
+
+		var legal *chess.Move
+		for _, candidate := range pos.ValidMoves() {
+			if sameMove(candidate, move) {
+				legal = candidate
+				break
+			}
+		}
+		if legal == nil {
+			break
+		}
+
+		line = append(line, chess.UCINotation{}.Encode(pos, legal))
+		pos = pos.Update(legal)
+
+		move = nil
+		if entry, ok := e.table.get(hash(pos)); ok {
+			move = entry.move
+		}
+	}
+
+	return line
+}
+
+// Close stops a search still running. The engine cannot be used afterwards.
+func (e *Engine) Close() error {
+	e.closed.Store(true)
+	return nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"ChessApp/uci"
+	"bufio"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func TestPerft(t *testing.T) {
+	positions := []struct {
+		Name     string
+		FEN      string
+		Depth    int
+		Expected int
+	}{
+		{
+			Name:     "Start Position",
+			FEN:      "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1",
+			Depth:    3,
+			Expected: 8902,
+		},
+		{
+			Name:     "Kiwipete",
+			FEN:      "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+			Depth:    2,
+			Expected: 2039,
+		},
+		{
+			Name:     "En Passant Pins",
+			FEN:      "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1",
+			Depth:    3,
+			Expected: 2812,
+		},
+	}
+
+	for _, tc := range positions {
+		t.Run(tc.Name, func(t *testing.T) {
+			pos := positionFromFEN(t, tc.FEN)
+			if nodes := Perft(pos, tc.Depth); nodes != tc.Expected {
+				t.Errorf("expected %d nodes, got %d", tc.Expected, nodes)
+			}
+		})
+	}
+}
+
+// TestTactics runs the positions of testdata/tactics.epd and expects one of
+// the best moves of each.
+func TestTactics(t *testing.T) {
+	file, err := os.Open("testdata/tactics.epd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			t.Fatalf("malformed EPD line %q", line)
+		}
+		pos := positionFromFEN(t, strings.Join(fields[:4], " ")+" 0 1")
+
+		var best []string
+		name := line
+		for _, op := range strings.Split(strings.Join(fields[4:], " "), ";") {
+			op = strings.TrimSpace(op)
+			switch {
+			case strings.HasPrefix(op, "bm "):
+				best = strings.Fields(op[3:])
+			case strings.HasPrefix(op, "id "):
+				name = strings.Trim(op[3:], `"`)
+			}
+		}
+
+		t.Run(name, func(t *testing.T) {
+			result := New().Search(pos, nil, uci.Limits{Depth: 4})
+			if result.Move == nil {
+				t.Fatal("expected a move")
+			}
+
+			played := chess.AlgebraicNotation{}.Encode(pos, result.Move)
+			if !slices.Contains(best, played) {
+				t.Errorf("expected one of %v, got %s (score %d, depth %d)", best, played, result.Score, result.Depth)
+			}
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBestMove(t *testing.T) {
+	engine := New()
+	defer engine.Close()
+
+	positions := []struct {
+		Name     string
+		FEN      string
+		Moves    []string
+		Expected string
+	}{
+		{
+			Name:  "Start Position",
+			Moves: []string{"e2e4", "e7e5"},
+		},
+		{
+			Name:     "Only Move",
+			FEN:      "k7/8/1K6/8/8/8/7P/8 b - - 0 1",
+			Expected: "a8b8",
+		},
+		{
+			Name:     "Mate In One",
+			FEN:      "6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1",
+			Expected: "a1a8",
+		},
+	}
+
+	for _, tc := range positions {
+		t.Run(tc.Name, func(t *testing.T) {
+			move, err := engine.BestMove(tc.FEN, tc.Moves, uci.Limits{MoveTime: 200 * time.Millisecond}, time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(move) < 4 {
+				t.Errorf("expected a move in UCI notation, got %q", move)
+			}
+			if tc.Expected != "" && move != tc.Expected {
+				t.Errorf("expected %s, got %s", tc.Expected, move)
+			}
+		})
+	}
+
+	if _, err := engine.BestMove("", []string{"e2e5"}, uci.Limits{Depth: 1}, time.Second); err == nil {
+		t.Error("expected an illegal move to be refused")
+	}
+
+	engine.Close()
+	if _, err := engine.BestMove("", nil, uci.Limits{Depth: 1}, time.Second); err == nil {
+		t.Error("expected a closed engine to be refused")
+	}
+}
+
+func TestAnalyse(t *testing.T) {
+	positions := []struct {
+		Name     string
+		FEN      string
+		Mate     int
+		Expected string
+	}{
+		{
+			Name:     "Mate In One",
+			FEN:      "6k1/5ppp/8/8/8/8/5PPP/R5K1 w - - 0 1",
+			Mate:     1,
+			Expected: "a1a8",
+		},
+		{
+			Name:     "Mate In Two",
+			FEN:      "7k/8/8/8/8/8/1R6/R5K1 w - - 0 1",
+			Mate:     2,
+			Expected: "",
+		},
+		{
+			Name: "Getting Mated",
+			FEN:  "k7/8/1K6/8/8/8/8/7R b - - 0 1",
+			Mate: -1,
+		},
+	}
+
+	for _, tc := range positions {
+		t.Run(tc.Name, func(t *testing.T) {
+			analysis, err := New().Analyse(tc.FEN, nil, uci.Limits{Depth: 4}, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if analysis.Mate != tc.Mate {
+				t.Errorf("expected mate in %d, got %+v", tc.Mate, analysis)
+			}
+			if tc.Expected != "" && analysis.BestMove != tc.Expected {
+				t.Errorf("expected %s, got %s", tc.Expected, analysis.BestMove)
+			}
+			if len(analysis.PV) == 0 || analysis.PV[0] != analysis.BestMove {
+				t.Errorf("expected the line to start with %s, got %v", analysis.BestMove, analysis.PV)
+			}
+		})
+	}
+}
+
+func TestTimeBudget(t *testing.T) {
+	start := time.Now()
+	New().Search(chess.StartingPosition(), nil, uci.Limits{WhiteTime: 3 * time.Second})
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected a short think with 3s left, took %v", elapsed)
+	}
+}
+
+func BenchmarkPerft(b *testing.B) {
+	pos := chess.StartingPosition()
+	for i := 0; i < b.N; i++ {
+		Perft(pos, 3)
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	pos := chess.StartingPosition()
+	for i := 0; i < b.N; i++ {
+		New().Search(pos, nil, uci.Limits{Depth: 4})
+	}
+}
+
+func positionFromFEN(t *testing.T, fen string) *chess.Position {
+	t.Helper()
+
+	option, err := chess.FEN(fen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return chess.NewGame(option).Position()
+}
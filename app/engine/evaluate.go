@@ -0,0 +1,149 @@
+package engine
+
+import (
+	"github.com/notnil/chess"
+)
+
+var pieceValues = [7]int{
+	chess.King:   0,
+	chess.Queen:  900,
+	chess.Rook:   500,
+	chess.Bishop: 330,
+	chess.Knight: 320,
+	chess.Pawn:   100,
+}
+
+// Piece-square tables from white's side, a8 first, so they read like the
+// board. The values are Tomasz Michniewski's simplified evaluation.
+var pieceSquares = [7][64]int{
+	chess.Pawn: {
+		0, 0, 0, 0, 0, 0, 0, 0,
+		50, 50, 50, 50, 50, 50, 50, 50,
+		10, 10, 20, 30, 30, 20, 10, 10,
+		5, 5, 10, 25, 25, 10, 5, 5,
+		0, 0, 0, 20, 20, 0, 0, 0,
+		5, -5, -10, 0, 0, -10, -5, 5,
+		5, 10, 10, -20, -20, 10, 10, 5,
+		0, 0, 0, 0, 0, 0, 0, 0,
+	},
+	chess.Knight: {
+		-50, -40, -30, -30, -30, -30, -40, -50,
+		-40, -20, 0, 0, 0, 0, -20, -40,
+		-30, 0, 10, 15, 15, 10, 0, -30,
+		-30, 5, 15, 20, 20, 15, 5, -30,
+		-30, 0, 15, 20, 20, 15, 0, -30,
+		-30, 5, 10, 15, 15, 10, 5, -30,
+		-40, -20, 0, 5, 5, 0, -20, -40,
+		-50, -40, -30, -30, -30, -30, -40, -50,
+	},
+	chess.Bishop: {
+		-20, -10, -10, -10, -10, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 10, 10, 5, 0, -10,
+		-10, 5, 5, 10, 10, 5, 5, -10,
+		-10, 0, 10, 10, 10, 10, 0, -10,
+		-10, 10, 10, 10, 10, 10, 10, -10,
+		-10, 5, 0, 0, 0, 0, 5, -10,
+		-20, -10, -10, -10, -10, -10, -10, -20,
+	},
+	chess.Rook: {
+		0, 0, 0, 0, 0, 0, 0, 0,
+		5, 10, 10, 10, 10, 10, 10, 5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		-5, 0, 0, 0, 0, 0, 0, -5,
+		0, 0, 0, 5, 5, 0, 0, 0,
+	},
+	chess.Queen: {
+		-20, -10, -10, -5, -5, -10, -10, -20,
+		-10, 0, 0, 0, 0, 0, 0, -10,
+		-10, 0, 5, 5, 5, 5, 0, -10,
+		-5, 0, 5, 5, 5, 5, 0, -5,
+		0, 0, 5, 5, 5, 5, 0, -5,
+		-10, 5, 5, 5, 5, 5, 0, -10,
+		-10, 0, 5, 0, 0, 0, 0, -10,
+		-20, -10, -10, -5, -5, -10, -10, -20,
+	},
+	chess.King: {
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-30, -40, -40, -50, -50, -40, -40, -30,
+		-20, -30, -30, -40, -40, -30, -30, -20,
+		-10, -20, -20, -20, -20, -20, -20, -10,
+		20, 20, 0, 0, 0, 0, 20, 20,
+		20, 30, 10, 0, 0, 10, 30, 20,
+	},
+}
+
+// kingEndgame replaces the king's table once the queens are off or little
+// else is left, when the king belongs in the centre.
+var kingEndgame = [64]int{
+	-50, -40, -30, -20, -20, -30, -40, -50,
+	-30, -20, -10, 0, 0, -10, -20, -30,
+	-30, -10, 20, 30, 30, 20, -10, -30,
+	-30, -10, 30, 40, 40, 30, -10, -30,
+	-30, -10, 30, 40, 40, 30, -10, -30,
+	-30, -10, 20, 30, 30, 20, -10, -30,
+	-30, -30, 0, 0, 0, 0, -30, -30,
+	-50, -30, -30, -30, -30, -30, -30, -50,
+}
+
+// evaluate scores pos in centipawns for the side to move.
+func evaluate(pos *chess.Position) int {
+	board := pos.Board()
+
+	score := 0
+	material := 0
+	queens := 0
+	kings := [3]chess.Square{}
+
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		piece := board.Piece(sq)
+		if piece == chess.NoPiece {
+			continue
+		}
+
+		t := piece.Type()
+		switch t {
+		case chess.King:
+			kings[piece.Color()] = sq
+			continue
+		case chess.Queen:
+			queens++
+		}
+		if t != chess.Pawn {
+			material += pieceValues[t]
+		}
+
+		value := pieceValues[t] + pieceSquares[t][tableIndex(sq, piece.Color())]
+		if piece.Color() == chess.White {
+			score += value
+		} else {
+			score -= value
+		}
+	}
+
+	kingTable := &pieceSquares[chess.King]
+	if queens == 0 || material <= 2*(pieceValues[chess.Rook]+pieceValues[chess.Bishop]) {
+		kingTable = &kingEndgame
+	}
+	score += kingTable[tableIndex(kings[chess.White], chess.White)]
+	score -= kingTable[tableIndex(kings[chess.Black], chess.Black)]
+
+	if pos.Turn() == chess.Black {
+		return -score
+	}
+	return score
+}
+
+// tableIndex maps sq to its place in a piece-square table, mirrored for
+// black.
+func tableIndex(sq chess.Square, color chess.Color) int {
+	if color == chess.White {
+		return (7-int(sq.Rank()))*8 + int(sq.File())
+	}
+	return int(sq.Rank())*8 + int(sq.File())
+}
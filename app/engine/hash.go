@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"math/rand"
+
+	"github.com/notnil/chess"
+)
+
+// Zobrist keys, drawn once from a fixed seed so hashes are the same on
+// every run.
+var (
+	pieceKeys     [13][64]uint64
+	blackKey      uint64
+	castleKeys    [4]uint64
+	enPassantKeys [8]uint64
+)
+
+func init() {
+	r := rand.New(rand.NewSource(2024))
+	for piece := range pieceKeys {
+		for sq := range pieceKeys[piece] {
+			pieceKeys[piece][sq] = r.Uint64()
+		}
+	}
+	blackKey = r.Uint64()
+	for i := range castleKeys {
+		castleKeys[i] = r.Uint64()
+	}
+	for i := range enPassantKeys {
+		enPassantKeys[i] = r.Uint64()
+	}
+}
+
+// hash is the Zobrist key of pos: pieces, side to move, castling rights and
+// the en passant file.
+func hash(pos *chess.Position) uint64 {
+	board := pos.Board()
+
+	var key uint64
+	for sq := chess.A1; sq <= chess.H8; sq++ {
+		if piece := board.Piece(sq); piece != chess.NoPiece {
+			key ^= pieceKeys[piece][sq]
+		}
+	}
+
+	if pos.Turn() == chess.Black {
+		key ^= blackKey
+	}
+
+	rights := pos.CastleRights()
+	for i, color := range []chess.Color{chess.White, chess.Black} {
+		if rights.CanCastle(color, chess.KingSide) {
+			key ^= castleKeys[2*i]
+		}
+		if rights.CanCastle(color, chess.QueenSide) {
+			key ^= castleKeys[2*i+1]
+		}
+	}
+
+	if sq := pos.EnPassantSquare(); sq != chess.NoSquare {
+		key ^= enPassantKeys[sq.File()]
+	}
+
+	return key
+}
+
+type bound uint8
+
+const (
+	boundExact bound = iota
+	boundLower
+	boundUpper
+)
+
+type entry struct {
+	key   uint64
+	depth int
+	score int
+	bound bound
+	move  *chess.Move
+}
+
+// table is the transposition table: a fixed number of slots indexed by the
+// low bits of the key, the newest entry replacing whatever was there.
+type table struct {
+	entries []entry
+	mask    uint64
+}
+
+// newTable makes a table of size slots, which must be a power of two.
+func newTable(size int) *table {
+	return &table{entries: make([]entry, size), mask: uint64(size - 1)}
+}
+
+func (t *table) get(key uint64) (entry, bool) {
+	e := t.entries[key&t.mask]
+	return e, e.key == key && e.move != nil
+}
+
+func (t *table) put(key uint64, depth, score int, b bound, move *chess.Move) {
+	t.entries[key&t.mask] = entry{key: key, depth: depth, score: score, bound: b, move: move}
+}
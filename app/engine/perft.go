@@ -0,0 +1,24 @@
+package engine
+
+import (
+	"github.com/notnil/chess"
+)
+
+// Perft counts the leaf nodes of the move tree of pos to depth. Compared
+// with known counts it checks the move generation the search relies on.
+func Perft(pos *chess.Position, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+
+	moves := pos.ValidMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+
+	nodes := 0
+	for _, move := range moves {
+		nodes += Perft(pos.Update(move), depth-1)
+	}
+	return nodes
+}
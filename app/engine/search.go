@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"sort"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	infinity  = 1000000
+	mateScore = 100000
+
+	// maxPly bounds the length of a line, extensions and quiescence
+	// included
+	maxPly = 128
+)
+
+// negamax searches pos to depth and returns its score for the side to move.
+// Scores outside alpha and beta are only bounds. inCheck tells whether the
+// side to move is in check, known from the move that led here.
+func (e *Engine) negamax(pos *chess.Position, depth, ply, alpha, beta int, inCheck bool) int {
+	if e.stop() {
+		return 0
+	}
+
+	key := hash(pos)
+	if ply > 0 && (e.repetition(key) || pos.HalfMoveClock() >= 100) {
+		return 0
+	}
+
+	if depth <= 0 || ply >= maxPly-1 {
+		return e.quiesce(pos, ply, alpha, beta, inCheck)
+	}
+	e.nodes++
+
+	entry, found := e.table.get(key)
+	if found && ply > 0 && entry.depth >= depth {
+		score := fromTable(entry.score, ply)
+		switch {
+		case entry.bound == boundExact:
+			return score
+		case entry.bound == boundLower && score >= beta:
+			return score
+		case entry.bound == boundUpper && score <= alpha:
+			return score
+		}
+	}
+
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return -mateScore + ply
+		}
+		return 0
+	}
+
+	var hint *chess.Move
+	if found {
+		hint = entry.move
+	}
+	moves = e.order(pos, moves, hint, ply)
+
+	e.path = append(e.path, key)
+	defer func() { e.path = e.path[:len(e.path)-1] }()
+
+	alphaIn := alpha
+	best, bestMove := -infinity, moves[0]
+
+	for _, move := range moves {
+		// Checks are searched one ply deeper so short mates are not missed
+		extension := 0
+		if move.HasTag(chess.Check) {
+			extension = 1
+		}
+
+		score := -e.negamax(pos.Update(move), depth-1+extension, ply+1, -beta, -alpha, move.HasTag(chess.Check))
+		if e.stopped {
+			return 0
+		}
+
+		if score > best {
+			best, bestMove = score, move
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta {
+			if !move.HasTag(chess.Capture) && move.Promo() == chess.NoPieceType {
+				e.rememberCutoff(move, depth, ply)
+			}
+			break
+		}
+	}
+
+	bound := boundExact
+	switch {
+	case best <= alphaIn:
+		bound = boundUpper
+	case best >= beta:
+		bound = boundLower
+	}
+	e.table.put(key, depth, toTable(best, ply), bound, bestMove)
+
+	return best
+}
+
+// quiesce follows captures and promotions until the position is quiet, so
+// the evaluation never stops in the middle of an exchange. A side in check
+// cannot stand pat and has every evasion searched instead.
+func (e *Engine) quiesce(pos *chess.Position, ply, alpha, beta int, inCheck bool) int {
+	if e.stop() {
+		return 0
+	}
+	e.nodes++
+
+	moves := pos.ValidMoves()
+	if len(moves) == 0 {
+		if pos.Status() == chess.Checkmate {
+			return -mateScore + ply
+		}
+		return 0
+	}
+
+	if ply >= maxPly-1 {
+		return evaluate(pos)
+	}
+
+	tactical := moves
+	if !inCheck {
+		standPat := evaluate(pos)
+		if standPat >= beta {
+			return standPat
+		}
+		if standPat > alpha {
+			alpha = standPat
+		}
+
+		tactical = []*chess.Move{}
+		for _, move := range moves {
+			if move.HasTag(chess.Capture) || move.Promo() != chess.NoPieceType {
+				tactical = append(tactical, move)
+			}
+		}
+	}
+
+	best := alpha
+	if inCheck {
+		best = -infinity
+	}
+
+	for _, move := range e.order(pos, tactical, nil, ply) {
+		score := -e.quiesce(pos.Update(move), ply+1, -beta, -alpha, move.HasTag(chess.Check))
+		if e.stopped {
+			return 0
+		}
+
+		if score >= beta {
+			return score
+		}
+		if score > best {
+			best = score
+		}
+		if score > alpha {
+			alpha = score
+		}
+	}
+
+	return best
+}
+
+// order sorts moves best first: the move from the transposition table,
+// captures by most valuable victim and least valuable attacker, promotions,
+// the killer moves of this ply, then quiet moves by their history.
+func (e *Engine) order(pos *chess.Position, moves []*chess.Move, hint *chess.Move, ply int) []*chess.Move {
+	board := pos.Board()
+	scores := make(map[*chess.Move]int, len(moves))
+
+	for _, move := range moves {
+		score := 0
+		switch {
+		case hint != nil && sameMove(move, hint):
+			score = 1 << 30
+		case move.HasTag(chess.Capture):
+			victim := board.Piece(move.S2()).Type()
+			if move.HasTag(chess.EnPassant) {
+				victim = chess.Pawn
+			}
+			score = 1<<28 + 16*pieceValues[victim] - pieceValues[board.Piece(move.S1()).Type()]/16
+		case move.Promo() != chess.NoPieceType:
+			score = 1<<27 + pieceValues[move.Promo()]
+		case sameMove(move, e.killers[ply][0]):
+			score = 1<<26 + 1
+		case sameMove(move, e.killers[ply][1]):
+			score = 1 << 26
+		default:
+			score = e.history[move.S1()][move.S2()]
+		}
+		scores[move] = score
+	}
+
+	sorted := append([]*chess.Move(nil), moves...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return scores[sorted[i]] > scores[sorted[j]]
+	})
+	return sorted
+}
+
+// rememberCutoff records a quiet move that refuted a line, to try it early
+// in sibling positions.
+func (e *Engine) rememberCutoff(move *chess.Move, depth, ply int) {
+	if !sameMove(move, e.killers[ply][0]) {
+		e.killers[ply][1] = e.killers[ply][0]
+		e.killers[ply][0] = move
+	}
+
+	e.history[move.S1()][move.S2()] += depth * depth
+	if e.history[move.S1()][move.S2()] > 1<<25 {
+		for from := range e.history {
+			for to := range e.history[from] {
+				e.history[from][to] /= 2
+			}
+		}
+	}
+}
+
+// repetition reports whether the position with key already occurred since
+// the last irreversible move. One repetition is scored as a draw, since
+// neither side can expect to do better by repeating again.
+func (e *Engine) repetition(key uint64) bool {
+	for _, previous := range e.path {
+		if previous == key {
+			return true
+		}
+	}
+	return false
+}
+
+// stop reports whether the search has to end now. The clock is only looked
+// at every few thousand nodes.
+func (e *Engine) stop() bool {
+	if e.stopped {
+		return true
+	}
+
+	if e.maxNodes > 0 && e.nodes >= e.maxNodes {
+		e.stopped = true
+	}
+	if e.nodes&2047 == 0 && (e.closed.Load() || (!e.deadline.IsZero() && time.Now().After(e.deadline))) {
+		e.stopped = true
+	}
+	return e.stopped
+}
+
+func sameMove(a, b *chess.Move) bool {
+	return a != nil && b != nil && a.S1() == b.S1() && a.S2() == b.S2() && a.Promo() == b.Promo()
+}
+
+// Mate scores are stored relative to the position, not the root, so they
+// stay right wherever in the tree the position is found again.
+func toTable(score, ply int) int {
+	switch {
+	case score >= mateScore-maxPly:
+		return score + ply
+	case score <= -mateScore+maxPly:
+		return score - ply
+	}
+	return score
+}
+
+func fromTable(score, ply int) int {
+	switch {
+	case score >= mateScore-maxPly:
+		return score - ply
+	case score <= -mateScore+maxPly:
+		return score + ply
+	}
+	return score
+}
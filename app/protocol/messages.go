@@ -0,0 +1,274 @@
+package protocol
+
+import (
+	"ChessApp/types"
+	"time"
+)
+
+// Hello opens the handshake. The server answers with Welcome, or with an
+// unsupported_version error.
+type Hello struct {
+	Version int `json:"version"`
+}
+
+type Move struct {
+	Move string `json:"move"`
+}
+
+type Resign struct{}
+
+type OfferDraw struct{}
+
+type AcceptDraw struct{}
+
+type DeclineDraw struct{}
+
+// TakebackRequest asks the opponent to undo the requester's last move,
+// together with the opponent's reply if it has been played.
+type TakebackRequest struct{}
+
+type TakebackAccept struct{}
+
+// Abort cancels the game without a result. It is only allowed until both
+// sides have moved.
+type Abort struct{}
+
+// Resync asks for the moves after Ply, the last ply the client knows.
+type Resync struct {
+	Ply int `json:"ply"`
+}
+
+// ClaimVictory and ClaimDraw end the game once the opponent has been
+// disconnected for longer than the grace period.
+type ClaimVictory struct{}
+
+type ClaimDraw struct{}
+
+type Welcome struct {
+	Version int    `json:"version"`
+	Role    string `json:"role"`
+}
+
+// Ack confirms the request with the same envelope ID was applied.
+type Ack struct{}
+
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type State struct {
+	Version       int      `json:"version"`
+	Role          string   `json:"role"`
+	Status        string   `json:"status"`
+	Variant       string   `json:"variant"`
+	FEN           string   `json:"fen"`
+	Moves         []string `json:"moves"`
+	Ply           int      `json:"ply"`
+	Turn          string   `json:"turn"`
+	WhiteTime     int64    `json:"white_time"`
+	BlackTime     int64    `json:"black_time"`
+	PlayerWhite   string   `json:"player_white"`
+	PlayerBlack   string   `json:"player_black"`
+	Spectators    int      `json:"spectators"`
+	PendingOffers []string `json:"pending_offers"`
+	Outcome       string   `json:"outcome,omitempty"`
+	Method        string   `json:"method,omitempty"`
+}
+
+type MoveMade struct {
+	Version int    `json:"version"`
+	Ply     int    `json:"ply"`
+	SAN     string `json:"san"`
+	UCI     string `json:"uci"`
+	FEN     string `json:"fen"`
+	Turn    string `json:"turn"`
+}
+
+// Clock carries the remaining time in milliseconds of both sides.
+type Clock struct {
+	WhiteTime int64  `json:"white_time"`
+	BlackTime int64  `json:"black_time"`
+	Turn      string `json:"turn"`
+	Running   bool   `json:"running"`
+}
+
+type GameOver struct {
+	Result string `json:"result"`
+	Method string `json:"method"`
+	FEN    string `json:"fen"`
+	PGN    string `json:"pgn"`
+}
+
+type MissedMoves struct {
+	Version   int          `json:"version"`
+	FromPly   int          `json:"from_ply"`
+	Moves     []types.Move `json:"moves"`
+	Status    string       `json:"status"`
+	FEN       string       `json:"fen"`
+	Turn      string       `json:"turn"`
+	WhiteTime int64        `json:"white_time"`
+	BlackTime int64        `json:"black_time"`
+	Outcome   string       `json:"outcome,omitempty"`
+	Method    string       `json:"method,omitempty"`
+}
+
+type Spectators struct {
+	Count int `json:"count"`
+}
+
+// Offer announces a draw or takeback offer by Color ("white" or "black")
+// and its declines. Accepted offers are followed by game_over or takeback.
+type Offer struct {
+	Kind   string `json:"kind"`
+	Color  string `json:"color"`
+	Status string `json:"status"`
+}
+
+// Takeback is the position after an accepted takeback, a clock follows.
+type Takeback struct {
+	Version int    `json:"version"`
+	Ply     int    `json:"ply"`
+	FEN     string `json:"fen"`
+	Turn    string `json:"turn"`
+}
+
+// OpponentDisconnected is sent when the last connection of the player
+// with Color closes. The other player may claim the game once SecondsLeft
+// have passed.
+type OpponentDisconnected struct {
+	Color       string    `json:"color"`
+	SecondsLeft int       `json:"seconds_left"`
+	ClaimAt     time.Time `json:"claim_at"`
+}
+
+type OpponentReconnected struct {
+	Color string `json:"color"`
+}
+
+// ChallengeCreated, ChallengeJoined and ChallengeExpired keep the lobby's
+// list of open games up to date.
+type ChallengeCreated struct {
+	Game types.OpenGame `json:"game"`
+}
+
+type ChallengeJoined struct {
+	GameID   string `json:"game_id"`
+	Opponent string `json:"opponent"`
+}
+
+// ChallengeExpired is sent when an open game is withdrawn without being
+// joined. Reason is "timeout" or "aborted".
+type ChallengeExpired struct {
+	GameID string `json:"game_id"`
+	Reason string `json:"reason"`
+}
+
+// ChallengeReceived tells a user they were challenged directly.
+type ChallengeReceived struct {
+	Challenge types.Challenge `json:"challenge"`
+}
+
+// ChallengeAccepted is sent to both users with the game they were seated
+// in.
+type ChallengeAccepted struct {
+	ChallengeID string `json:"challenge_id"`
+	GameID      string `json:"game_id"`
+	Color       string `json:"color"`
+	Opponent    string `json:"opponent"`
+}
+
+type ChallengeDeclined struct {
+	ChallengeID string `json:"challenge_id"`
+	Reason      string `json:"reason"`
+}
+
+// ChallengeCancelled is sent to both users when a direct challenge is
+// withdrawn by the challenger or expires. Reason is "cancelled" or
+// "expired".
+type ChallengeCancelled struct {
+	ChallengeID string `json:"challenge_id"`
+	Reason      string `json:"reason"`
+}
+
+type MatchFound struct {
+	GameID   string `json:"game_id"`
+	Color    string `json:"color"`
+	Opponent string `json:"opponent"`
+}
+
+func (*Hello) MessageType() string           { return TypeHello }
+func (*Move) MessageType() string            { return TypeMove }
+func (*Resign) MessageType() string          { return TypeResign }
+func (*OfferDraw) MessageType() string       { return TypeOfferDraw }
+func (*AcceptDraw) MessageType() string      { return TypeAcceptDraw }
+func (*DeclineDraw) MessageType() string     { return TypeDeclineDraw }
+func (*TakebackRequest) MessageType() string { return TypeTakebackRequest }
+func (*TakebackAccept) MessageType() string  { return TypeTakebackAccept }
+func (*Abort) MessageType() string           { return TypeAbort }
+func (*ClaimVictory) MessageType() string    { return TypeClaimVictory }
+func (*ClaimDraw) MessageType() string       { return TypeClaimDraw }
+func (*Resync) MessageType() string          { return TypeResync }
+func (*Welcome) MessageType() string         { return TypeWelcome }
+func (*Ack) MessageType() string             { return TypeAck }
+func (*Error) MessageType() string           { return TypeError }
+func (*State) MessageType() string           { return TypeState }
+func (*MoveMade) MessageType() string        { return TypeMoveMade }
+func (*Clock) MessageType() string           { return TypeClock }
+func (*GameOver) MessageType() string        { return TypeGameOver }
+func (*MissedMoves) MessageType() string     { return TypeMissedMoves }
+func (*Spectators) MessageType() string      { return TypeSpectators }
+func (*MatchFound) MessageType() string      { return TypeMatchFound }
+func (*Offer) MessageType() string           { return TypeOffer }
+func (*Takeback) MessageType() string        { return TypeTakeback }
+
+func (*OpponentDisconnected) MessageType() string { return TypeOpponentDisconnected }
+func (*OpponentReconnected) MessageType() string  { return TypeOpponentReconnected }
+func (*ChallengeCreated) MessageType() string     { return TypeChallengeCreated }
+func (*ChallengeJoined) MessageType() string      { return TypeChallengeJoined }
+func (*ChallengeExpired) MessageType() string     { return TypeChallengeExpired }
+func (*ChallengeReceived) MessageType() string    { return TypeChallengeReceived }
+func (*ChallengeAccepted) MessageType() string    { return TypeChallengeAccepted }
+func (*ChallengeDeclined) MessageType() string    { return TypeChallengeDeclined }
+func (*ChallengeCancelled) MessageType() string   { return TypeChallengeCancelled }
+
+// ClientMessages and ServerMessages list every payload by direction, the
+// schema is generated from them.
+var ClientMessages = []Message{
+	&Hello{},
+	&Move{},
+	&Resign{},
+	&OfferDraw{},
+	&AcceptDraw{},
+	&DeclineDraw{},
+	&TakebackRequest{},
+	&TakebackAccept{},
+	&Abort{},
+	&ClaimVictory{},
+	&ClaimDraw{},
+	&Resync{},
+}
+
+var ServerMessages = []Message{
+	&Welcome{},
+	&Ack{},
+	&Error{},
+	&State{},
+	&MoveMade{},
+	&Clock{},
+	&GameOver{},
+	&MissedMoves{},
+	&Spectators{},
+	&MatchFound{},
+	&Offer{},
+	&Takeback{},
+	&OpponentDisconnected{},
+	&OpponentReconnected{},
+	&ChallengeCreated{},
+	&ChallengeJoined{},
+	&ChallengeExpired{},
+	&ChallengeReceived{},
+	&ChallengeAccepted{},
+	&ChallengeDeclined{},
+	&ChallengeCancelled{},
+}
@@ -0,0 +1,123 @@
+// Package protocol defines the messages sent over the game and lobby
+// WebSockets. Every frame is an Envelope whose payload is one of the
+// structs in messages.go.
+package protocol
+
+//go:generate go run ./schemagen -o schema.json
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Version is bumped on every incompatible change to the messages.
+const Version = 1
+
+// Client to server message types
+const (
+	TypeHello           = "hello"
+	TypeMove            = "move"
+	TypeResign          = "resign"
+	TypeOfferDraw       = "offer_draw"
+	TypeAcceptDraw      = "accept_draw"
+	TypeDeclineDraw     = "decline_draw"
+	TypeTakebackRequest = "takeback_request"
+	TypeTakebackAccept  = "takeback_accept"
+	TypeAbort           = "abort"
+	TypeClaimVictory    = "claim_victory"
+	TypeClaimDraw       = "claim_draw"
+	TypeResync          = "resync"
+)
+
+// Server to client message types
+const (
+	TypeWelcome     = "welcome"
+	TypeAck         = "ack"
+	TypeError       = "error"
+	TypeState       = "state"
+	TypeMoveMade    = "move_made"
+	TypeClock       = "clock"
+	TypeGameOver    = "game_over"
+	TypeMissedMoves = "missed_moves"
+	TypeSpectators  = "spectators"
+	TypeMatchFound  = "match_found"
+	TypeOffer       = "offer"
+	TypeTakeback    = "takeback"
+
+	TypeOpponentDisconnected = "opponent_disconnected"
+	TypeOpponentReconnected  = "opponent_reconnected"
+
+	TypeChallengeCreated = "challenge_created"
+	TypeChallengeJoined  = "challenge_joined"
+	TypeChallengeExpired = "challenge_expired"
+
+	TypeChallengeReceived  = "challenge_received"
+	TypeChallengeAccepted  = "challenge_accepted"
+	TypeChallengeDeclined  = "challenge_declined"
+	TypeChallengeCancelled = "challenge_cancelled"
+)
+
+// Offer kinds and statuses sent in Offer
+const (
+	OfferKindDraw     = "draw"
+	OfferKindTakeback = "takeback"
+
+	OfferStatusMade     = "offered"
+	OfferStatusDeclined = "declined"
+)
+
+// Error codes sent in Error.Code
+const (
+	ErrBadRequest         = "bad_request"
+	ErrUnknownType        = "unknown_type"
+	ErrUnsupportedVersion = "unsupported_version"
+	ErrHandshakeRequired  = "handshake_required"
+	ErrForbidden          = "forbidden"
+	ErrIllegalMove        = "illegal_move"
+	ErrNotAllowed         = "not_allowed"
+)
+
+// Envelope wraps every message. ID is chosen by the client on requests and
+// echoed on the matching ack or error; server events leave it empty.
+type Envelope struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Message is implemented by every payload struct.
+type Message interface {
+	MessageType() string
+}
+
+// NewEnvelope wraps message, replying to the request id if it is set.
+func NewEnvelope(id string, message Message) Envelope {
+	payload, err := json.Marshal(message)
+	if err != nil {
+		// Only our own structs are marshalled, this cannot happen
+		panic(fmt.Sprintf("protocol: marshal %s: %v", message.MessageType(), err))
+	}
+
+	return Envelope{
+		Type:    message.MessageType(),
+		ID:      id,
+		Payload: payload,
+	}
+}
+
+// Decode unmarshals the payload into message after checking its type.
+func (e Envelope) Decode(message Message) error {
+	if e.Type != message.MessageType() {
+		return fmt.Errorf("expected %s payload, got %s", message.MessageType(), e.Type)
+	}
+
+	if len(e.Payload) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(e.Payload, message)
+}
+
+func NewError(id, code, message string) Envelope {
+	return NewEnvelope(id, &Error{Code: code, Message: message})
+}
@@ -0,0 +1,78 @@
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	envelopes := []struct {
+		Name     string
+		Frame    string
+		Decode   Message
+		Expected Message
+		Valid    bool
+	}{
+		{
+			Name:     "Move",
+			Frame:    `{"type":"move","id":"7","payload":{"move":"e4"}}`,
+			Decode:   &Move{},
+			Expected: &Move{Move: "e4"},
+			Valid:    true,
+		},
+		{
+			Name:     "Empty Payload",
+			Frame:    `{"type":"resign","id":"8"}`,
+			Decode:   &Resign{},
+			Expected: &Resign{},
+			Valid:    true,
+		},
+		{
+			Name:   "Wrong Type",
+			Frame:  `{"type":"resign","payload":{"move":"e4"}}`,
+			Decode: &Move{},
+			Valid:  false,
+		},
+	}
+
+	for _, tc := range envelopes {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			var envelope Envelope
+			if err := json.Unmarshal([]byte(tc.Frame), &envelope); err != nil {
+				t.Fatal(err)
+			}
+
+			err := envelope.Decode(tc.Decode)
+			if (err == nil) != tc.Valid {
+				t.Fatalf("expected valid %v, got %v", tc.Valid, err)
+			}
+
+			if !tc.Valid {
+				return
+			}
+
+			reply := NewEnvelope(envelope.ID, tc.Decode)
+			if reply.ID != envelope.ID || reply.Type != envelope.Type {
+				t.Errorf("expected %s %s, got %s %s", envelope.Type, envelope.ID, reply.Type, reply.ID)
+			}
+
+			expected, _ := json.Marshal(tc.Expected)
+			if !bytes.Equal(reply.Payload, expected) {
+				t.Errorf("expected payload %s, got %s", expected, reply.Payload)
+			}
+		})
+	}
+}
+
+func TestSchemaUpToDate(t *testing.T) {
+	generated, err := SchemaJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(generated, SchemaFile) {
+		t.Error("schema.json is out of date, run go generate ./protocol")
+	}
+}
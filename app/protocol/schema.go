@@ -0,0 +1,125 @@
+package protocol
+
+import (
+	_ "embed"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// SchemaFile is the generated schema.json, served to clients.
+//
+//go:embed schema.json
+var SchemaFile []byte
+
+// Schema returns a JSON Schema (draft 2020-12) for every envelope that can
+// be sent in either direction.
+func Schema() map[string]any {
+	defs := map[string]any{}
+	client := []any{}
+	server := []any{}
+
+	for _, message := range ClientMessages {
+		defs[message.MessageType()] = schemaFor(reflect.TypeOf(message).Elem())
+		client = append(client, envelopeSchema(message.MessageType()))
+	}
+
+	for _, message := range ServerMessages {
+		defs[message.MessageType()] = schemaFor(reflect.TypeOf(message).Elem())
+		server = append(server, envelopeSchema(message.MessageType()))
+	}
+
+	defs["client_message"] = map[string]any{"oneOf": client}
+	defs["server_message"] = map[string]any{"oneOf": server}
+
+	return map[string]any{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"$id":     "https://chessapp/protocol/schema.json",
+		"title":   "ChessApp WebSocket protocol",
+		"version": Version,
+		"oneOf": []any{
+			map[string]any{"$ref": "#/$defs/client_message"},
+			map[string]any{"$ref": "#/$defs/server_message"},
+		},
+		"$defs": defs,
+	}
+}
+
+// SchemaJSON is the indented form of Schema written to schema.json.
+func SchemaJSON() ([]byte, error) {
+	b, err := json.MarshalIndent(Schema(), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func envelopeSchema(msgType string) map[string]any {
+	return map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"type":    map[string]any{"const": msgType},
+			"id":      map[string]any{"type": "string"},
+			"payload": map[string]any{"$ref": "#/$defs/" + msgType},
+		},
+		"required":             []string{"type"},
+		"additionalProperties": false,
+	}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func schemaFor(t reflect.Type) map[string]any {
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaFor(t.Elem())}
+	case reflect.Pointer:
+		return schemaFor(t.Elem())
+	case reflect.Struct:
+		properties := map[string]any{}
+		required := []string{}
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			properties[name] = schemaFor(field.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+
+		return map[string]any{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	default:
+		return map[string]any{}
+	}
+}
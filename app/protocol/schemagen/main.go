@@ -0,0 +1,23 @@
+// Command schemagen writes the JSON Schema of the WebSocket protocol.
+package main
+
+import (
+	"ChessApp/protocol"
+	"flag"
+	"log"
+	"os"
+)
+
+func main() {
+	out := flag.String("o", "schema.json", "output file")
+	flag.Parse()
+
+	b, err := protocol.SchemaJSON()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.WriteFile(*out, b, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
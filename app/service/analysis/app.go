@@ -0,0 +1,243 @@
+package analysis
+
+import (
+	"ChessApp/service/app"
+	"ChessApp/types"
+	"ChessApp/uci"
+	"ChessApp/variant"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// queueSize is how many analyses may wait for the worker.
+const queueSize = 64
+
+var (
+	errNotFound    = fmt.Errorf("analysis not found")
+	errGameMissing = fmt.Errorf("game does not exist")
+	errNotFinished = fmt.Errorf("only finished games can be analysed")
+	errNotStandard = fmt.Errorf("only standard games can be analysed")
+	errQueueFull   = fmt.Errorf("too many analyses are waiting, try again later")
+)
+
+// App runs post-game analyses one at a time on a background worker. The
+// analyses waiting or running are kept in memory with their progress; the
+// store has the finished ones and the queue to resume after a restart.
+type App struct {
+	store    types.AnalysisStore
+	chessApp types.ChessApp
+	queue    chan string
+	jobs     map[string]*types.Analysis
+	mu       sync.Mutex
+}
+
+func NewApp(store types.AnalysisStore, chessApp types.ChessApp) *App {
+	return &App{
+		store:    store,
+		chessApp: chessApp,
+		queue:    make(chan string, queueSize),
+		jobs:     make(map[string]*types.Analysis),
+	}
+}
+
+// Request queues the analysis of a finished game. An analysis already
+// queued, running or done is returned as it is; a failed one is tried
+// again.
+func (a *App) Request(gameID string) (*types.Analysis, error) {
+	game, err := a.chessApp.GetGameByID(gameID)
+	if err != nil {
+		return nil, errGameMissing
+	}
+	if game.Status != types.GameStatusFinished {
+		return nil, errNotFinished
+	}
+	if game.Variant != "" && game.Variant != variant.Standard {
+		return nil, errNotStandard
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if job, ok := a.jobs[gameID]; ok {
+		return copyAnalysis(job), nil
+	}
+
+	existing, err := a.store.GetAnalysis(gameID)
+	if err != nil && err != errNotFound {
+		return nil, err
+	}
+	if existing != nil && existing.Status != types.AnalysisFailed {
+		return existing, nil
+	}
+
+	now := time.Now()
+	job := &types.Analysis{GameID: gameID, Status: types.AnalysisQueued, Moves: []types.AnalysedMove{}, CreatedAt: now, UpdatedAt: now}
+
+	select {
+	case a.queue <- gameID:
+	default:
+		return nil, errQueueFull
+	}
+	a.jobs[gameID] = job
+
+	if err := a.store.SaveAnalysis(*job); err != nil {
+		log.Printf("failed to save queued analysis of game %s: %v", gameID, err)
+	}
+
+	return copyAnalysis(job), nil
+}
+
+// Get returns the analysis of the game with the given id, with its progress
+// while it runs.
+func (a *App) Get(gameID string) (*types.Analysis, error) {
+	a.mu.Lock()
+	job, ok := a.jobs[gameID]
+	var result *types.Analysis
+	if ok {
+		result = copyAnalysis(job)
+	}
+	a.mu.Unlock()
+
+	if ok {
+		return result, nil
+	}
+	return a.store.GetAnalysis(gameID)
+}
+
+// Run resumes the analyses left over from the last run, then works through
+// the queue until stop is closed.
+func (a *App) Run(stop <-chan struct{}) {
+	pending, err := a.store.GetPendingAnalyses()
+	if err != nil {
+		log.Printf("failed to load pending analyses: %v", err)
+	}
+	for _, job := range pending {
+		a.mu.Lock()
+		if _, ok := a.jobs[job.GameID]; !ok {
+			job.Status = types.AnalysisQueued
+			job.Moves = []types.AnalysedMove{}
+			a.jobs[job.GameID] = &job
+		}
+		a.mu.Unlock()
+	}
+
+	for _, job := range pending {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		a.process(job.GameID)
+	}
+
+	for {
+		select {
+		case gameID := <-a.queue:
+			a.process(gameID)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// process analyses one game and stores the result, whether it succeeded or
+// not.
+func (a *App) process(gameID string) {
+	a.mu.Lock()
+	job, ok := a.jobs[gameID]
+	if !ok || job.Status != types.AnalysisQueued {
+		a.mu.Unlock()
+		return
+	}
+	job.Status = types.AnalysisRunning
+	a.mu.Unlock()
+
+	err := a.analyse(job)
+
+	a.mu.Lock()
+	job.Status, job.Progress = types.AnalysisDone, 100
+	if err != nil {
+		log.Printf("failed to analyse game %s: %v", gameID, err)
+		job.Status = types.AnalysisFailed
+		job.Error = err.Error()
+	}
+	job.UpdatedAt = time.Now()
+	result := copyAnalysis(job)
+	a.mu.Unlock()
+
+	if err := a.store.SaveAnalysis(*result); err != nil {
+		log.Printf("failed to save analysis of game %s: %v", gameID, err)
+	}
+
+	a.mu.Lock()
+	delete(a.jobs, gameID)
+	a.mu.Unlock()
+}
+
+// analyse searches every position of the game, reviewing each move as soon
+// as the position after it is scored so progress shows move by move.
+func (a *App) analyse(job *types.Analysis) error {
+	game, err := a.chessApp.GetGameByID(job.GameID)
+	if err != nil {
+		return errGameMissing
+	}
+
+	moves, err := a.chessApp.GetMovesByGameID(job.GameID)
+	if err != nil {
+		return err
+	}
+
+	positions, err := replay(*game, moves)
+	if err != nil {
+		return err
+	}
+
+	engine, err := app.StartEngine()
+	if err != nil {
+		return err
+	}
+	defer engine.Close()
+
+	played := []string{}
+	for _, move := range moves {
+		played = append(played, move.UCI)
+	}
+
+	var best uci.Analysis
+	var previous score
+	for i, pos := range positions {
+		analysis, current, err := evaluate(engine, game.InitialFEN, played[:i], pos)
+		if err != nil {
+			return fmt.Errorf("position after ply %d: %v", i, err)
+		}
+
+		a.mu.Lock()
+		if i > 0 {
+			job.Moves = append(job.Moves, review(positions[i-1], moves[i-1], best, previous, current))
+		}
+		job.Progress = (i + 1) * 100 / len(positions)
+		job.UpdatedAt = time.Now()
+		a.mu.Unlock()
+
+		best, previous = analysis, current
+	}
+
+	first := positions[0].Turn()
+
+	a.mu.Lock()
+	job.WhiteAccuracy = accuracy(job.Moves, chess.White, first)
+	job.BlackAccuracy = accuracy(job.Moves, chess.Black, first)
+	a.mu.Unlock()
+
+	return nil
+}
+
+func copyAnalysis(job *types.Analysis) *types.Analysis {
+	result := *job
+	result.Moves = append([]types.AnalysedMove{}, job.Moves...)
+	return &result
+}
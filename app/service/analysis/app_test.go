@@ -0,0 +1,307 @@
+package analysis
+
+import (
+	"ChessApp/engine"
+	"ChessApp/service/app"
+	"ChessApp/types"
+	"ChessApp/uci"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func TestRequestAnalysis(t *testing.T) {
+	a := NewApp(newMockStore(), newMockChessApp(t))
+
+	requests := []struct {
+		Name     string
+		GameID   string
+		Expected error
+	}{
+		{Name: "Unknown Game", GameID: "nobody", Expected: errGameMissing},
+		{Name: "Game In Progress", GameID: "playing", Expected: errNotFinished},
+		{Name: "Crazyhouse Game", GameID: "crazyhouse", Expected: errNotStandard},
+		{Name: "Finished Game", GameID: "scholar", Expected: nil},
+		{Name: "Already Queued", GameID: "scholar", Expected: nil},
+	}
+
+	for _, tc := range requests {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			analysis, err := a.Request(tc.GameID)
+			if err != tc.Expected {
+				t.Fatalf("expected error %v, got %v", tc.Expected, err)
+			}
+			if err == nil && analysis.Status != types.AnalysisQueued {
+				t.Errorf("expected a queued analysis, got %s", analysis.Status)
+			}
+		})
+	}
+
+	if len(a.queue) != 1 {
+		t.Errorf("expected one analysis in the queue, got %d", len(a.queue))
+	}
+
+	if _, err := a.Get("nobody"); err != errNotFound {
+		t.Errorf("expected no analysis of an unknown game, got %v", err)
+	}
+}
+
+func TestAnalyseGame(t *testing.T) {
+	useBuiltinEngine(t)
+
+	store := newMockStore()
+	a := NewApp(store, newMockChessApp(t))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go a.Run(stop)
+
+	if _, err := a.Request("scholar"); err != nil {
+		t.Fatal(err)
+	}
+
+	analysis := waitForAnalysis(t, a, "scholar")
+	if analysis.Status != types.AnalysisDone || analysis.Progress != 100 {
+		t.Fatalf("expected a finished analysis, got %s at %d%%: %s", analysis.Status, analysis.Progress, analysis.Error)
+	}
+	if len(analysis.Moves) != 7 {
+		t.Fatalf("expected 7 analysed moves, got %d", len(analysis.Moves))
+	}
+
+	blunder := analysis.Moves[5]
+	if blunder.SAN != "Nf6" || blunder.Classification != "blunder" || blunder.Mate != 1 || blunder.BestMove == "Nf6" {
+		t.Errorf("expected Nf6 to be a blunder allowing mate, got %+v", blunder)
+	}
+
+	mate := analysis.Moves[6]
+	if mate.Classification != "best" || mate.BestMove != "Qxf7#" || mate.Eval != mateEval || mate.Mate != 0 {
+		t.Errorf("expected Qxf7# to be the best move, got %+v", mate)
+	}
+	if len(mate.PV) != 1 || mate.PV[0] != "Qxf7#" {
+		t.Errorf("expected the line to be Qxf7#, got %v", mate.PV)
+	}
+
+	if analysis.WhiteAccuracy <= analysis.BlackAccuracy {
+		t.Errorf("expected white to play more accurately, got %.1f against %.1f", analysis.WhiteAccuracy, analysis.BlackAccuracy)
+	}
+
+	stored, err := store.GetAnalysis("scholar")
+	if err != nil || stored.Status != types.AnalysisDone || len(stored.Moves) != 7 {
+		t.Errorf("expected the analysis to be stored, got %+v, %v", stored, err)
+	}
+
+	again, err := a.Request("scholar")
+	if err != nil || again.Status != types.AnalysisDone {
+		t.Errorf("expected the finished analysis back, got %+v, %v", again, err)
+	}
+}
+
+func TestResumeAnalyses(t *testing.T) {
+	useBuiltinEngine(t)
+
+	store := newMockStore()
+	store.SaveAnalysis(types.Analysis{GameID: "scholar", Status: types.AnalysisRunning, Moves: []types.AnalysedMove{{Ply: 1}}})
+	store.SaveAnalysis(types.Analysis{GameID: "missing", Status: types.AnalysisQueued})
+
+	a := NewApp(store, newMockChessApp(t))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go a.Run(stop)
+
+	if analysis := waitForAnalysis(t, a, "scholar"); analysis.Status != types.AnalysisDone || len(analysis.Moves) != 7 {
+		t.Errorf("expected the interrupted analysis to be done again, got %s with %d moves", analysis.Status, len(analysis.Moves))
+	}
+	if analysis := waitForAnalysis(t, a, "missing"); analysis.Status != types.AnalysisFailed || analysis.Error == "" {
+		t.Errorf("expected the analysis of a deleted game to fail, got %s", analysis.Status)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	losses := []struct {
+		Loss     int
+		Expected string
+	}{
+		{Loss: 0, Expected: "best"},
+		{Loss: 20, Expected: "good"},
+		{Loss: 50, Expected: "inaccuracy"},
+		{Loss: 150, Expected: "mistake"},
+		{Loss: 300, Expected: "blunder"},
+	}
+
+	for _, tc := range losses {
+		if classification := classify(tc.Loss); classification != tc.Expected {
+			t.Errorf("expected a loss of %d to be a %s, got %s", tc.Loss, tc.Expected, classification)
+		}
+	}
+
+	if accuracy := moveAccuracy(winChance(30), winChance(30)); accuracy != 100 {
+		t.Errorf("expected a move keeping the evaluation to be 100%% accurate, got %.1f", accuracy)
+	}
+	if accuracy := moveAccuracy(winChance(0), winChance(-1000)); accuracy > 10 {
+		t.Errorf("expected a losing move to be inaccurate, got %.1f", accuracy)
+	}
+}
+
+// useBuiltinEngine analyses with a shallow search of the built-in engine
+// for the rest of the test.
+func useBuiltinEngine(t *testing.T) {
+	start, limits := app.StartEngine, searchLimits
+	app.StartEngine = func() (app.Engine, error) { return engine.New(), nil }
+	searchLimits = uci.Limits{Depth: 3}
+
+	t.Cleanup(func() { app.StartEngine, searchLimits = start, limits })
+}
+
+// waitForAnalysis waits for the analysis of gameID to finish or fail.
+func waitForAnalysis(t *testing.T, a *App, gameID string) *types.Analysis {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		analysis, err := a.Get(gameID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if analysis.Status == types.AnalysisDone || analysis.Status == types.AnalysisFailed {
+			return analysis
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the analysis to finish, still %s at %d%%", analysis.Status, analysis.Progress)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// playMoves replays moves in SAN from the start position into stored moves.
+func playMoves(t *testing.T, gameID string, moves ...string) []types.Move {
+	t.Helper()
+
+	game := chess.NewGame()
+	played := []types.Move{}
+
+	for i, san := range moves {
+		pos := game.Position()
+		move, err := chess.AlgebraicNotation{}.Decode(pos, san)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := game.Move(move); err != nil {
+			t.Fatal(err)
+		}
+
+		played = append(played, types.Move{
+			GameID: gameID,
+			Ply:    i + 1,
+			SAN:    san,
+			UCI:    chess.UCINotation{}.Encode(pos, move),
+			FEN:    game.Position().String(),
+		})
+	}
+
+	return played
+}
+
+type mockStore struct {
+	analyses map[string]types.Analysis
+	mu       sync.Mutex
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{analyses: make(map[string]types.Analysis)}
+}
+
+func (m *mockStore) GetAnalysis(gameID string) (*types.Analysis, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	analysis, ok := m.analyses[gameID]
+	if !ok {
+		return nil, errNotFound
+	}
+	return &analysis, nil
+}
+
+func (m *mockStore) GetPendingAnalyses() ([]types.Analysis, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending := []types.Analysis{}
+	for _, analysis := range m.analyses {
+		if analysis.Status == types.AnalysisQueued || analysis.Status == types.AnalysisRunning {
+			pending = append(pending, analysis)
+		}
+	}
+	return pending, nil
+}
+
+func (m *mockStore) SaveAnalysis(analysis types.Analysis) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.analyses[analysis.GameID] = analysis
+	return nil
+}
+
+type mockChessApp struct {
+	games map[string]types.Game
+	moves map[string][]types.Move
+}
+
+func newMockChessApp(t *testing.T) *mockChessApp {
+	return &mockChessApp{
+		games: map[string]types.Game{
+			"scholar":    {ID: "scholar", Status: types.GameStatusFinished, Variant: "standard", Outcome: "1-0", Method: "checkmate"},
+			"playing":    {ID: "playing", Status: types.GameStatusPlaying, Variant: "standard"},
+			"crazyhouse": {ID: "crazyhouse", Status: types.GameStatusFinished, Variant: "crazyhouse"},
+		},
+		moves: map[string][]types.Move{
+			"scholar": playMoves(t, "scholar", "e4", "e5", "Qh5", "Nc6", "Bc4", "Nf6", "Qxf7#"),
+		},
+	}
+}
+
+func (m *mockChessApp) CreateGame(options types.GameOptions) (*types.Game, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *mockChessApp) GetGameByID(id string) (*types.Game, error) {
+	game, ok := m.games[id]
+	if !ok {
+		return nil, fmt.Errorf("game not found")
+	}
+	return &game, nil
+}
+
+func (m *mockChessApp) GetUnfinishedGames() ([]types.Game, error) {
+	return nil, nil
+}
+
+func (m *mockChessApp) UpdateGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) FinishGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) ImportGame(game types.Game, moves []types.Move) (*types.Game, error) {
+	return &game, nil
+}
+
+func (m *mockChessApp) CreateMove(move types.Move) error {
+	return nil
+}
+
+func (m *mockChessApp) DeleteMovesAfter(gameID string, ply int) error {
+	return nil
+}
+
+func (m *mockChessApp) GetMovesByGameID(gameID string) ([]types.Move, error) {
+	return m.moves[gameID], nil
+}
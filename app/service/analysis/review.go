@@ -0,0 +1,202 @@
+package analysis
+
+import (
+	"ChessApp/service/app"
+	"ChessApp/types"
+	"ChessApp/uci"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// mateEval is the centipawn value given to a forced mate.
+const mateEval = 10000
+
+// searchLimits bound the search of every position of a game. Tests lower
+// them to keep the built-in engine quick.
+var searchLimits = uci.Limits{Depth: 18, MoveTime: 500 * time.Millisecond}
+
+// score rates a position from white's point of view. Mate counts the moves
+// to a forced mate, negative if black mates; cp is then ±mateEval.
+type score struct {
+	cp   int
+	mate int
+}
+
+// replay returns the positions of game, from the start position to the one
+// after the last move.
+func replay(game types.Game, moves []types.Move) ([]*chess.Position, error) {
+	start := chess.StartingPosition()
+	if game.InitialFEN != "" {
+		option, err := chess.FEN(game.InitialFEN)
+		if err != nil {
+			return nil, err
+		}
+		start = chess.NewGame(option).Position()
+	}
+
+	positions := []*chess.Position{start}
+	for _, move := range moves {
+		pos := positions[len(positions)-1]
+		m := legalMove(pos, move.UCI)
+		if m == nil {
+			return nil, fmt.Errorf("move %d %s is not legal", move.Ply, move.SAN)
+		}
+		positions = append(positions, pos.Update(m))
+	}
+
+	return positions, nil
+}
+
+// evaluate searches pos, reached from fen by moves. A position without legal
+// moves is scored from the board, as engines have nothing to search there.
+func evaluate(engine app.Engine, fen string, moves []string, pos *chess.Position) (uci.Analysis, score, error) {
+	if len(pos.ValidMoves()) == 0 {
+		if pos.Status() != chess.Checkmate {
+			return uci.Analysis{}, score{}, nil
+		}
+		if pos.Turn() == chess.White {
+			return uci.Analysis{}, score{cp: -mateEval}, nil
+		}
+		return uci.Analysis{}, score{cp: mateEval}, nil
+	}
+
+	analysis, err := engine.Analyse(fen, moves, searchLimits, searchLimits.MoveTime+10*time.Second)
+	if err != nil {
+		return uci.Analysis{}, score{}, err
+	}
+
+	s := score{cp: analysis.Score, mate: analysis.Mate}
+	switch {
+	case s.mate > 0:
+		s.cp = mateEval
+	case s.mate < 0:
+		s.cp = -mateEval
+	}
+	if pos.Turn() == chess.Black {
+		s.cp, s.mate = -s.cp, -s.mate
+	}
+
+	return analysis, s, nil
+}
+
+// review judges the move played in before, given the engine's analysis of
+// before and the scores of the positions before and after the move.
+func review(before *chess.Position, played types.Move, best uci.Analysis, scoreBefore, scoreAfter score) types.AnalysedMove {
+	sign := 1
+	if before.Turn() == chess.Black {
+		sign = -1
+	}
+
+	// Past a clear advantage the exact number no longer matters, so a
+	// missed mate in an already won game is not a blunder
+	cpBefore := clamp(sign * scoreBefore.cp)
+	cpAfter := clamp(sign * scoreAfter.cp)
+	if played.UCI == best.BestMove {
+		cpAfter = cpBefore
+	}
+	loss := max(0, cpBefore-cpAfter)
+
+	bestMove, pv := sanLine(before, best.PV)
+
+	return types.AnalysedMove{
+		Ply:            played.Ply,
+		SAN:            played.SAN,
+		Eval:           scoreAfter.cp,
+		Mate:           scoreAfter.mate,
+		BestMove:       bestMove,
+		PV:             pv,
+		Classification: classify(loss),
+		Loss:           loss,
+		Accuracy:       moveAccuracy(winChance(cpBefore), winChance(cpAfter)),
+	}
+}
+
+func clamp(cp int) int {
+	return max(-1000, min(1000, cp))
+}
+
+// classify names a move by the centipawns it gave away.
+func classify(loss int) string {
+	switch {
+	case loss == 0:
+		return "best"
+	case loss < 50:
+		return "good"
+	case loss < 100:
+		return "inaccuracy"
+	case loss < 300:
+		return "mistake"
+	default:
+		return "blunder"
+	}
+}
+
+// winChance turns centipawns into the chance of winning, in percent, with
+// the same curve as lichess.
+func winChance(cp int) float64 {
+	return 50 + 50*(2/(1+math.Exp(-0.00368208*float64(cp)))-1)
+}
+
+// moveAccuracy rates a move from 0 to 100 by the winning chances it lost.
+func moveAccuracy(before, after float64) float64 {
+	accuracy := 103.1668*math.Exp(-0.04354*max(0, before-after)) - 3.1669
+	return round(max(0, min(100, accuracy)))
+}
+
+// accuracy is the mean accuracy of the moves played by color.
+func accuracy(moves []types.AnalysedMove, color chess.Color, firstTurn chess.Color) float64 {
+	total, count := 0.0, 0
+	for i, move := range moves {
+		turn := firstTurn
+		if i%2 == 1 {
+			turn = firstTurn.Other()
+		}
+		if turn == color {
+			total += move.Accuracy
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return round(total / float64(count))
+}
+
+func round(x float64) float64 {
+	return math.Round(x*10) / 10
+}
+
+// sanLine converts a line of UCI moves from pos to SAN. It returns the
+// first move apart, and stops at a move that is not legal.
+func sanLine(pos *chess.Position, line []string) (string, []string) {
+	moves := []string{}
+	for _, s := range line {
+		move := legalMove(pos, s)
+		if move == nil {
+			break
+		}
+		moves = append(moves, chess.AlgebraicNotation{}.Encode(pos, move))
+		pos = pos.Update(move)
+	}
+
+	if len(moves) == 0 {
+		return "", moves
+	}
+	return moves[0], moves
+}
+
+// legalMove finds the move given in UCI notation among the legal moves of
+// pos. Unlike a decoded move it carries the check and capture tags that SAN
+// and the game status rely on.
+func legalMove(pos *chess.Position, s string) *chess.Move {
+	for _, move := range pos.ValidMoves() {
+		if (chess.UCINotation{}).Encode(pos, move) == s {
+			return move
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"ChessApp/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	app *App
+}
+
+func NewHandler(app *App) *Handler {
+	return &Handler{app: app}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	auth.SetAccess(router.HandleFunc("/game/{id}/analysis", h.handleRequest).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/game/{id}/analysis", h.handleGet).Methods(http.MethodGet), auth.Public)
+}
+
+func (h *Handler) handleRequest(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+
+	analysis, err := h.app.Request(vars["id"])
+	switch err {
+	case nil:
+	case errGameMissing:
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	case errNotFinished, errNotStandard:
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	case errQueueFull:
+		utils.WriteError(w, http.StatusServiceUnavailable, err)
+		return
+	default:
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	status := http.StatusAccepted
+	if analysis.Status == types.AnalysisDone {
+		status = http.StatusOK
+	}
+	utils.WriteJSON(w, status, analysis)
+}
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+
+	analysis, err := h.app.Get(vars["id"])
+	if err == errNotFound {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, analysis)
+}
@@ -0,0 +1,165 @@
+package analysis
+
+import (
+	"ChessApp/types"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+type Store struct {
+	db *sql.DB
+}
+
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) GetAnalysis(gameID string) (*types.Analysis, error) {
+
+	rows, err := s.db.Query("SELECT * FROM analyses WHERE game_id = ?", gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	a := new(types.Analysis)
+
+	for rows.Next() {
+		a, err = scanRowIntoAnalysis(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if a.GameID == "" {
+		return nil, errNotFound
+	}
+
+	a.Moves, err = s.getMoves(gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// GetPendingAnalyses returns the analyses that were queued or running when
+// the server stopped, oldest first.
+func (s *Store) GetPendingAnalyses() ([]types.Analysis, error) {
+
+	rows, err := s.db.Query("SELECT * FROM analyses WHERE status IN (?, ?) ORDER BY created_at", types.AnalysisQueued, types.AnalysisRunning)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	analyses := []types.Analysis{}
+
+	for rows.Next() {
+		a, err := scanRowIntoAnalysis(rows)
+		if err != nil {
+			return nil, err
+		}
+		analyses = append(analyses, *a)
+	}
+
+	return analyses, rows.Err()
+}
+
+// SaveAnalysis writes a, replacing the moves stored for its game before.
+func (s *Store) SaveAnalysis(a types.Analysis) error {
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		`INSERT INTO analyses (game_id, status, white_accuracy, black_accuracy, error, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (game_id) DO UPDATE SET status = excluded.status, white_accuracy = excluded.white_accuracy, black_accuracy = excluded.black_accuracy, error = excluded.error, updated_at = excluded.updated_at`,
+		a.GameID, a.Status, a.WhiteAccuracy, a.BlackAccuracy, a.Error, a.CreatedAt, a.UpdatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec("DELETE FROM analysis_moves WHERE game_id = ?", a.GameID); err != nil {
+		return err
+	}
+
+	for _, move := range a.Moves {
+		_, err = tx.Exec(
+			"INSERT INTO analysis_moves (game_id, ply, san, eval, mate, best_move, pv, classification, loss, accuracy) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			a.GameID, move.Ply, move.SAN, move.Eval, move.Mate, move.BestMove, strings.Join(move.PV, " "), move.Classification, move.Loss, move.Accuracy,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *Store) getMoves(gameID string) ([]types.AnalysedMove, error) {
+
+	rows, err := s.db.Query("SELECT ply, san, eval, mate, best_move, pv, classification, loss, accuracy FROM analysis_moves WHERE game_id = ? ORDER BY ply", gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := []types.AnalysedMove{}
+
+	for rows.Next() {
+		var move types.AnalysedMove
+		var pv string
+
+		err := rows.Scan(
+			&move.Ply,
+			&move.SAN,
+			&move.Eval,
+			&move.Mate,
+			&move.BestMove,
+			&pv,
+			&move.Classification,
+			&move.Loss,
+			&move.Accuracy,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read analysis of game %s: %v", gameID, err)
+		}
+
+		move.PV = strings.Fields(pv)
+		moves = append(moves, move)
+	}
+
+	return moves, rows.Err()
+}
+
+func scanRowIntoAnalysis(rows *sql.Rows) (*types.Analysis, error) {
+	a := new(types.Analysis)
+
+	err := rows.Scan(
+		&a.GameID,
+		&a.Status,
+		&a.WhiteAccuracy,
+		&a.BlackAccuracy,
+		&a.Error,
+		&a.CreatedAt,
+		&a.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if a.Status == types.AnalysisDone {
+		a.Progress = 100
+	}
+	return a, nil
+}
@@ -0,0 +1,244 @@
+package app
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/variant"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// action applies a player's command to game, which must be locked, and
+// returns the messages to broadcast.
+type action func(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error)
+
+var actions = map[string]action{
+	protocol.TypeResign:          resign,
+	protocol.TypeOfferDraw:       offerDraw,
+	protocol.TypeAcceptDraw:      acceptDraw,
+	protocol.TypeDeclineDraw:     declineDraw,
+	protocol.TypeTakebackRequest: requestTakeback,
+	protocol.TypeTakebackAccept:  acceptTakeback,
+	protocol.TypeAbort:           abort,
+	protocol.TypeClaimVictory:    claimVictory,
+	protocol.TypeClaimDraw:       claimDraw,
+}
+
+// playerColor returns the side username plays, or chess.NoColor. Nobody
+// plays for a bot, even a user who happens to share its name.
+func playerColor(game *ChessGame, username string) chess.Color {
+	switch {
+	case username == "" || (game.bot != nil && username == game.bot.Name):
+		return chess.NoColor
+	case username == game.PlayerWhite:
+		return chess.White
+	case username == game.PlayerBlack:
+		return chess.Black
+	default:
+		return chess.NoColor
+	}
+}
+
+func colorName(color chess.Color) string {
+	return strings.ToLower(color.Name())
+}
+
+func checkPlaying(game *ChessGame) error {
+	if game.Outcome != "" {
+		return fmt.Errorf("game is over: %s by %s", game.Outcome, game.Method)
+	}
+
+	if !game.GameStarted {
+		return fmt.Errorf("game has not started")
+	}
+
+	return nil
+}
+
+func resign(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if err := checkPlaying(game); err != nil {
+		return nil, err
+	}
+
+	endGame(game, winner(color.Other()), "resignation")
+	return []protocol.Message{newGameOverMessage(game)}, nil
+}
+
+// offerDraw accepts the opponent's pending offer instead of making a second
+// one, so two crossing offers end the game.
+func offerDraw(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if err := checkPlaying(game); err != nil {
+		return nil, err
+	}
+
+	switch game.DrawOffer {
+	case color:
+		return nil, fmt.Errorf("draw already offered")
+	case color.Other():
+		return acceptDraw(game, color, now)
+	}
+
+	game.DrawOffer = color
+	game.Version++
+	persistGame(game)
+
+	return []protocol.Message{&protocol.Offer{Kind: protocol.OfferKindDraw, Color: colorName(color), Status: protocol.OfferStatusMade}}, nil
+}
+
+func acceptDraw(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if err := checkPlaying(game); err != nil {
+		return nil, err
+	}
+
+	if game.DrawOffer != color.Other() {
+		return nil, fmt.Errorf("no draw offer to accept")
+	}
+
+	game.DrawOffer = chess.NoColor
+	endGame(game, chess.Draw, variant.DrawAgreement)
+	return []protocol.Message{newGameOverMessage(game)}, nil
+}
+
+func declineDraw(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if game.DrawOffer != color.Other() {
+		return nil, fmt.Errorf("no draw offer to decline")
+	}
+
+	game.DrawOffer = chess.NoColor
+	game.Version++
+	persistGame(game)
+
+	return []protocol.Message{&protocol.Offer{Kind: protocol.OfferKindDraw, Color: colorName(color.Other()), Status: protocol.OfferStatusDeclined}}, nil
+}
+
+// takebackPlies is the number of plies to undo so that color is to move
+// again: their own last move, and the opponent's reply if it was played.
+func takebackPlies(game *ChessGame, color chess.Color) (int, error) {
+	plies := 2
+	if game.Game.Position().Turn() != color {
+		plies = 1
+	}
+
+	if plies > len(game.Moves) {
+		return 0, fmt.Errorf("no move to take back")
+	}
+
+	return plies, nil
+}
+
+func requestTakeback(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if err := checkPlaying(game); err != nil {
+		return nil, err
+	}
+
+	if game.TakebackOffer == color {
+		return nil, fmt.Errorf("takeback already requested")
+	}
+
+	if _, err := takebackPlies(game, color); err != nil {
+		return nil, err
+	}
+
+	game.TakebackOffer = color
+	game.Version++
+	persistGame(game)
+
+	return []protocol.Message{&protocol.Offer{Kind: protocol.OfferKindTakeback, Color: colorName(color), Status: protocol.OfferStatusMade}}, nil
+}
+
+func acceptTakeback(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if err := checkPlaying(game); err != nil {
+		return nil, err
+	}
+
+	requester := color.Other()
+	if game.TakebackOffer != requester {
+		return nil, fmt.Errorf("no takeback request to accept")
+	}
+
+	plies, err := takebackPlies(game, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := takeBack(game, len(game.Moves)-plies, now); err != nil {
+		return nil, err
+	}
+
+	takeback := &protocol.Takeback{
+		Version: game.Version,
+		Ply:     len(game.Moves),
+		FEN:     game.Game.Position().String(),
+		Turn:    game.Game.Position().Turn().String(),
+	}
+
+	return []protocol.Message{takeback, newClockMessage(game)}, nil
+}
+
+// takeBack rewinds game to ply. The board is replayed from the start and
+// both clocks are reset to the times saved with the last remaining move.
+func takeBack(game *ChessGame, ply int, now time.Time) error {
+	chessGame, err := variant.NewGame(game.Variant, game.Game.StartFEN())
+	if err != nil {
+		return err
+	}
+
+	moves := game.Moves[:ply]
+	if err := replayMoves(chessGame, moves); err != nil {
+		return err
+	}
+
+	game.Game = chessGame
+	game.Moves = moves
+	game.CurrentTurn = chessGame.Position().Turn().String()
+	game.DrawOffer = chess.NoColor
+	game.TakebackOffer = chess.NoColor
+	game.Version++
+
+	if ply == 0 {
+		initial := time.Duration(game.InitialTime) * time.Minute
+		game.Clock.White, game.Clock.Black = initial, initial
+	} else {
+		last := moves[ply-1]
+		game.Clock.White = time.Duration(last.WhiteTime) * time.Millisecond
+		game.Clock.Black = time.Duration(last.BlackTime) * time.Millisecond
+	}
+	game.Clock.LastUpdate = now
+	armClock(game)
+
+	deleteMoves(game, ply)
+	persistGame(game)
+	return nil
+}
+
+// abort ends the game without a result. Once both sides have moved the game
+// has to be resigned instead.
+func abort(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if game.Outcome != "" {
+		return nil, fmt.Errorf("game is over: %s by %s", game.Outcome, game.Method)
+	}
+
+	if len(game.Moves) >= 2 {
+		return nil, fmt.Errorf("game can no longer be aborted")
+	}
+
+	closeChallenge(game, &protocol.ChallengeExpired{GameID: game.ID, Reason: "aborted"})
+	endGame(game, chess.NoOutcome, "aborted")
+	return []protocol.Message{newGameOverMessage(game)}, nil
+}
+
+// pendingOffers lists the open offers as "kind:color" for the state message.
+func pendingOffers(game *ChessGame) []string {
+	offers := []string{}
+
+	if game.DrawOffer != chess.NoColor {
+		offers = append(offers, protocol.OfferKindDraw+":"+colorName(game.DrawOffer))
+	}
+	if game.TakebackOffer != chess.NoColor {
+		offers = append(offers, protocol.OfferKindTakeback+":"+colorName(game.TakebackOffer))
+	}
+
+	return offers
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"ChessApp/protocol"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+type step struct {
+	Color  chess.Color
+	Action string
+}
+
+func TestActions(t *testing.T) {
+	sequences := []struct {
+		Name    string
+		Moves   []string
+		Steps   []step
+		Valid   bool
+		Outcome string
+		Method  string
+		Ply     int
+	}{
+		{
+			Name:    "Resign",
+			Moves:   []string{"e4", "e5"},
+			Steps:   []step{{chess.White, protocol.TypeResign}},
+			Valid:   true,
+			Outcome: "0-1",
+			Method:  "resignation",
+			Ply:     2,
+		},
+		{
+			Name:    "Draw Agreed",
+			Moves:   []string{"e4", "e5"},
+			Steps:   []step{{chess.White, protocol.TypeOfferDraw}, {chess.Black, protocol.TypeAcceptDraw}},
+			Valid:   true,
+			Outcome: "1/2-1/2",
+			Method:  "draw_agreement",
+			Ply:     2,
+		},
+		{
+			Name:    "Crossing Draw Offers",
+			Moves:   []string{"e4"},
+			Steps:   []step{{chess.Black, protocol.TypeOfferDraw}, {chess.White, protocol.TypeOfferDraw}},
+			Valid:   true,
+			Outcome: "1/2-1/2",
+			Method:  "draw_agreement",
+			Ply:     1,
+		},
+		{
+			Name:  "Draw Declined",
+			Moves: []string{"e4"},
+			Steps: []step{{chess.White, protocol.TypeOfferDraw}, {chess.Black, protocol.TypeDeclineDraw}, {chess.Black, protocol.TypeAcceptDraw}},
+			Valid: false,
+			Ply:   1,
+		},
+		{
+			Name:  "Accept Own Draw Offer",
+			Moves: []string{"e4"},
+			Steps: []step{{chess.White, protocol.TypeOfferDraw}, {chess.White, protocol.TypeAcceptDraw}},
+			Valid: false,
+			Ply:   1,
+		},
+		{
+			Name:  "Takeback Last Move",
+			Moves: []string{"e4", "e5", "Nf3"},
+			Steps: []step{{chess.White, protocol.TypeTakebackRequest}, {chess.Black, protocol.TypeTakebackAccept}},
+			Valid: true,
+			Ply:   2,
+		},
+		{
+			Name:  "Takeback With Reply",
+			Moves: []string{"e4", "e5", "Nf3"},
+			Steps: []step{{chess.Black, protocol.TypeTakebackRequest}, {chess.White, protocol.TypeTakebackAccept}},
+			Valid: true,
+			Ply:   1,
+		},
+		{
+			Name:  "Takeback Without Moves",
+			Moves: []string{"e4"},
+			Steps: []step{{chess.Black, protocol.TypeTakebackRequest}},
+			Valid: false,
+			Ply:   1,
+		},
+		{
+			Name:    "Abort After One Move",
+			Moves:   []string{"e4"},
+			Steps:   []step{{chess.Black, protocol.TypeAbort}},
+			Valid:   true,
+			Outcome: "*",
+			Method:  "aborted",
+			Ply:     1,
+		},
+		{
+			Name:  "Abort After Both Moved",
+			Moves: []string{"e4", "e5"},
+			Steps: []step{{chess.White, protocol.TypeAbort}},
+			Valid: false,
+			Ply:   2,
+		},
+	}
+
+	for _, tc := range sequences {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			game := &ChessGame{Color: "white", InitialTime: 5}
+			JoinGame(game, "alice")
+			JoinGame(game, "bob")
+			defer game.Clock.stopTimer()
+
+			for _, move := range tc.Moves {
+				if _, err := MakeMove(game, move); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var err error
+			for _, s := range tc.Steps {
+				if _, err = actions[s.Action](game, s.Color, time.Now()); err != nil {
+					break
+				}
+			}
+
+			if (err == nil) != tc.Valid {
+				t.Fatalf("expected valid %v, got %v", tc.Valid, err)
+			}
+
+			if game.Outcome != tc.Outcome || game.Method != tc.Method {
+				t.Errorf("expected %q by %q, got %q by %q", tc.Outcome, tc.Method, game.Outcome, game.Method)
+			}
+
+			if len(game.Moves) != tc.Ply || len(game.Game.Moves()) != tc.Ply {
+				t.Errorf("expected %d plies, got %d moves and %d on the board", tc.Ply, len(game.Moves), len(game.Game.Moves()))
+			}
+		})
+	}
+}
+
+func TestTakebackRestoresClock(t *testing.T) {
+	game := &ChessGame{Color: "white", InitialTime: 5, TimeControl: 2}
+	JoinGame(game, "alice")
+	JoinGame(game, "bob")
+	defer game.Clock.stopTimer()
+
+	for _, move := range []string{"e4", "e5"} {
+		if _, err := MakeMove(game, move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	white, black := game.Clock.White, game.Clock.Black
+
+	MakeMove(game, "Nf3")
+	if _, err := requestTakeback(game, chess.White, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acceptTakeback(game, chess.Black, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if game.Clock.White != white.Truncate(time.Millisecond) || game.Clock.Black != black.Truncate(time.Millisecond) {
+		t.Errorf("expected clocks %v %v, got %v %v", white, black, game.Clock.White, game.Clock.Black)
+	}
+
+	if game.CurrentTurn != "w" {
+		t.Errorf("expected white to move, got %s", game.CurrentTurn)
+	}
+}
@@ -1,141 +1,266 @@
 package app
 
 import (
+	"ChessApp/protocol"
+	"ChessApp/types"
+	"ChessApp/variant"
 	"fmt"
-	"sync"
+	"math/rand"
 	"time"
 
 	"github.com/notnil/chess"
-	"github.com/gorilla/websocket"
-	"github.com/matoous/go-nanoid/v2"
 )
 
 type ChessGame struct {
-	PlayerWhite     string
-	PlayerBlack     string
-	CurrentTurn     string
-	Color           string
-	InitialTime     int
-	TimeControl     int
-	GameStarted     bool
-	PlayerWhiteTime time.Time
-	PlayerBlackTime time.Time
-	LastUpdate      time.Time
-
-	Game			*chess.Game
-
-	Connections []*websocket.Conn
-	mu sync.Mutex
+	ID             string
+	PlayerWhite    string
+	PlayerBlack    string
+	CurrentTurn    string
+	Color          string
+	InitialTime    int
+	TimeControl    int
+	Rated          bool
+	SpectatorDelay int
+	Variant        string
+
+	// Start position, empty for the standard one
+	InitialFEN string
+
+	// Level of the engine in the opponent's seat, 0 if there is none
+	BotLevel int
+	bot      *Bot
+
+	GameStarted bool
+	Version     int
+	Outcome     string
+	Method      string
+	Clock       *Clock
+	CreatedAt   time.Time
+
+	// Side with a pending offer, chess.NoColor if there is none
+	DrawOffer     chess.Color
+	TakebackOffer chess.Color
+
+	// Open challenges are listed in the lobby until joined or expired
+	Open          bool
+	ExpiresAt     time.Time
+	CreatorRating int
+	lobby         types.Lobby
+	expiry        *time.Timer
+
+	// Open connections per player, and when a player's last one closed
+	presence    map[chess.Color]int
+	absentSince map[chess.Color]time.Time
+
+	Game  *variant.Game
+	Moves []types.Move
+
+	Connections []*Client
+
+	// Messages held back from spectators, oldest first, see spectator.go
+	delayed      []delayedMessage
+	delayedTimer *time.Timer
+
+	// Set up by Registry.Add, see registry.go
+	commands chan func()
+	stopped  chan struct{}
+
+	app types.ChessApp
 }
 
+func JoinGame(game *ChessGame, username string) error {
 
-var GameStore = make(map[string]*ChessGame)
-
-func NewApp() *ChessGame {
-	return &ChessGame{}
-}
-
-func (c *ChessGame) CreateGame(initialTime, timeControl int, color string) error {
-	gameID, err := gonanoid.New(10)
-	if err != nil {
-		return err
-	}
-
-	game := &ChessGame{
-		InitialTime: initialTime,
-		TimeControl: timeControl,
-		Color:       color,
-		GameStarted: false,
+	if game.Outcome != "" {
+		return fmt.Errorf("game is over")
 	}
 
-	GameStore[gameID] = game
-
-	fmt.Println(GameStore)
-	return nil
-}
-
-func JoinGame(game *ChessGame, username string) error {
-
-	
 	if game.PlayerWhite != "" && game.PlayerBlack != "" {
 		return fmt.Errorf("game already full")
 	}
 
 	assignPlayer(game, username)
+	game.Version++
 
 	if game.PlayerWhite != "" && game.PlayerBlack != "" && !game.GameStarted {
+		closeChallenge(game, &protocol.ChallengeJoined{GameID: game.ID, Opponent: username})
 		startGame(game)
 	}
-	fmt.Printf("%+v\n", game)
+	promoteClients(game, username)
+
+	persistGame(game)
 	return nil
 }
 
 func MakeMove(game *ChessGame, move string) (string, error) {
 
+	if game.Outcome != "" {
+		return "", fmt.Errorf("game is over: %s by %s", game.Outcome, game.Method)
+	}
+
 	chessGame := game.Game
+	now := time.Now()
+	turn := chessGame.Position().Turn()
+
+	if game.Clock.Flagged(turn, now) {
+		flagFall(game, turn, now)
+		return "", errOutOfTime
+	}
+
+	prePosition := chessGame.Position()
 
 	err := chessGame.MoveStr(move)
 	if err != nil {
 		return "", err
 	}
 
+	game.Clock.Punch(turn, now)
 	game.CurrentTurn = chessGame.Position().Turn().String()
 
-	isGameOver(*game.Game)
- 
+	// Moving declines the opponent's draw offer and drops any takeback request
+	if game.DrawOffer == turn.Other() {
+		game.DrawOffer = chess.NoColor
+	}
+	game.TakebackOffer = chess.NoColor
+
+	recordMove(game, prePosition, now)
+
+	if !isGameOver(game) {
+		armClock(game)
+		persistGame(game)
+	}
+
 	pos := chessGame.Position()
 	return pos.String(), nil
 
 }
 
+// assignPlayer seats the creator on the colour they asked for, tossing a
+// coin for "random", and the opponent on whichever seat is left.
 func assignPlayer(game *ChessGame, username string) {
-	if game.Color == "white" {
-		if game.PlayerWhite == "" {
-			game.PlayerWhite = username
-		} else if game.PlayerBlack == "" && game.PlayerWhite != username {
-			game.PlayerBlack = username
-		}
+	if game.PlayerWhite == username || game.PlayerBlack == username {
+		return
+	}
 
-	} else if game.Color == "black" {
-		if game.PlayerBlack == "" {
+	switch {
+	case game.PlayerWhite == "" && game.PlayerBlack == "":
+		if game.Color == "black" || (game.Color == "random" && rand.Intn(2) == 0) {
 			game.PlayerBlack = username
-		} else if game.PlayerWhite == "" && game.PlayerBlack != username {
+		} else {
 			game.PlayerWhite = username
 		}
+	case game.PlayerWhite == "":
+		game.PlayerWhite = username
+	case game.PlayerBlack == "":
+		game.PlayerBlack = username
 	}
 }
 
 func startGame(game *ChessGame) {
+	setupGame(game)
+	armClock(game)
+
+	for _, absence := range markAbsent(game, time.Now()) {
+		broadcast(game, absence)
+	}
+}
+
+// setupGame puts the pieces and a running clock on the board without
+// arming the flag timer.
+func setupGame(game *ChessGame) {
 
 	nowTime := time.Now()
-	playerTime := nowTime.Add(time.Duration(game.InitialTime) * time.Minute)
 
-	fen := getFen()
-	FEN, err := chess.FEN(fen)
+	if game.InitialFEN == "" && game.Variant == variant.Chess960 {
+		game.InitialFEN = variant.StartFEN(game.Variant)
+	}
+
+	chessGame, err := variant.NewGame(game.Variant, game.InitialFEN)
 	if err != nil {
 		return
 	}
 
-	chessGame := chess.NewGame(FEN)
 	turn := chessGame.Position().Turn().String()
 
-	game.PlayerWhiteTime = playerTime
-	game.PlayerBlackTime = playerTime
-	game.LastUpdate = nowTime
+	game.Clock = NewClock(game.InitialTime, game.TimeControl)
+	game.Clock.Start(nowTime)
 	game.CurrentTurn = turn
 	game.GameStarted = true
 
 	game.Game = chessGame
 }
 
-func getFen() string {
-	return "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+// armClock schedules a flag check for the side to move, so a player who
+// never moves still loses on time.
+func armClock(game *ChessGame) {
+	clock := game.Clock
+	clock.stopTimer()
+
+	if !clock.Running {
+		return
+	}
+
+	turn := game.Game.Position().Turn()
+	clock.timer = time.AfterFunc(clock.Remaining(turn, turn, time.Now()), func() {
+		game.Do(func() { checkFlag(game) })
+	})
+}
+
+// checkFlag runs when the timer armed by armClock fires. The game may have
+// moved on since, so the flag is checked again.
+func checkFlag(game *ChessGame) {
+	now := time.Now()
+	turn := game.Game.Position().Turn()
+	if game.Outcome != "" || !game.Clock.Flagged(turn, now) {
+		return
+	}
+
+	flagFall(game, turn, now)
+	broadcastDelayed(game, newClockMessage(game))
+	broadcastDelayed(game, newGameOverMessage(game))
+}
+
+func flagFall(game *ChessGame, color chess.Color, now time.Time) {
+	game.Clock.Stop(now, color)
+	game.Clock.set(color, 0)
+
+	if !game.Game.CanWin(color.Other()) {
+		endGame(game, chess.Draw, "timeout_vs_insufficient_material")
+		return
+	}
+
+	endGame(game, winner(color.Other()), "timeout")
+}
+
+// newMoveMadeMessage describes the last move played in game.
+func newMoveMadeMessage(game *ChessGame) *protocol.MoveMade {
+	last := game.Moves[len(game.Moves)-1]
+
+	return &protocol.MoveMade{
+		Version: game.Version,
+		Ply:     last.Ply,
+		SAN:     last.SAN,
+		UCI:     last.UCI,
+		FEN:     last.FEN,
+		Turn:    game.Game.Position().Turn().String(),
+	}
 }
 
-func isGameOver(game chess.Game) (string, error) {
+func newClockMessage(game *ChessGame) *protocol.Clock {
+	now := time.Now()
+	turn := game.Game.Position().Turn()
 
-	fmt.Println(game.Outcome())
-	fmt.Println(game.Method())
+	return &protocol.Clock{
+		WhiteTime: game.Clock.Remaining(chess.White, turn, now).Milliseconds(),
+		BlackTime: game.Clock.Remaining(chess.Black, turn, now).Milliseconds(),
+		Turn:      turn.String(),
+		Running:   game.Clock.Running,
+	}
+}
 
-	return "", nil
+// startFEN returns the position game starts from, before the board is set up.
+func startFEN(game *ChessGame) string {
+	if game.InitialFEN != "" {
+		return game.InitialFEN
+	}
+	return variant.StandardFEN
 }
@@ -0,0 +1,44 @@
+package app
+
+import "testing"
+
+func TestAssignPlayer(t *testing.T) {
+	colors := []struct {
+		Name    string
+		Color   string
+		Creator string
+	}{
+		{Name: "White", Color: "white", Creator: "white"},
+		{Name: "Black", Color: "black", Creator: "black"},
+		{Name: "Random", Color: "random"},
+	}
+
+	for _, tc := range colors {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			seats := map[string]int{}
+
+			for i := 0; i < 200; i++ {
+				game := &ChessGame{Color: tc.Color}
+				assignPlayer(game, "alice")
+				assignPlayer(game, "alice")
+				assignPlayer(game, "bob")
+
+				if game.PlayerWhite == game.PlayerBlack || game.PlayerWhite == "" || game.PlayerBlack == "" {
+					t.Fatalf("expected both players seated, got %q and %q", game.PlayerWhite, game.PlayerBlack)
+				}
+
+				seats[colorName(playerColor(game, "alice"))]++
+			}
+
+			if tc.Creator != "" && seats[tc.Creator] != 200 {
+				t.Errorf("expected creator to always play %s, got %v", tc.Creator, seats)
+			}
+
+			// Both colours turn up in 200 fair tosses all but certainly
+			if tc.Creator == "" && (seats["white"] == 0 || seats["black"] == 0) {
+				t.Errorf("expected creator to play both colours, got %v", seats)
+			}
+		})
+	}
+}
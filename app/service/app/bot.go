@@ -0,0 +1,281 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/engine"
+	"ChessApp/protocol"
+	"ChessApp/uci"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// Bot is an engine playing one seat of a game. It only ever moves through
+// playMove, like the players do.
+type Bot struct {
+	Name     string
+	Level    int
+	engine   Engine
+	thinking bool
+}
+
+// Engine finds the bot's moves and reviews finished games: a UCI engine
+// process, or the built-in engine when none can be started.
+type Engine interface {
+	BestMove(fen string, moves []string, limits uci.Limits, timeout time.Duration) (string, error)
+	Analyse(fen string, moves []string, limits uci.Limits, timeout time.Duration) (uci.Analysis, error)
+	Close() error
+}
+
+// botStrength is how a level weakens the engine: the Skill Level or
+// UCI_Elo option if the engine has one, and a depth and time limit always.
+type botStrength struct {
+	skill    int
+	elo      int
+	depth    int
+	moveTime time.Duration
+}
+
+var botLevels = []botStrength{
+	{skill: 0, elo: 1350, depth: 1, moveTime: 50 * time.Millisecond},
+	{skill: 3, elo: 1500, depth: 2, moveTime: 100 * time.Millisecond},
+	{skill: 6, elo: 1650, depth: 3, moveTime: 150 * time.Millisecond},
+	{skill: 9, elo: 1800, depth: 4, moveTime: 200 * time.Millisecond},
+	{skill: 11, elo: 1950, depth: 6, moveTime: 300 * time.Millisecond},
+	{skill: 14, elo: 2100, depth: 8, moveTime: 400 * time.Millisecond},
+	{skill: 17, elo: 2300, depth: 12, moveTime: 500 * time.Millisecond},
+	{skill: 20, elo: 2600, depth: 18, moveTime: time.Second},
+}
+
+// StartEngine starts the engine process for a new bot or analysis, or the
+// built-in engine if ENGINE_PATH is empty or the process does not start.
+// Tests swap it for the stub engine.
+var StartEngine = func() (Engine, error) {
+	if config.Envs.EnginePath == "" {
+		return engine.New(), nil
+	}
+
+	process, err := uci.Start(config.Envs.EnginePath)
+	if err != nil {
+		log.Printf("falling back to the built-in engine: %v", err)
+		return engine.New(), nil
+	}
+	return process, nil
+}
+
+func botName(level int) string {
+	return fmt.Sprintf("Bot level %d", level)
+}
+
+// newBot starts an engine and sets it up to play at level.
+func newBot(level int) (*Bot, error) {
+	if level < 1 || level > len(botLevels) {
+		return nil, fmt.Errorf("bot level must be between 1 and %d", len(botLevels))
+	}
+	strength := botLevels[level-1]
+
+	player, err := StartEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	// The built-in engine is held back by the depth and time limits alone
+	if process, ok := player.(*uci.Engine); ok {
+		err = setStrength(process, strength)
+	}
+	if err != nil {
+		player.Close()
+		return nil, err
+	}
+
+	return &Bot{Name: botName(level), Level: level, engine: player}, nil
+}
+
+// setStrength sets the skill options a UCI engine has and starts a new game.
+func setStrength(process *uci.Engine, strength botStrength) error {
+	var err error
+	switch {
+	case process.HasOption("Skill Level"):
+		err = process.SetOption("Skill Level", strconv.Itoa(strength.skill))
+	case process.HasOption("UCI_Elo"):
+		option := process.Options["UCI_Elo"]
+		elo := max(option.Min, min(option.Max, strength.elo))
+		if process.HasOption("UCI_LimitStrength") {
+			err = process.SetOption("UCI_LimitStrength", "true")
+		}
+		if err == nil {
+			err = process.SetOption("UCI_Elo", strconv.Itoa(elo))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return process.NewGame()
+}
+
+// StartBot seats the bot of the game with the given id, which starts the
+// game, and lets it move if it plays white. The engine is started here so
+// the game's goroutine never waits for it.
+func (r *Registry) StartBot(id string) error {
+	game, ok := r.Get(id)
+	if !ok {
+		return fmt.Errorf("game does not exist")
+	}
+
+	var level int
+	if !game.Do(func() { level = game.BotLevel }) {
+		return fmt.Errorf("game does not exist")
+	}
+
+	bot, err := newBot(level)
+	if err != nil {
+		return err
+	}
+
+	if !game.Do(func() { err = seatBot(game, bot) }) {
+		err = fmt.Errorf("game does not exist")
+	}
+	if err != nil {
+		go bot.engine.Close()
+	}
+	return err
+}
+
+func seatBot(game *ChessGame, bot *Bot) error {
+	if game.Outcome != "" {
+		return fmt.Errorf("game is over")
+	}
+
+	game.bot = bot
+	if game.PlayerWhite != bot.Name && game.PlayerBlack != bot.Name {
+		if err := JoinGame(game, bot.Name); err != nil {
+			game.bot = nil
+			return err
+		}
+	}
+
+	botTurn(game)
+	return nil
+}
+
+func botColor(game *ChessGame) chess.Color {
+	switch {
+	case game.bot == nil:
+		return chess.NoColor
+	case game.bot.Name == game.PlayerWhite:
+		return chess.White
+	case game.bot.Name == game.PlayerBlack:
+		return chess.Black
+	default:
+		return chess.NoColor
+	}
+}
+
+// botTurn starts a search if the bot is to move. The engine thinks off the
+// game's goroutine, and the move it finds is dropped if the game has changed
+// in the meantime, by a takeback or the clock. The version tells, as a
+// takeback followed by a new move leaves as many moves as before.
+func botTurn(game *ChessGame) {
+	bot := game.bot
+	if bot == nil || bot.thinking || game.Outcome != "" || !game.GameStarted {
+		return
+	}
+
+	color := game.Game.Position().Turn()
+	if color != botColor(game) {
+		return
+	}
+
+	now := time.Now()
+	strength := botLevels[bot.Level-1]
+	remaining := game.Clock.Remaining(color, color, now)
+	increment := time.Duration(game.TimeControl) * time.Second
+
+	// Never spend more than a small share of the clock on one move
+	limits := uci.Limits{
+		WhiteTime: game.Clock.Remaining(chess.White, color, now),
+		BlackTime: game.Clock.Remaining(chess.Black, color, now),
+		WhiteInc:  increment,
+		BlackInc:  increment,
+		MoveTime:  max(10*time.Millisecond, min(strength.moveTime, remaining/20+increment/2)),
+		Depth:     strength.depth,
+	}
+
+	moves := []string{}
+	for _, move := range game.Moves {
+		moves = append(moves, move.UCI)
+	}
+
+	fen := game.InitialFEN
+	version := game.Version
+	bot.thinking = true
+
+	go func() {
+		move, err := bot.engine.BestMove(fen, moves, limits, remaining+time.Second)
+
+		game.Do(func() {
+			bot.thinking = false
+			if game.Outcome != "" || game.Version != version {
+				botTurn(game)
+				return
+			}
+
+			if err == nil {
+				err = playMove(game, move)
+			}
+			if err != nil {
+				log.Printf("bot in game %s failed to move: %v", game.ID, err)
+				botResign(game, color)
+			}
+		})
+	}()
+}
+
+// botAnswer replies to the offers just made to the bot. It lets players take
+// moves back, but plays on rather than agree to a draw.
+func botAnswer(game *ChessGame) []protocol.Message {
+	color := botColor(game)
+	if color == chess.NoColor {
+		return nil
+	}
+
+	now := time.Now()
+	messages := []protocol.Message{}
+
+	if game.TakebackOffer == color.Other() {
+		if replies, err := acceptTakeback(game, color, now); err == nil {
+			messages = append(messages, replies...)
+		}
+	}
+	if game.DrawOffer == color.Other() {
+		if replies, err := declineDraw(game, color, now); err == nil {
+			messages = append(messages, replies...)
+		}
+	}
+
+	return messages
+}
+
+// botResign gives up a game the engine cannot carry on with.
+func botResign(game *ChessGame, color chess.Color) {
+	messages, err := resign(game, color, time.Now())
+	if err != nil {
+		return
+	}
+
+	for _, message := range messages {
+		broadcastDelayed(game, message)
+	}
+}
+
+// stopBot shuts the engine down once the game is over. A search still
+// running ends with an error that botTurn ignores.
+func stopBot(game *ChessGame) {
+	if game.bot == nil {
+		return
+	}
+	go game.bot.engine.Close()
+}
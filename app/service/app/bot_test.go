@@ -0,0 +1,270 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/engine"
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"ChessApp/uci"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/notnil/chess"
+)
+
+// TestMain lets the test binary double as the bots' engine: started again
+// with UCI_STUB_ENGINE set, it runs the stub engine instead of the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("UCI_STUB_ENGINE") != "" {
+		uci.Stub(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+
+	os.Setenv("UCI_STUB_ENGINE", "1")
+	StartEngine = func() (Engine, error) {
+		return uci.Start(os.Args[0])
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestCreateBotGame(t *testing.T) {
+	handler := NewHandler(&mockChessApp{}, &mockUserApp{}, nil, nil)
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), "1", "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot_payloads := []struct {
+		Name     string
+		Payload  types.NewGamePayload
+		Expected int
+		Moves    int
+	}{
+		{
+			Name:     "Player Moves First",
+			Payload:  types.NewGamePayload{GameMode: "vs_bot", Color: "white", InitialTime: 5, TimeControl: 3, Level: 1, Rated: true},
+			Expected: http.StatusCreated,
+		},
+		{
+			Name:     "Bot Moves First",
+			Payload:  types.NewGamePayload{GameMode: "vs_bot", Color: "black", InitialTime: 5, TimeControl: 3, Level: 8},
+			Expected: http.StatusCreated,
+			Moves:    1,
+		},
+		{
+			Name:     "Missing Level",
+			Payload:  types.NewGamePayload{GameMode: "vs_bot", Color: "white", InitialTime: 5, TimeControl: 3},
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Name:     "Unknown Level",
+			Payload:  types.NewGamePayload{GameMode: "vs_bot", Color: "white", InitialTime: 5, TimeControl: 3, Level: 9},
+			Expected: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range bot_payloads {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			marshalled, _ := json.Marshal(tc.Payload)
+
+			req, err := http.NewRequest(http.MethodPost, "/create", bytes.NewBuffer(marshalled))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+			router.Use(auth.Middleware(&mockUserApp{}))
+			auth.SetAccess(router.HandleFunc("/create", handler.createGame), auth.Authenticated)
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.Expected {
+				t.Fatalf("expected status code %d, got %d: %s", tc.Expected, rr.Code, rr.Body)
+			}
+			if rr.Code != http.StatusCreated {
+				return
+			}
+
+			var response types.CreateGameResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			if response.Rated || response.Variant != "standard" {
+				t.Errorf("expected a casual standard game, got %+v", response)
+			}
+
+			game, _ := GameStore.Get(response.ID)
+			defer game.Do(func() { endGame(game, chess.NoOutcome, "aborted") })
+
+			waitForMoves(t, game, tc.Moves)
+
+			game.Do(func() {
+				if !game.GameStarted || botColor(game) == playerColor(game, "testuser") {
+					t.Errorf("expected the bot to take the other seat, got %s vs %s", game.PlayerWhite, game.PlayerBlack)
+				}
+				if playerColor(game, game.bot.Name) != chess.NoColor {
+					t.Error("expected nobody to play for the bot")
+				}
+			})
+		})
+	}
+}
+
+func TestBotReplies(t *testing.T) {
+	game := &ChessGame{ID: "bot", Color: "white", InitialTime: 5, BotLevel: 3}
+	GameStore.Add(game)
+	game.Do(func() { JoinGame(game, "alice") })
+
+	if err := GameStore.StartBot(game.ID); err != nil {
+		t.Fatal(err)
+	}
+	defer game.Do(func() { endGame(game, chess.NoOutcome, "aborted") })
+
+	game.Do(func() {
+		if err := playMove(game, "e4"); err != nil {
+			t.Error(err)
+		}
+	})
+	waitForMoves(t, game, 2)
+
+	handler := NewHandler(&mockChessApp{}, &mockUserApp{}, nil, nil)
+	game.Do(func() {
+		if err := handler.handleMove(game, game.bot.Name, "d4"); err == nil {
+			t.Error("expected a user named like the bot to be turned away")
+		}
+	})
+
+	game.Do(func() {
+		if _, err := requestTakeback(game, chess.White, time.Now()); err != nil {
+			t.Error(err)
+			return
+		}
+		botAnswer(game)
+
+		if len(game.Moves) != 0 || game.TakebackOffer != chess.NoColor {
+			t.Errorf("expected the bot to accept the takeback, got %d moves", len(game.Moves))
+		}
+	})
+
+	game.Do(func() {
+		offerDraw(game, chess.White, time.Now())
+		botAnswer(game)
+
+		if game.DrawOffer != chess.NoColor || game.Outcome != "" {
+			t.Errorf("expected the bot to decline the draw, got %q", game.Outcome)
+		}
+	})
+}
+
+func TestBuiltinEngineBot(t *testing.T) {
+	start := StartEngine
+	StartEngine = func() (Engine, error) { return engine.New(), nil }
+	defer func() { StartEngine = start }()
+
+	game := &ChessGame{ID: "builtin", Color: "white", InitialTime: 5, BotLevel: 2}
+	GameStore.Add(game)
+	game.Do(func() { JoinGame(game, "alice") })
+
+	if err := GameStore.StartBot(game.ID); err != nil {
+		t.Fatal(err)
+	}
+	defer game.Do(func() { endGame(game, chess.NoOutcome, "aborted") })
+
+	game.Do(func() {
+		if err := playMove(game, "e4"); err != nil {
+			t.Error(err)
+		}
+	})
+	waitForMoves(t, game, 2)
+}
+
+func TestBotDropsStaleMove(t *testing.T) {
+	engine := &gatedEngine{
+		release: make(chan struct{}),
+		replies: map[string]string{"e2e4": "e7e5", "d2d4": "d7d5"},
+	}
+
+	game := &ChessGame{ID: "stale", Color: "white", InitialTime: 5, BotLevel: 1}
+	GameStore.Add(game)
+	defer game.Do(func() { endGame(game, chess.NoOutcome, "aborted") })
+
+	game.Do(func() {
+		JoinGame(game, "alice")
+		if err := seatBot(game, &Bot{Name: botName(1), Level: 1, engine: engine}); err != nil {
+			t.Error(err)
+		}
+		if err := playMove(game, "e2e4"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// Take e4 back and play d4 while the bot still thinks about e4
+	game.Do(func() {
+		if _, err := requestTakeback(game, chess.White, time.Now()); err != nil {
+			t.Error(err)
+		}
+		botAnswer(game)
+		if err := playMove(game, "d2d4"); err != nil {
+			t.Error(err)
+		}
+	})
+	close(engine.release)
+
+	waitForMoves(t, game, 2)
+
+	game.Do(func() {
+		if game.Outcome != "" || game.Moves[1].UCI != "d7d5" {
+			t.Errorf("expected the bot to answer d4 with d5, got %s with outcome %q", game.Moves[1].UCI, game.Outcome)
+		}
+	})
+}
+
+// waitForMoves waits for the bot to bring game to the given number of moves.
+func waitForMoves(t *testing.T, game *ChessGame, moves int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var played int
+		game.Do(func() { played = len(game.Moves) })
+
+		if played == moves {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d moves, got %d", moves, played)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// gatedEngine answers the last move with its reply once release is closed.
+type gatedEngine struct {
+	release chan struct{}
+	replies map[string]string
+}
+
+func (e *gatedEngine) BestMove(fen string, moves []string, limits uci.Limits, timeout time.Duration) (string, error) {
+	<-e.release
+	return e.replies[moves[len(moves)-1]], nil
+}
+
+func (e *gatedEngine) Analyse(fen string, moves []string, limits uci.Limits, timeout time.Duration) (uci.Analysis, error) {
+	return uci.Analysis{}, fmt.Errorf("not implemented")
+}
+
+func (e *gatedEngine) Close() error {
+	return nil
+}
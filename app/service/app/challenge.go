@@ -0,0 +1,151 @@
+package app
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/service/rating"
+	"ChessApp/types"
+	"sort"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// openChallenge lists a freshly created game in the lobby. It expires after
+// ttl unless someone joins it first.
+func openChallenge(game *ChessGame, lobby types.Lobby, creatorRating int, ttl time.Duration) {
+	game.Open = true
+	game.CreatorRating = creatorRating
+	game.ExpiresAt = time.Now().Add(ttl)
+	game.lobby = lobby
+	game.expiry = time.AfterFunc(ttl, func() {
+		game.Do(func() { expireChallenge(game) })
+	})
+
+	notifyLobby(game, &protocol.ChallengeCreated{Game: newOpenGame(game)})
+}
+
+// creatorRating is the rating shown next to a challenge, in the category of
+// its time control and variant.
+func creatorRating(ratingApp types.RatingApp, username, variantName string, initialTime, timeControl int) (int, error) {
+	if ratingApp == nil {
+		return int(rating.DefaultRating), nil
+	}
+
+	r, err := ratingApp.GetRating(username, rating.Category(variantName, initialTime, timeControl))
+	if err != nil {
+		return 0, err
+	}
+	return int(r.Rating), nil
+}
+
+// closeChallenge takes game off the lobby list, announcing why with event.
+func closeChallenge(game *ChessGame, event protocol.Message) {
+	if !game.Open {
+		return
+	}
+
+	game.Open = false
+	if game.expiry != nil {
+		game.expiry.Stop()
+		game.expiry = nil
+	}
+
+	notifyLobby(game, event)
+}
+
+func expireChallenge(game *ChessGame) {
+	if !game.Open || game.GameStarted {
+		return
+	}
+
+	closeChallenge(game, &protocol.ChallengeExpired{GameID: game.ID, Reason: "timeout"})
+	endGame(game, chess.NoOutcome, "expired")
+	broadcast(game, newGameOverMessage(game))
+}
+
+func notifyLobby(game *ChessGame, message protocol.Message) {
+	if game.lobby == nil {
+		return
+	}
+	game.lobby.Broadcast(protocol.NewEnvelope("", message))
+}
+
+func newOpenGame(game *ChessGame) types.OpenGame {
+	creator := game.PlayerWhite
+	if creator == "" {
+		creator = game.PlayerBlack
+	}
+
+	return types.OpenGame{
+		ID:          game.ID,
+		Creator:     creator,
+		Rating:      game.CreatorRating,
+		InitialTime: game.InitialTime,
+		TimeControl: game.TimeControl,
+		TimeClass:   rating.TimeClass(game.InitialTime, game.TimeControl),
+		Color:       game.Color,
+		Rated:       game.Rated,
+		Variant:     game.Variant,
+		CreatedAt:   game.CreatedAt,
+		ExpiresAt:   game.ExpiresAt,
+	}
+}
+
+// openGames collects the open challenges of every live game.
+func openGames(registry *Registry) []types.OpenGame {
+	games := []types.OpenGame{}
+
+	for _, game := range registry.List() {
+		game.Do(func() {
+			if game.Open {
+				games = append(games, newOpenGame(game))
+			}
+		})
+	}
+
+	return games
+}
+
+// filterOpenGames applies query to games, newest first, and returns the
+// requested page together with the number of matches.
+func filterOpenGames(games []types.OpenGame, query types.OpenGamesQuery) ([]types.OpenGame, int) {
+	matches := []types.OpenGame{}
+
+	for _, game := range games {
+		if query.TimeClass != "" && game.TimeClass != query.TimeClass {
+			continue
+		}
+		if query.Variant != "" && game.Variant != query.Variant {
+			continue
+		}
+		if query.Rated != nil && game.Rated != *query.Rated {
+			continue
+		}
+		if query.MinRating > 0 && game.Rating < query.MinRating {
+			continue
+		}
+		if query.MaxRating > 0 && game.Rating > query.MaxRating {
+			continue
+		}
+		matches = append(matches, game)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].ID < matches[j].ID
+		}
+		return matches[i].CreatedAt.After(matches[j].CreatedAt)
+	})
+
+	total := len(matches)
+	if query.Offset >= total {
+		return []types.OpenGame{}, total
+	}
+
+	end := query.Offset + query.Limit
+	if end > total {
+		end = total
+	}
+
+	return matches[query.Offset:end], total
+}
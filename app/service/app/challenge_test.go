@@ -0,0 +1,210 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/protocol"
+	"ChessApp/types"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFilterOpenGames(t *testing.T) {
+	now := time.Now()
+	rated := true
+
+	games := []types.OpenGame{
+		{ID: "a", TimeClass: "blitz", Rating: 1500, Rated: true, CreatedAt: now.Add(-3 * time.Minute)},
+		{ID: "b", TimeClass: "blitz", Rating: 1800, Rated: false, CreatedAt: now.Add(-2 * time.Minute)},
+		{ID: "c", TimeClass: "rapid", Rating: 1200, Rated: true, CreatedAt: now.Add(-1 * time.Minute)},
+	}
+
+	queries := []struct {
+		Name     string
+		Query    types.OpenGamesQuery
+		Expected []string
+		Total    int
+	}{
+		{
+			Name:     "Newest First",
+			Query:    types.OpenGamesQuery{Limit: 20},
+			Expected: []string{"c", "b", "a"},
+			Total:    3,
+		},
+		{
+			Name:     "Time Class",
+			Query:    types.OpenGamesQuery{TimeClass: "blitz", Limit: 20},
+			Expected: []string{"b", "a"},
+			Total:    2,
+		},
+		{
+			Name:     "Rated And Rating Range",
+			Query:    types.OpenGamesQuery{Rated: &rated, MinRating: 1300, MaxRating: 1600, Limit: 20},
+			Expected: []string{"a"},
+			Total:    1,
+		},
+		{
+			Name:     "Second Page",
+			Query:    types.OpenGamesQuery{Limit: 2, Offset: 2},
+			Expected: []string{"a"},
+			Total:    3,
+		},
+		{
+			Name:     "Past The End",
+			Query:    types.OpenGamesQuery{Limit: 2, Offset: 5},
+			Expected: []string{},
+			Total:    3,
+		},
+	}
+
+	for _, tc := range queries {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			page, total := filterOpenGames(games, tc.Query)
+			if total != tc.Total || len(page) != len(tc.Expected) {
+				t.Fatalf("expected %v of %d, got %+v of %d", tc.Expected, tc.Total, page, total)
+			}
+
+			for i, game := range page {
+				if game.ID != tc.Expected[i] {
+					t.Errorf("expected %s at %d, got %s", tc.Expected[i], i, game.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestChallengeLifecycle(t *testing.T) {
+	challenges := []struct {
+		Name     string
+		Opponent string
+		Expected []string
+		Outcome  string
+		Method   string
+	}{
+		{
+			Name:     "Joined",
+			Opponent: "bob",
+			Expected: []string{protocol.TypeChallengeCreated, protocol.TypeChallengeJoined},
+		},
+		{
+			Name:     "Expired",
+			Expected: []string{protocol.TypeChallengeCreated, protocol.TypeChallengeExpired},
+			Outcome:  "*",
+			Method:   "expired",
+		},
+	}
+
+	for _, tc := range challenges {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			lobby := &mockLobby{}
+			game := &ChessGame{ID: "challenge" + tc.Name, Color: "white", InitialTime: 5}
+			GameStore.Add(game)
+
+			game.Do(func() {
+				JoinGame(game, "alice")
+				openChallenge(game, lobby, 1500, 50*time.Millisecond)
+			})
+
+			if tc.Opponent != "" {
+				if _, err := GameStore.Join(game.ID, tc.Opponent); err != nil {
+					t.Fatal(err)
+				}
+				defer game.Do(func() { game.Clock.stopTimer() })
+			}
+
+			time.Sleep(100 * time.Millisecond)
+
+			if events := lobby.types(); len(events) != len(tc.Expected) || events[0] != tc.Expected[0] || events[1] != tc.Expected[1] {
+				t.Errorf("expected lobby events %v, got %v", tc.Expected, events)
+			}
+
+			// A retired game has no goroutine left to ask
+			var outcome, method string
+			if !game.Do(func() { outcome, method = game.Outcome, game.Method }) {
+				outcome, method = game.Outcome, game.Method
+			}
+
+			if outcome != tc.Outcome || method != tc.Method {
+				t.Errorf("expected %q by %q, got %q by %q", tc.Outcome, tc.Method, outcome, method)
+			}
+
+			if _, listed := GameStore.Get(game.ID); listed != (tc.Outcome == "") {
+				t.Errorf("expected game to be listed %v", tc.Outcome == "")
+			}
+		})
+	}
+}
+
+func TestReopenChallenges(t *testing.T) {
+	ttl := time.Duration(config.Envs.ChallengeExpirationInSeconds) * time.Second
+	created := time.Now().Add(-time.Minute)
+
+	app := &mockChessApp{games: map[string]types.Game{
+		"reopened": {ID: "reopened", PlayerWhite: "alice", Color: "white", InitialTime: 5, Status: types.GameStatusWaiting, CreatedAt: created},
+		"stale":    {ID: "stale", PlayerWhite: "alice", Color: "white", InitialTime: 5, Status: types.GameStatusWaiting, CreatedAt: time.Now().Add(-2 * ttl)},
+	}}
+
+	lobby := &mockLobby{}
+	if err := LoadGames(app, nil, lobby); err != nil {
+		t.Fatal(err)
+	}
+
+	game, ok := GameStore.Get("reopened")
+	if !ok {
+		t.Fatal("expected the challenge to be restored")
+	}
+	defer game.Do(func() { game.expiry.Stop() })
+
+	var open []types.OpenGame
+	for _, g := range openGames(GameStore) {
+		if g.ID == "reopened" || g.ID == "stale" {
+			open = append(open, g)
+		}
+	}
+	if len(open) != 1 || open[0].ID != "reopened" || open[0].Creator != "alice" {
+		t.Fatalf("expected only the fresh challenge to be open, got %+v", open)
+	}
+	if !open[0].ExpiresAt.Round(time.Second).Equal(created.Add(ttl).Round(time.Second)) {
+		t.Errorf("expected the challenge to expire at %v, got %v", created.Add(ttl), open[0].ExpiresAt)
+	}
+
+	stale, ok := GameStore.Get("stale")
+	if ok {
+		var outcome, method string
+		if !stale.Do(func() { outcome, method = stale.Outcome, stale.Method }) {
+			outcome, method = stale.Outcome, stale.Method
+		}
+		if outcome != "*" || method != "expired" {
+			t.Errorf("expected the stale challenge to expire, got %q by %q", outcome, method)
+		}
+	}
+}
+
+type mockLobby struct {
+	messages []any
+	mu       sync.Mutex
+}
+
+func (m *mockLobby) Broadcast(message any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, message)
+}
+
+func (m *mockLobby) SendToUser(username string, message any) {
+	m.Broadcast(message)
+}
+
+func (m *mockLobby) types() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	types := []string{}
+	for _, message := range m.messages {
+		types = append(types, message.(protocol.Envelope).Type)
+	}
+	return types
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+var errOutOfTime = fmt.Errorf("out of time")
+
+type Clock struct {
+	White      time.Duration
+	Black      time.Duration
+	Increment  time.Duration
+	LastUpdate time.Time
+	Running    bool
+
+	timer *time.Timer
+}
+
+// NewClock takes the initial time in minutes and the increment in seconds.
+func NewClock(initialTime, increment int) *Clock {
+	return &Clock{
+		White:     time.Duration(initialTime) * time.Minute,
+		Black:     time.Duration(initialTime) * time.Minute,
+		Increment: time.Duration(increment) * time.Second,
+	}
+}
+
+func (c *Clock) Start(now time.Time) {
+	c.LastUpdate = now
+	c.Running = true
+}
+
+func (c *Clock) Stop(now time.Time, turn chess.Color) {
+	if c.Running {
+		c.set(turn, c.Remaining(turn, turn, now))
+		c.LastUpdate = now
+	}
+	c.Running = false
+	c.stopTimer()
+}
+
+// Remaining returns the time left for color, counting the time spent
+// thinking if it is their turn.
+func (c *Clock) Remaining(color, turn chess.Color, now time.Time) time.Duration {
+	left := c.get(color)
+	if !c.Running || color != turn {
+		return left
+	}
+
+	left -= now.Sub(c.LastUpdate)
+	if left < 0 {
+		return 0
+	}
+	return left
+}
+
+// Punch deducts the thinking time of the side that just moved and adds the
+// increment. It returns false if the side ran out of time before moving.
+func (c *Clock) Punch(color chess.Color, now time.Time) bool {
+	left := c.Remaining(color, color, now)
+	c.LastUpdate = now
+
+	if left <= 0 {
+		c.set(color, 0)
+		return false
+	}
+
+	c.set(color, left+c.Increment)
+	return true
+}
+
+func (c *Clock) Flagged(color chess.Color, now time.Time) bool {
+	return c.Running && c.Remaining(color, color, now) <= 0
+}
+
+func (c *Clock) get(color chess.Color) time.Duration {
+	if color == chess.White {
+		return c.White
+	}
+	return c.Black
+}
+
+func (c *Clock) set(color chess.Color, d time.Duration) {
+	if color == chess.White {
+		c.White = d
+	} else {
+		c.Black = d
+	}
+}
+
+func (c *Clock) stopTimer() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func TestClockPunch(t *testing.T) {
+	start := time.Now()
+
+	clock := NewClock(1, 2)
+	clock.Start(start)
+
+	if !clock.Punch(chess.White, start.Add(10*time.Second)) {
+		t.Fatal("expected white to still have time")
+	}
+
+	expected := 52 * time.Second
+	if clock.White != expected {
+		t.Errorf("expected white to have %v, got %v", expected, clock.White)
+	}
+
+	if clock.Punch(chess.Black, start.Add(80*time.Second)) {
+		t.Error("expected black to have flagged")
+	}
+
+	if clock.Black != 0 {
+		t.Errorf("expected black to have 0, got %v", clock.Black)
+	}
+}
+
+func TestFlagFall(t *testing.T) {
+	flags := []struct {
+		Name    string
+		FEN     string
+		Timer   bool
+		Outcome string
+		Method  string
+	}{
+		{Name: "Never Moves", Timer: true, Outcome: "0-1", Method: "timeout"},
+		{Name: "Moves Too Late", Outcome: "0-1", Method: "timeout"},
+		{Name: "Opponent Cannot Mate", FEN: "4k3/8/8/8/8/8/8/4K2R w K - 0 1", Timer: true, Outcome: "1/2-1/2", Method: "timeout_vs_insufficient_material"},
+	}
+
+	for _, tc := range flags {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			game := &ChessGame{ID: "flag" + tc.Name, Color: "white", InitialTime: 5, InitialFEN: tc.FEN}
+			GameStore.Add(game)
+
+			game.Do(func() {
+				JoinGame(game, "alice")
+				JoinGame(game, "bob")
+
+				game.Clock.White = 20 * time.Millisecond
+				game.Clock.LastUpdate = time.Now()
+				if tc.Timer {
+					armClock(game)
+				} else {
+					game.Clock.stopTimer()
+				}
+			})
+
+			if !tc.Timer {
+				time.Sleep(30 * time.Millisecond)
+				game.Do(func() {
+					if _, err := MakeMove(game, "e4"); err != errOutOfTime {
+						t.Errorf("expected the move to come too late, got %v", err)
+					}
+				})
+			}
+
+			deadline := time.Now().Add(time.Second)
+			for {
+				// A retired game has no goroutine left to ask
+				var outcome, method string
+				if !game.Do(func() { outcome, method = game.Outcome, game.Method }) {
+					outcome, method = game.Outcome, game.Method
+				}
+
+				if outcome != "" {
+					if outcome != tc.Outcome || method != tc.Method {
+						t.Errorf("expected %s by %s, got %s by %s", tc.Outcome, tc.Method, outcome, method)
+					}
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatal("expected white to lose on time")
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if game.Clock.White != 0 || game.Clock.Running {
+				t.Errorf("expected white's clock to stop at 0, got %v", game.Clock.White)
+			}
+		})
+	}
+}
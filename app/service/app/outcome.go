@@ -0,0 +1,64 @@
+package app
+
+import (
+	"ChessApp/protocol"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// isGameOver ends the game if the last move decided it. Threefold repetition
+// and the fifty-move rule are claimed on behalf of the players.
+func isGameOver(game *ChessGame) bool {
+	chessGame := game.Game
+
+	if chessGame.Outcome() == chess.NoOutcome {
+		draws := chessGame.EligibleDraws()
+		if len(draws) == 0 {
+			return false
+		}
+		chessGame.Draw(draws[0])
+	}
+
+	endGame(game, chessGame.Outcome(), chessGame.Method())
+	return true
+}
+
+// endGame freezes the game with the given result. Every way a game can
+// finish goes through here.
+func endGame(game *ChessGame, outcome chess.Outcome, method string) {
+	if game.Clock != nil {
+		game.Clock.Stop(time.Now(), game.Game.Position().Turn())
+	}
+
+	game.Outcome = outcome.String()
+	game.Method = method
+	game.Version++
+
+	stopBot(game)
+	finishGame(game)
+}
+
+func winner(color chess.Color) chess.Outcome {
+	if color == chess.White {
+		return chess.WhiteWon
+	}
+	return chess.BlackWon
+}
+
+func newGameOverMessage(game *ChessGame) *protocol.GameOver {
+	return &protocol.GameOver{
+		Result: game.Outcome,
+		Method: game.Method,
+		FEN:    currentFEN(game),
+		PGN:    encodePGN(game),
+	}
+}
+
+// currentFEN also covers games aborted before the board was set up.
+func currentFEN(game *ChessGame) string {
+	if game.Game == nil {
+		return startFEN(game)
+	}
+	return game.Game.Position().String()
+}
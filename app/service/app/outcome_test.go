@@ -0,0 +1,67 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestGameOver(t *testing.T) {
+	games := []struct {
+		Name    string
+		Variant string
+		Moves   []string
+		Outcome string
+		Method  string
+	}{
+		{
+			Name:    "Fools Mate",
+			Moves:   []string{"f3", "e5", "g4", "Qh4"},
+			Outcome: "0-1",
+			Method:  "checkmate",
+		},
+		{
+			Name:    "Threefold Repetition",
+			Moves:   []string{"Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1", "Ng8"},
+			Outcome: "1/2-1/2",
+			Method:  "threefold_repetition",
+		},
+		{
+			Name:    "King Of The Hill",
+			Variant: "king_of_the_hill",
+			Moves:   []string{"e3", "e6", "Ke2", "Ke7", "Kd3", "Kd6", "Kd4"},
+			Outcome: "1-0",
+			Method:  "king_in_centre",
+		},
+		{
+			Name:    "Game In Progress",
+			Moves:   []string{"e4", "e5"},
+			Outcome: "",
+			Method:  "",
+		},
+	}
+
+	for _, tc := range games {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			game := &ChessGame{InitialTime: 5, TimeControl: 0, Variant: tc.Variant}
+			startGame(game)
+
+			for _, move := range tc.Moves {
+				if _, err := MakeMove(game, move); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if game.Outcome != tc.Outcome || game.Method != tc.Method {
+				t.Errorf("expected %q by %q, got %q by %q", tc.Outcome, tc.Method, game.Outcome, game.Method)
+			}
+
+			if tc.Outcome != "" {
+				if _, err := MakeMove(game, "a3"); err == nil {
+					t.Error("expected move after game over to be rejected")
+				}
+			}
+
+			game.Clock.stopTimer()
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/types"
+	"ChessApp/variant"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// LoadGames puts every unfinished game back into GameStore so players can
+// reconnect after a restart. Clocks resume from the last saved times; the
+// time the server was down is not charged to anyone. The flag timers are
+// armed once the games run on their own goroutines. Challenges still waiting
+// for an opponent go back on the lobby list until they expire, counted from
+// when they were created. Games that cannot be replayed are aborted.
+func LoadGames(app types.ChessApp, ratingApp types.RatingApp, lobby types.Lobby) error {
+	records, err := app.GetUnfinishedGames()
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		game := &ChessGame{
+			ID:             record.ID,
+			PlayerWhite:    record.PlayerWhite,
+			PlayerBlack:    record.PlayerBlack,
+			Color:          record.Color,
+			InitialTime:    record.InitialTime,
+			TimeControl:    record.TimeControl,
+			Rated:          record.Rated,
+			SpectatorDelay: record.SpectatorDelay,
+			Variant:        record.Variant,
+			InitialFEN:     record.InitialFEN,
+			BotLevel:       record.BotLevel,
+			CreatedAt:      record.CreatedAt,
+			app:            app,
+		}
+
+		if record.Status == types.GameStatusPlaying {
+			moves, err := app.GetMovesByGameID(record.ID)
+			if err != nil {
+				return err
+			}
+
+			if err := restoreGame(game, record, moves); err != nil {
+				log.Printf("failed to restore game %s, aborting it: %v", record.ID, err)
+				abortRecord(app, record)
+				continue
+			}
+		}
+
+		GameStore.Add(game)
+		if game.Clock != nil {
+			game.Do(func() { armClock(game) })
+		}
+
+		if game.BotLevel > 0 {
+			if err := GameStore.StartBot(game.ID); err != nil {
+				log.Printf("failed to restart bot for game %s: %v", record.ID, err)
+			}
+		} else if record.Status == types.GameStatusWaiting {
+			reopenChallenge(game, ratingApp, lobby)
+		}
+	}
+
+	log.Printf("Loaded %d unfinished games", len(records))
+	return nil
+}
+
+// abortRecord ends a stored game that could not be restored so that it is
+// not loaded again on every restart.
+func abortRecord(app types.ChessApp, record types.Game) {
+	record.Status = types.GameStatusFinished
+	record.Outcome = chess.NoOutcome.String()
+	record.Method = "aborted"
+	record.UpdatedAt = time.Now()
+
+	if err := app.FinishGame(record); err != nil {
+		log.Printf("failed to abort game %s: %v", record.ID, err)
+	}
+}
+
+func reopenChallenge(game *ChessGame, ratingApp types.RatingApp, lobby types.Lobby) {
+	ttl := time.Duration(config.Envs.ChallengeExpirationInSeconds) * time.Second
+	ttl = time.Until(game.CreatedAt.Add(ttl))
+	if ttl <= 0 {
+		game.Do(func() { endGame(game, chess.NoOutcome, "expired") })
+		return
+	}
+
+	creator := game.PlayerWhite
+	if creator == "" {
+		creator = game.PlayerBlack
+	}
+
+	creatorRating, err := creatorRating(ratingApp, creator, game.Variant, game.InitialTime, game.TimeControl)
+	if err != nil {
+		log.Printf("failed to get creator rating for game %s: %v", game.ID, err)
+	}
+
+	game.Do(func() { openChallenge(game, lobby, creatorRating, ttl) })
+}
+
+func restoreGame(game *ChessGame, record types.Game, moves []types.Move) error {
+	setupGame(game)
+	if game.Game == nil {
+		return fmt.Errorf("invalid start position %q", game.InitialFEN)
+	}
+
+	if err := replayMoves(game.Game, moves); err != nil {
+		return err
+	}
+
+	game.Moves = moves
+	// Games saved before the version was stored count their moves only
+	game.Version = max(record.Version, len(moves))
+	game.Clock.White = time.Duration(record.WhiteTime) * time.Millisecond
+	game.Clock.Black = time.Duration(record.BlackTime) * time.Millisecond
+	game.Clock.Start(time.Now())
+	game.CurrentTurn = game.Game.Position().Turn().String()
+
+	// Nobody is connected after a restart until they open a socket again
+	markAbsent(game, time.Now())
+
+	return nil
+}
+
+func replayMoves(chessGame *variant.Game, moves []types.Move) error {
+	for _, m := range moves {
+		if err := chessGame.MoveStr(m.UCI); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (game *ChessGame) record() types.Game {
+	record := types.Game{
+		ID:             game.ID,
+		PlayerWhite:    game.PlayerWhite,
+		PlayerBlack:    game.PlayerBlack,
+		Color:          game.Color,
+		InitialTime:    game.InitialTime,
+		TimeControl:    game.TimeControl,
+		Status:         types.GameStatusWaiting,
+		Outcome:        game.Outcome,
+		Method:         game.Method,
+		Rated:          game.Rated,
+		SpectatorDelay: game.SpectatorDelay,
+		Variant:        game.Variant,
+		InitialFEN:     game.InitialFEN,
+		BotLevel:       game.BotLevel,
+		Version:        game.Version,
+		CreatedAt:      game.CreatedAt,
+		UpdatedAt:      time.Now(),
+	}
+
+	if game.GameStarted {
+		record.Status = types.GameStatusPlaying
+	}
+
+	if game.Outcome != "" {
+		record.Status = types.GameStatusFinished
+	}
+
+	if game.Clock != nil {
+		turn := game.Game.Position().Turn()
+		record.WhiteTime = game.Clock.Remaining(chess.White, turn, record.UpdatedAt).Milliseconds()
+		record.BlackTime = game.Clock.Remaining(chess.Black, turn, record.UpdatedAt).Milliseconds()
+	} else {
+		initial := (time.Duration(game.InitialTime) * time.Minute).Milliseconds()
+		record.WhiteTime = initial
+		record.BlackTime = initial
+	}
+
+	return record
+}
+
+func persistGame(game *ChessGame) {
+	if game.app == nil {
+		return
+	}
+
+	if err := game.app.UpdateGame(game.record()); err != nil {
+		log.Printf("failed to save game %s: %v", game.ID, err)
+	}
+}
+
+// deleteMoves removes the moves after ply, undone by a takeback.
+func deleteMoves(game *ChessGame, ply int) {
+	if game.app == nil {
+		return
+	}
+
+	if err := game.app.DeleteMovesAfter(game.ID, ply); err != nil {
+		log.Printf("failed to delete moves for game %s: %v", game.ID, err)
+	}
+}
+
+func finishGame(game *ChessGame) {
+	if game.app == nil {
+		return
+	}
+
+	if err := game.app.FinishGame(game.record()); err != nil {
+		log.Printf("failed to finish game %s: %v", game.ID, err)
+	}
+}
+
+// recordMove appends the last move on the board to game.Moves and saves it.
+func recordMove(game *ChessGame, prePosition *variant.Position, now time.Time) {
+	moves := game.Game.Moves()
+	move := moves[len(moves)-1]
+
+	record := types.Move{
+		GameID:    game.ID,
+		Ply:       len(moves),
+		SAN:       prePosition.SAN(move),
+		UCI:       prePosition.UCI(move),
+		FEN:       game.Game.Position().String(),
+		WhiteTime: game.Clock.White.Milliseconds(),
+		BlackTime: game.Clock.Black.Milliseconds(),
+		CreatedAt: now,
+	}
+	game.Moves = append(game.Moves, record)
+	game.Version++
+
+	if game.app == nil {
+		return
+	}
+
+	if err := game.app.CreateMove(record); err != nil {
+		log.Printf("failed to save move for game %s: %v", game.ID, err)
+	}
+}
@@ -0,0 +1,396 @@
+package app
+
+import (
+	"ChessApp/service/rating"
+	"ChessApp/types"
+	"ChessApp/variant"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const pgnLineLength = 80
+
+var clockCommentRe = regexp.MustCompile(`\[%clk\s+(\d+):(\d{1,2}):(\d{1,2}(?:\.\d+)?)\]`)
+
+var tagPairRe = regexp.MustCompile(`^\[(\w+)\s+"((?:[^"\\]|\\.)*)"\]$`)
+
+// moveNumberRe matches the number in front of a move, as in 1. or 1...
+var moveNumberRe = regexp.MustCompile(`^\d+\.+`)
+
+var terminations = map[string]string{
+	"timeout":                          "time forfeit",
+	"timeout_vs_insufficient_material": "time forfeit",
+	"aborted":                          "abandoned",
+	"abandonment":                      "abandoned",
+	"expired":                          "abandoned",
+}
+
+// encodePGN writes the PGN of a live game. Results decided outside the
+// board, such as timeouts, come from the ChessGame rather than chess.Game.
+func encodePGN(game *ChessGame) string {
+	return exportPGN(game.record(), game.Moves)
+}
+
+// exportPGN writes a PGN with the Seven Tag Roster, the TimeControl tag and
+// a [%clk] comment with the mover's remaining time after every move. Games
+// of other variants also get the Variant tag, and the SetUp and FEN tags if
+// they did not start from the standard position.
+func exportPGN(game types.Game, moves []types.Move) string {
+	result := game.Outcome
+	if result == "" {
+		result = chess.NoOutcome.String()
+	}
+
+	kind := rating.TimeClass(game.InitialTime, game.TimeControl)
+	if game.Variant != "" && game.Variant != variant.Standard {
+		kind = variant.PGNName(game.Variant)
+	}
+
+	event := "Casual " + kind + " game"
+	if game.Rated {
+		event = "Rated " + kind + " game"
+	}
+	if game.Owner != "" {
+		event = "Imported game"
+	}
+
+	timeControl := "-"
+	if game.InitialTime > 0 || game.TimeControl > 0 {
+		timeControl = fmt.Sprintf("%d+%d", game.InitialTime*60, game.TimeControl)
+	}
+
+	tags := []*chess.TagPair{
+		{Key: "Event", Value: event},
+		{Key: "Site", Value: "ChessApp"},
+		{Key: "Date", Value: game.CreatedAt.Format("2006.01.02")},
+		{Key: "Round", Value: "-"},
+		{Key: "White", Value: pgnName(game.PlayerWhite)},
+		{Key: "Black", Value: pgnName(game.PlayerBlack)},
+		{Key: "Result", Value: result},
+		{Key: "TimeControl", Value: timeControl},
+		{Key: "Termination", Value: pgnTermination(game)},
+	}
+
+	if game.Variant != "" && game.Variant != variant.Standard {
+		tags = append(tags, &chess.TagPair{Key: "Variant", Value: variant.PGNName(game.Variant)})
+	}
+	if game.InitialFEN != "" {
+		tags = append(tags,
+			&chess.TagPair{Key: "SetUp", Value: "1"},
+			&chess.TagPair{Key: "FEN", Value: game.InitialFEN},
+		)
+	}
+
+	var sb strings.Builder
+	for _, tag := range tags {
+		value := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(tag.Value)
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", tag.Key, value)
+	}
+	sb.WriteString("\n")
+
+	tokens := []string{}
+	for i, move := range moves {
+		fields := strings.Fields(move.FEN)
+		whiteMoved := fields[1] == "b"
+		number, _ := strconv.Atoi(fields[5])
+
+		if whiteMoved {
+			tokens = append(tokens, fmt.Sprintf("%d.", number))
+		} else if i == 0 {
+			tokens = append(tokens, fmt.Sprintf("%d...", number-1))
+		}
+		tokens = append(tokens, move.SAN)
+
+		clock := move.BlackTime
+		if whiteMoved {
+			clock = move.WhiteTime
+		}
+		if clock >= 0 {
+			tokens = append(tokens, fmt.Sprintf("{ [%%clk %s] }", formatClock(clock)))
+		}
+	}
+	tokens = append(tokens, result)
+
+	line := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if line+1+len(token) > pgnLineLength {
+				sb.WriteString("\n")
+				line = 0
+			} else {
+				sb.WriteString(" ")
+				line++
+			}
+		}
+		sb.WriteString(token)
+		line += len(token)
+	}
+	sb.WriteString("\n")
+
+	return sb.String()
+}
+
+// importPGN validates pgn and converts it into a finished game of any
+// variant and its moves. Games without a result are rejected. Clock times
+// are taken from [%clk] comments and are -1 if missing.
+func importPGN(pgn string) (record types.Game, moves []types.Move, err error) {
+	tags, movetext := splitPGN(pgn)
+
+	name, ok := variant.FromPGNName(tags["Variant"])
+	if !ok {
+		return record, nil, fmt.Errorf("invalid pgn: unknown variant %s", tags["Variant"])
+	}
+
+	// Without a FEN tag even Chess960 starts from the standard position
+	fen := tags["FEN"]
+	if fen == "" {
+		fen = variant.StandardFEN
+	}
+
+	game, err := variant.NewGame(name, fen)
+	if err != nil {
+		return record, nil, fmt.Errorf("invalid pgn: %v", err)
+	}
+
+	played, result, err := readMovetext(movetext)
+	if err != nil {
+		return record, nil, fmt.Errorf("invalid pgn: %v", err)
+	}
+	if len(played) == 0 {
+		return record, nil, fmt.Errorf("invalid pgn: no moves")
+	}
+
+	whiteTime, blackTime := int64(-1), int64(-1)
+
+	for i, token := range played {
+		pre := game.Position()
+
+		move, err := pre.Decode(token.san)
+		if err != nil {
+			return record, nil, fmt.Errorf("invalid pgn: %v", err)
+		}
+
+		san, uci := pre.SAN(move), pre.UCI(move)
+		if err := game.MoveStr(uci); err != nil {
+			return record, nil, fmt.Errorf("invalid pgn: %v", err)
+		}
+
+		for _, comment := range token.comments {
+			if clock, ok := parseClock(comment); ok {
+				if pre.Turn() == chess.White {
+					whiteTime = clock
+				} else {
+					blackTime = clock
+				}
+			}
+		}
+
+		moves = append(moves, types.Move{
+			Ply:       i + 1,
+			SAN:       san,
+			UCI:       uci,
+			FEN:       game.Position().String(),
+			WhiteTime: whiteTime,
+			BlackTime: blackTime,
+		})
+	}
+
+	record = types.Game{
+		PlayerWhite: tags["White"],
+		PlayerBlack: tags["Black"],
+		Color:       "white",
+		Variant:     name,
+		InitialFEN:  tags["FEN"],
+		Status:      types.GameStatusFinished,
+		Method:      game.Method(),
+		WhiteTime:   whiteTime,
+		BlackTime:   blackTime,
+	}
+
+	record.Outcome, err = pgnResult(tags["Result"], result, game.Outcome())
+	if err != nil {
+		return record, nil, fmt.Errorf("invalid pgn: %v", err)
+	}
+
+	// A time control without an increment is given in seconds alone
+	base, increment, _ := strings.Cut(tags["TimeControl"], "+")
+	if seconds, err := strconv.Atoi(base); err == nil {
+		record.InitialTime = seconds / 60
+		record.TimeControl, _ = strconv.Atoi(increment)
+	}
+
+	return record, moves, nil
+}
+
+// pgnResult settles the result of an imported game from its Result tag, the
+// result at the end of its movetext and the outcome on the board. Any of
+// them may be missing, but those given have to agree.
+func pgnResult(tag, token string, board chess.Outcome) (string, error) {
+	if tag != "" && token != "" && tag != token {
+		return "", fmt.Errorf("result %s does not match the movetext result %s", tag, token)
+	}
+
+	result := tag
+	if result == "" {
+		result = token
+	}
+
+	if board != chess.NoOutcome {
+		if result != "" && result != board.String() {
+			return "", fmt.Errorf("result %s does not match the final position, which is %s", result, board)
+		}
+		result = board.String()
+	}
+
+	switch result {
+	case chess.WhiteWon.String(), chess.BlackWon.String(), chess.Draw.String():
+		return result, nil
+	case "", chess.NoOutcome.String():
+		return "", fmt.Errorf("the game has no result")
+	default:
+		return "", fmt.Errorf("unknown result %s", result)
+	}
+}
+
+// splitPGN reads the tag pairs at the top of pgn and returns them with the
+// movetext that follows.
+func splitPGN(pgn string) (map[string]string, string) {
+	tags := make(map[string]string)
+	lines := strings.Split(pgn, "\n")
+
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+
+		match := tagPairRe.FindStringSubmatch(line)
+		if match == nil {
+			break
+		}
+		tags[match[1]] = strings.NewReplacer(`\\`, `\`, `\"`, `"`).Replace(match[2])
+	}
+
+	return tags, strings.Join(lines[i:], "\n")
+}
+
+// pgnMove is a move of the movetext in SAN with the comments after it.
+type pgnMove struct {
+	san      string
+	comments []string
+}
+
+// readMovetext splits movetext into its moves and the result at the end,
+// skipping move numbers, annotations and variations. Comments before the
+// first move belong to no move and are dropped.
+func readMovetext(movetext string) ([]pgnMove, string, error) {
+	moves := []pgnMove{}
+	result := ""
+	depth := 0
+
+	comment := func(text string) {
+		if depth == 0 && len(moves) > 0 {
+			moves[len(moves)-1].comments = append(moves[len(moves)-1].comments, text)
+		}
+	}
+
+	for i := 0; i < len(movetext); {
+		switch c := movetext[i]; {
+		case c == '{':
+			end := strings.IndexByte(movetext[i:], '}')
+			if end < 0 {
+				return nil, "", fmt.Errorf("unterminated comment")
+			}
+			comment(movetext[i+1 : i+end])
+			i += end + 1
+		case c == ';':
+			end := strings.IndexByte(movetext[i:], '\n')
+			if end < 0 {
+				end = len(movetext) - i
+			}
+			comment(movetext[i+1 : i+end])
+			i += end
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth == 0 {
+				return nil, "", fmt.Errorf("unbalanced variation")
+			}
+			depth--
+			i++
+		case strings.IndexByte(" \t\r\n", c) >= 0:
+			i++
+		default:
+			end := i
+			for end < len(movetext) && strings.IndexByte(" \t\r\n{};()", movetext[end]) < 0 {
+				end++
+			}
+			token := moveNumberRe.ReplaceAllString(movetext[i:end], "")
+			i = end
+
+			if depth > 0 || token == "" || strings.HasPrefix(token, "$") {
+				continue
+			}
+			if result != "" {
+				return nil, "", fmt.Errorf("moves after the result")
+			}
+
+			switch token {
+			case "1-0", "0-1", "1/2-1/2", "*":
+				result = token
+			default:
+				moves = append(moves, pgnMove{san: token})
+			}
+		}
+	}
+
+	if depth > 0 {
+		return nil, "", fmt.Errorf("unbalanced variation")
+	}
+
+	return moves, result, nil
+}
+
+func pgnName(name string) string {
+	if name == "" {
+		return "?"
+	}
+	return name
+}
+
+func pgnTermination(game types.Game) string {
+	if game.Outcome == "" {
+		return "unterminated"
+	}
+
+	if termination, ok := terminations[game.Method]; ok {
+		return termination
+	}
+	return "normal"
+}
+
+func formatClock(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+func parseClock(comment string) (int64, bool) {
+	match := clockCommentRe.FindStringSubmatch(comment)
+	if match == nil {
+		return 0, false
+	}
+
+	hours, _ := strconv.Atoi(match[1])
+	minutes, _ := strconv.Atoi(match[2])
+	seconds, _ := strconv.ParseFloat(match[3], 64)
+
+	d := time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute + time.Duration(seconds*float64(time.Second))
+	return d.Milliseconds(), true
+}
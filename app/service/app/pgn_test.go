@@ -0,0 +1,333 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/notnil/chess"
+)
+
+const testPGN = `[Event "Casual blitz game"]
+[Site "ChessApp"]
+[White "alice"]
+[Black "bob"]
+[Result "0-1"]
+[TimeControl "180+2"]
+
+1. f3 { [%clk 0:03:01] } 1... e5 { [%clk 0:03:00] } 2. g4 { [%clk 0:02:59.5] } 2... Qh4# { [%clk 0:02:58] } 0-1`
+
+func TestPGNRoundTrip(t *testing.T) {
+	record, moves, err := importPGN(testPGN)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.PlayerWhite != "alice" || record.PlayerBlack != "bob" || record.Outcome != "0-1" {
+		t.Errorf("unexpected imported game %+v", record)
+	}
+
+	if record.InitialTime != 3 || record.TimeControl != 2 {
+		t.Errorf("expected 3+2, got %d+%d", record.InitialTime, record.TimeControl)
+	}
+
+	if len(moves) != 4 || moves[3].SAN != "Qh4#" || moves[3].UCI != "d8h4" {
+		t.Fatalf("unexpected imported moves %+v", moves)
+	}
+
+	if moves[2].WhiteTime != 179500 {
+		t.Errorf("expected white clock 179500, got %d", moves[2].WhiteTime)
+	}
+
+	pgn := exportPGN(record, moves)
+
+	for _, expected := range []string{
+		`[Event "Casual blitz game"]`,
+		`[Date "`,
+		`[Round "-"]`,
+		`[White "alice"]`,
+		`[TimeControl "180+2"]`,
+		`2. g4 { [%clk 0:02:59] } Qh4#`,
+		`{ [%clk 0:02:58] } 0-1`,
+	} {
+		if !strings.Contains(pgn, expected) {
+			t.Errorf("expected exported pgn to contain %q, got\n%s", expected, pgn)
+		}
+	}
+
+	if _, again, err := importPGN(pgn); err != nil || len(again) != len(moves) {
+		t.Errorf("expected exported pgn to import again, got %v", err)
+	}
+}
+
+func TestImportInvalidPGN(t *testing.T) {
+	invalid := []string{
+		"",
+		"1. e4 e5 2. Ke3 *",
+		"{ comment } 1. e4 *",
+		"[Variant \"Crazyhouse\"]\n\n1. e4 e5 *",
+		"[Result \"*\"]\n\n1. e4 e5 *",
+		"[Variant \"Bughouse\"]\n\n1. e4 e5 1-0",
+		"1. e4 (1. d4 1-0",
+	}
+
+	for _, pgn := range invalid {
+		if _, _, err := importPGN(pgn); err == nil {
+			t.Errorf("expected %q to be rejected", pgn)
+		}
+	}
+}
+
+func TestImportResult(t *testing.T) {
+	results := []struct {
+		Name     string
+		PGN      string
+		Expected string
+	}{
+		{Name: "Tag Only", PGN: "[Result \"0-1\"]\n\n1. e4 e5", Expected: "0-1"},
+		{Name: "Movetext Only", PGN: "1. e4 e5 1/2-1/2", Expected: "1/2-1/2"},
+		{Name: "Board Only", PGN: "1. f3 e5 2. g4 Qh4#", Expected: "0-1"},
+		{Name: "All Agree", PGN: "[Result \"0-1\"]\n\n1. f3 e5 2. g4 Qh4# 0-1", Expected: "0-1"},
+		{Name: "Unknown Result", PGN: "[Result \"2-0\"]\n\n1. e4 e5", Expected: "unknown result"},
+		{Name: "Movetext Mismatch", PGN: "[Result \"1-0\"]\n\n1. e4 e5 0-1", Expected: "does not match the movetext"},
+		{Name: "Board Mismatch", PGN: "[Result \"1/2-1/2\"]\n\n1. f3 e5 2. g4 Qh4# 1/2-1/2", Expected: "does not match the final position"},
+	}
+
+	for _, tc := range results {
+		t.Run(tc.Name, func(t *testing.T) {
+			record, _, err := importPGN(tc.PGN)
+			if err != nil {
+				if !strings.Contains(err.Error(), tc.Expected) {
+					t.Errorf("expected %q, got %v", tc.Expected, err)
+				}
+				return
+			}
+			if record.Outcome != tc.Expected {
+				t.Errorf("expected %s, got %s", tc.Expected, record.Outcome)
+			}
+		})
+	}
+}
+
+func TestImportTimeControl(t *testing.T) {
+	timeControls := []struct {
+		Tag         string
+		InitialTime int
+		Increment   int
+	}{
+		{Tag: "180+2", InitialTime: 3, Increment: 2},
+		{Tag: "300", InitialTime: 5, Increment: 0},
+		{Tag: "-", InitialTime: 0, Increment: 0},
+	}
+
+	for _, tc := range timeControls {
+		pgn := strings.Replace(testPGN, `[TimeControl "180+2"]`, `[TimeControl "`+tc.Tag+`"]`, 1)
+
+		record, _, err := importPGN(pgn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if record.InitialTime != tc.InitialTime || record.TimeControl != tc.Increment {
+			t.Errorf("expected %s to be %d+%d, got %d+%d", tc.Tag, tc.InitialTime, tc.Increment, record.InitialTime, record.TimeControl)
+		}
+	}
+}
+
+func TestExportVariantPGN(t *testing.T) {
+	game := &ChessGame{ID: "960", Variant: "chess960", InitialFEN: "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1", InitialTime: 5}
+	startGame(game)
+	defer game.Clock.stopTimer()
+
+	JoinGame(game, "alice")
+	JoinGame(game, "bob")
+	for _, move := range []string{"e4", "e5", "Nf3"} {
+		if _, err := MakeMove(game, move); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pgn := encodePGN(game)
+
+	for _, expected := range []string{
+		`[Event "Casual Chess960 game"]`,
+		`[Variant "Chess960"]`,
+		`[SetUp "1"]`,
+		`[FEN "bbqnnrkr/pppppppp/8/8/8/8/PPPPPPPP/BBQNNRKR w KQkq - 0 1"]`,
+		`2. Nf3`,
+	} {
+		if !strings.Contains(pgn, expected) {
+			t.Errorf("expected exported pgn to contain %q, got\n%s", expected, pgn)
+		}
+	}
+
+	resign(game, chess.Black, time.Now())
+
+	record, moves, err := importPGN(encodePGN(game))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Variant != "chess960" || record.InitialFEN != game.InitialFEN || record.Outcome != "1-0" || len(moves) != 3 {
+		t.Errorf("expected the Chess960 game back, got %+v with %d moves", record, len(moves))
+	}
+}
+
+func TestImportVariantPGN(t *testing.T) {
+	games := []struct {
+		Name    string
+		PGN     string
+		Variant string
+		Outcome string
+		Method  string
+		Last    string
+	}{
+		{
+			Name:    "King Of The Hill",
+			PGN:     "[Variant \"King of the Hill\"]\n[Result \"0-1\"]\n\n1. e4 d5 2. exd5 Kd7 3. Ke2 Kd6 4. Ke3 Kxd5 0-1",
+			Variant: "king_of_the_hill",
+			Outcome: "0-1",
+			Method:  "king_in_centre",
+			Last:    "Kxd5",
+		},
+		{
+			Name:    "Crazyhouse Drop",
+			PGN:     "[Variant \"Crazyhouse\"]\n[Result \"0-1\"]\n\n1. e4 d5 2. exd5 Qxd5 3. Nc3 Qa5 4. P@b4 0-1",
+			Variant: "crazyhouse",
+			Outcome: "0-1",
+			Last:    "P@b4",
+		},
+		{
+			Name:    "Annotations And Variations",
+			PGN:     "[Result \"0-1\"]\n\n1. f3 $2 (1. e4 e5) e5 ; a comment\n2. g4?? Qh4# 0-1",
+			Variant: "standard",
+			Outcome: "0-1",
+			Method:  "checkmate",
+			Last:    "Qh4#",
+		},
+	}
+
+	for _, tc := range games {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			record, moves, err := importPGN(tc.PGN)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if record.Variant != tc.Variant || record.Outcome != tc.Outcome || record.Method != tc.Method {
+				t.Errorf("expected %s won %s by %q, got %s won %s by %q", tc.Variant, tc.Outcome, tc.Method, record.Variant, record.Outcome, record.Method)
+			}
+			if moves[len(moves)-1].SAN != tc.Last {
+				t.Errorf("expected the last move to be %s, got %s", tc.Last, moves[len(moves)-1].SAN)
+			}
+		})
+	}
+}
+
+func TestCustomPositionPGNRoundTrip(t *testing.T) {
+	fen := "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1"
+
+	game := &ChessGame{ID: "custom", InitialFEN: fen, InitialTime: 5}
+	startGame(game)
+	defer game.Clock.stopTimer()
+
+	for _, move := range []string{"Kd7", "e4"} {
+		if _, err := MakeMove(game, move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := resign(game, chess.Black, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	pgn := encodePGN(game)
+
+	for _, expected := range []string{
+		`[SetUp "1"]`,
+		`[FEN "` + fen + `"]`,
+		`1... Kd7`,
+		`2. e4`,
+	} {
+		if !strings.Contains(pgn, expected) {
+			t.Errorf("expected exported pgn to contain %q, got\n%s", expected, pgn)
+		}
+	}
+
+	record, moves, err := importPGN(pgn)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if record.InitialFEN != fen || len(moves) != 2 || moves[1].UCI != "e2e4" {
+		t.Errorf("unexpected imported game %+v with moves %+v", record, moves)
+	}
+}
+
+func TestExportDelayedPGN(t *testing.T) {
+	now := time.Now()
+	moves := []types.Move{
+		{Ply: 1, SAN: "e4", UCI: "e2e4", FEN: "rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1", CreatedAt: now.Add(-2 * time.Minute)},
+		{Ply: 2, SAN: "e5", UCI: "e7e5", FEN: "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2", CreatedAt: now.Add(-10 * time.Second)},
+	}
+
+	chessApp := &mockChessApp{
+		games: map[string]types.Game{
+			"delayed":  {ID: "delayed", PlayerWhite: "testuser", PlayerBlack: "bob", Status: types.GameStatusPlaying, SpectatorDelay: 60},
+			"finished": {ID: "finished", PlayerWhite: "alice", PlayerBlack: "bob", Status: types.GameStatusFinished, Outcome: "1/2-1/2", SpectatorDelay: 60},
+		},
+		moves: map[string][]types.Move{"delayed": moves, "finished": moves},
+	}
+	handler := NewHandler(chessApp, &mockUserApp{}, nil, nil)
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), "1", "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exports := []struct {
+		Name     string
+		GameID   string
+		Token    string
+		Expected bool
+	}{
+		{Name: "Spectator During The Game", GameID: "delayed", Expected: false},
+		{Name: "Player During The Game", GameID: "delayed", Token: token, Expected: true},
+		{Name: "Spectator After The Game", GameID: "finished", Expected: true},
+	}
+
+	for _, tc := range exports {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodGet, "/game/"+tc.GameID+"/pgn", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.Token)
+			}
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+			router.Use(auth.Middleware(&mockUserApp{}))
+			auth.SetAccess(router.HandleFunc("/game/{id}/pgn", handler.handlePGN), auth.Optional)
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body)
+			}
+
+			pgn := rr.Body.String()
+			if !strings.Contains(pgn, "1. e4") {
+				t.Errorf("expected the first move in\n%s", pgn)
+			}
+			if strings.Contains(pgn, "e5") != tc.Expected {
+				t.Errorf("expected the recent move shown %v, got\n%s", tc.Expected, pgn)
+			}
+		})
+	}
+}
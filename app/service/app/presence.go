@@ -0,0 +1,154 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/protocol"
+	"fmt"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+// disconnectGrace is how long a player may be gone before the opponent can
+// claim the game.
+func disconnectGrace() time.Duration {
+	return time.Duration(config.Envs.DisconnectGraceInSeconds) * time.Second
+}
+
+// playerConnected counts a new connection of a player and returns the
+// reconnect event if they had been marked as gone.
+func playerConnected(game *ChessGame, client *Client) protocol.Message {
+	color := playerColor(game, client.Username)
+	if client.Role != RolePlayer || color == chess.NoColor {
+		return nil
+	}
+
+	if game.presence == nil {
+		game.presence = make(map[chess.Color]int)
+	}
+	game.presence[color]++
+
+	if _, absent := game.absentSince[color]; !absent {
+		return nil
+	}
+
+	delete(game.absentSince, color)
+	return &protocol.OpponentReconnected{Color: colorName(color)}
+}
+
+// playerDisconnected returns the disconnect event when the last connection
+// of a player in a running game closes.
+func playerDisconnected(game *ChessGame, client *Client, now time.Time) protocol.Message {
+	color := playerColor(game, client.Username)
+	if client.Role != RolePlayer || color == chess.NoColor {
+		return nil
+	}
+
+	game.presence[color]--
+	if game.presence[color] > 0 || checkPlaying(game) != nil {
+		return nil
+	}
+
+	if game.absentSince == nil {
+		game.absentSince = make(map[chess.Color]time.Time)
+	}
+	game.absentSince[color] = now
+
+	return newDisconnectedMessage(game, color, now)
+}
+
+// markAbsent starts the absence clock of every seated player without an
+// open socket, so a player who never connects, or does not come back after
+// a restart, can be claimed against like one who disconnected. The clock
+// stops when they connect. Bots never connect and are never absent.
+func markAbsent(game *ChessGame, now time.Time) []protocol.Message {
+	messages := []protocol.Message{}
+
+	for _, color := range []chess.Color{chess.White, chess.Black} {
+		name := game.PlayerWhite
+		if color == chess.Black {
+			name = game.PlayerBlack
+		}
+		if name == "" || game.presence[color] > 0 || (game.BotLevel > 0 && name == botName(game.BotLevel)) {
+			continue
+		}
+
+		if game.absentSince == nil {
+			game.absentSince = make(map[chess.Color]time.Time)
+		}
+		if _, absent := game.absentSince[color]; !absent {
+			game.absentSince[color] = now
+			messages = append(messages, newDisconnectedMessage(game, color, now))
+		}
+	}
+
+	return messages
+}
+
+func newDisconnectedMessage(game *ChessGame, color chess.Color, now time.Time) *protocol.OpponentDisconnected {
+	claimAt := game.absentSince[color].Add(disconnectGrace())
+
+	secondsLeft := 0
+	if left := claimAt.Sub(now); left > 0 {
+		secondsLeft = int((left + time.Second - 1) / time.Second)
+	}
+
+	return &protocol.OpponentDisconnected{
+		Color:       colorName(color),
+		SecondsLeft: secondsLeft,
+		ClaimAt:     claimAt,
+	}
+}
+
+// absentPlayers returns a disconnect event for every player other than
+// username who is currently gone, for clients that connect late.
+func absentPlayers(game *ChessGame, username string, now time.Time) []protocol.Message {
+	messages := []protocol.Message{}
+	if checkPlaying(game) != nil {
+		return messages
+	}
+
+	for _, color := range []chess.Color{chess.White, chess.Black} {
+		if _, absent := game.absentSince[color]; absent && playerColor(game, username) != color {
+			messages = append(messages, newDisconnectedMessage(game, color, now))
+		}
+	}
+	return messages
+}
+
+// checkAbandoned allows color to claim the game once the opponent has been
+// gone for the grace period.
+func checkAbandoned(game *ChessGame, color chess.Color, now time.Time) error {
+	if err := checkPlaying(game); err != nil {
+		return err
+	}
+
+	since, absent := game.absentSince[color.Other()]
+	if !absent {
+		return fmt.Errorf("opponent is connected")
+	}
+
+	if left := since.Add(disconnectGrace()).Sub(now); left > 0 {
+		return fmt.Errorf("opponent can be claimed in %v", left.Round(time.Second))
+	}
+
+	return nil
+}
+
+func claimVictory(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if err := checkAbandoned(game, color, now); err != nil {
+		return nil, err
+	}
+
+	endGame(game, winner(color), "abandonment")
+	return []protocol.Message{newGameOverMessage(game)}, nil
+}
+
+func claimDraw(game *ChessGame, color chess.Color, now time.Time) ([]protocol.Message, error) {
+	if err := checkAbandoned(game, color, now); err != nil {
+		return nil, err
+	}
+
+	endGame(game, chess.Draw, "abandonment")
+	return []protocol.Message{newGameOverMessage(game)}, nil
+}
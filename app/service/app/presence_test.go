@@ -0,0 +1,139 @@
+package app
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/types"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func TestClaimAbandoned(t *testing.T) {
+	claims := []struct {
+		Name      string
+		Away      time.Duration
+		Reconnect bool
+		Action    string
+		Valid     bool
+		Outcome   string
+	}{
+		{
+			Name:    "Claim Victory After Grace",
+			Away:    2 * disconnectGrace(),
+			Action:  protocol.TypeClaimVictory,
+			Valid:   true,
+			Outcome: "1-0",
+		},
+		{
+			Name:    "Claim Draw After Grace",
+			Away:    2 * disconnectGrace(),
+			Action:  protocol.TypeClaimDraw,
+			Valid:   true,
+			Outcome: "1/2-1/2",
+		},
+		{
+			Name:   "Claim Before Grace",
+			Away:   disconnectGrace() / 2,
+			Action: protocol.TypeClaimVictory,
+			Valid:  false,
+		},
+		{
+			Name:      "Claim After Reconnect",
+			Away:      2 * disconnectGrace(),
+			Reconnect: true,
+			Action:    protocol.TypeClaimVictory,
+			Valid:     false,
+		},
+	}
+
+	for _, tc := range claims {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			game := &ChessGame{Color: "white", InitialTime: 5}
+			JoinGame(game, "alice")
+			JoinGame(game, "bob")
+			defer game.Clock.stopTimer()
+
+			alice := &Client{Username: "alice", Role: RolePlayer}
+			bob := &Client{Username: "bob", Role: RolePlayer}
+			playerConnected(game, alice)
+			playerConnected(game, bob)
+
+			now := time.Now()
+			message := playerDisconnected(game, bob, now.Add(-tc.Away))
+			if disconnected, ok := message.(*protocol.OpponentDisconnected); !ok || disconnected.Color != "black" {
+				t.Fatalf("expected black to be disconnected, got %+v", message)
+			}
+
+			if tc.Reconnect {
+				if _, ok := playerConnected(game, bob).(*protocol.OpponentReconnected); !ok {
+					t.Fatal("expected reconnect event")
+				}
+			}
+
+			_, err := actions[tc.Action](game, chess.White, now)
+			if (err == nil) != tc.Valid {
+				t.Fatalf("expected valid %v, got %v", tc.Valid, err)
+			}
+
+			if game.Outcome != tc.Outcome {
+				t.Errorf("expected %q, got %q", tc.Outcome, game.Outcome)
+			}
+		})
+	}
+}
+
+func TestSecondConnectionKeepsPresence(t *testing.T) {
+	game := &ChessGame{Color: "white", InitialTime: 5}
+	JoinGame(game, "alice")
+	JoinGame(game, "bob")
+	defer game.Clock.stopTimer()
+
+	first := &Client{Username: "bob", Role: RolePlayer}
+	second := &Client{Username: "bob", Role: RolePlayer}
+	playerConnected(game, first)
+	playerConnected(game, second)
+
+	if message := playerDisconnected(game, first, time.Now()); message != nil {
+		t.Errorf("expected no disconnect while a second tab is open, got %+v", message)
+	}
+}
+
+func TestClaimNeverConnected(t *testing.T) {
+	game := &ChessGame{Color: "white", InitialTime: 5}
+	JoinGame(game, "alice")
+	JoinGame(game, "bob")
+	defer game.Clock.stopTimer()
+
+	playerConnected(game, &Client{Username: "alice", Role: RolePlayer})
+
+	now := time.Now()
+	if _, err := claimVictory(game, chess.White, now); err == nil {
+		t.Fatal("expected no claim before the grace period")
+	}
+	if _, err := claimVictory(game, chess.Black, now.Add(2*disconnectGrace())); err == nil {
+		t.Fatal("expected no claim against a connected player")
+	}
+
+	if _, err := claimVictory(game, chess.White, now.Add(2*disconnectGrace())); err != nil {
+		t.Fatalf("expected to claim against a player who never connected, got %v", err)
+	}
+	if game.Outcome != "1-0" || game.Method != "abandonment" {
+		t.Errorf("expected white to win by abandonment, got %s by %s", game.Outcome, game.Method)
+	}
+}
+
+func TestClaimAfterRestore(t *testing.T) {
+	game := &ChessGame{Color: "white", InitialTime: 5, PlayerWhite: "alice", PlayerBlack: "bob"}
+	record := types.Game{Status: types.GameStatusPlaying, WhiteTime: 300000, BlackTime: 300000}
+	if err := restoreGame(game, record, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	playerConnected(game, &Client{Username: "alice", Role: RolePlayer})
+
+	if _, err := claimDraw(game, chess.White, time.Now().Add(2*disconnectGrace())); err != nil {
+		t.Fatalf("expected to claim against a player who did not come back, got %v", err)
+	}
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"ChessApp/types"
+	"fmt"
+	"sync"
+
+	"github.com/notnil/chess"
+)
+
+// Registry holds the live games. Each game added to it gets its own
+// goroutine that runs every command on the game one at a time, so joins,
+// moves, clock flags and broadcasts never run concurrently and ChessGame
+// needs no lock.
+type Registry struct {
+	games map[string]*ChessGame
+	mu    sync.RWMutex
+}
+
+var GameStore = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{games: make(map[string]*ChessGame)}
+}
+
+// Add registers game and starts its goroutine.
+func (r *Registry) Add(game *ChessGame) {
+	game.commands = make(chan func())
+	game.stopped = make(chan struct{})
+	go game.run(r)
+
+	r.mu.Lock()
+	r.games[game.ID] = game
+	r.mu.Unlock()
+}
+
+func (r *Registry) Get(id string) (*ChessGame, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	game, ok := r.games[id]
+	return game, ok
+}
+
+// List returns a snapshot of the live games.
+func (r *Registry) List() []*ChessGame {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	games := make([]*ChessGame, 0, len(r.games))
+	for _, game := range r.games {
+		games = append(games, game)
+	}
+	return games
+}
+
+func (r *Registry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.games)
+}
+
+// Join seats username in the game with the given id and returns the colour
+// they play.
+func (r *Registry) Join(id, username string) (string, error) {
+	game, ok := r.Get(id)
+	if !ok {
+		return "", fmt.Errorf("game does not exist")
+	}
+
+	var color string
+	var err error
+	if !game.Do(func() {
+		if err = JoinGame(game, username); err == nil {
+			color = colorName(playerColor(game, username))
+		}
+	}) {
+		return "", fmt.Errorf("game does not exist")
+	}
+	return color, err
+}
+
+// Abort ends the game with the given id without a result, for games that
+// could not be set up after they were created.
+func (r *Registry) Abort(id string) {
+	game, ok := r.Get(id)
+	if !ok {
+		return
+	}
+
+	game.Do(func() {
+		if game.Outcome == "" {
+			endGame(game, chess.NoOutcome, "aborted")
+		}
+	})
+}
+
+// CreatePairedGame creates a game through chessApp and seats white and
+// black in it, for games whose players are known up front. The game is
+// aborted if either of them cannot be seated.
+func CreatePairedGame(chessApp types.ChessApp, options types.GameOptions, white, black string) (*types.Game, error) {
+	// The first player to join gets the colour the game was created with
+	options.Color = "white"
+
+	created, err := chessApp.CreateGame(options)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, player := range []string{white, black} {
+		if _, err := GameStore.Join(created.ID, player); err != nil {
+			GameStore.Abort(created.ID)
+			return nil, err
+		}
+	}
+
+	return created, nil
+}
+
+func (r *Registry) remove(id string) {
+	r.mu.Lock()
+	delete(r.games, id)
+	r.mu.Unlock()
+}
+
+// run executes the game's commands until the game is retired, which
+// happens once it is over and the last client has left.
+func (game *ChessGame) run(r *Registry) {
+	for {
+		select {
+		case command := <-game.commands:
+			command()
+		case <-game.stopped:
+			return
+		}
+
+		if game.Outcome != "" && len(game.Connections) == 0 {
+			r.remove(game.ID)
+			close(game.stopped)
+			return
+		}
+	}
+}
+
+// Do runs fn on the game's goroutine and waits for it to finish. It must not
+// be called from that goroutine, and returns false if the game was retired
+// before fn could run.
+func (game *ChessGame) Do(fn func()) bool {
+	done := make(chan struct{})
+	command := func() {
+		fn()
+		close(done)
+	}
+
+	select {
+	case game.commands <- command:
+	case <-game.stopped:
+		return false
+	}
+
+	<-done
+	return true
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/protocol"
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func TestConcurrentJoin(t *testing.T) {
+	game := &ChessGame{ID: "crowded", Color: "white", InitialTime: 5}
+	GameStore.Add(game)
+	defer game.Do(func() { game.Clock.stopTimer() })
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seated := 0
+
+	for i := 0; i < 300; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			if _, err := GameStore.Join(game.ID, fmt.Sprintf("player%d", i)); err == nil {
+				mu.Lock()
+				seated++
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var white, black string
+	game.Do(func() { white, black = game.PlayerWhite, game.PlayerBlack })
+
+	if seated != 2 || white == "" || black == "" || white == black {
+		t.Errorf("expected two distinct players, got %d seated: %q and %q", seated, white, black)
+	}
+}
+
+func TestConcurrentClients(t *testing.T) {
+	handler := NewHandler(&mockChessApp{}, &namedUserApp{}, nil, nil)
+
+	game := &ChessGame{ID: "busy", Color: "white", InitialTime: 5}
+	GameStore.Add(game)
+	game.Do(func() {
+		JoinGame(game, "alice")
+		JoinGame(game, "bob")
+	})
+	defer game.Do(func() { game.Clock.stopTimer() })
+
+	router := mux.NewRouter()
+	router.Use(auth.Middleware(&namedUserApp{}))
+	auth.SetAccess(router.HandleFunc("/game/{id}", handler.handleGame), auth.Optional)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/game/busy"
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), "alice", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const spectators = 200
+	const tabs = 20
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	replies := map[string]int{}
+	conns := make(chan *websocket.Conn, spectators+tabs)
+
+	client := func(url, request string, message protocol.Message) {
+		defer wg.Done()
+
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+
+		conn.WriteJSON(protocol.NewEnvelope("hello", &protocol.Hello{Version: protocol.Version}))
+		conn.WriteJSON(protocol.NewEnvelope(request, message))
+
+		reply := readReply(t, conn, request)
+
+		mu.Lock()
+		replies[request+":"+reply]++
+		mu.Unlock()
+
+		// Keep reading so broadcasts never block the game
+		go func() {
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < spectators; i++ {
+		wg.Add(1)
+		go client(url, "resync", &protocol.Resync{Ply: 0})
+	}
+
+	// The same player races the same move from many tabs
+	for i := 0; i < tabs; i++ {
+		wg.Add(1)
+		go client(url+"?token="+token, "move", &protocol.Move{Move: "e4"})
+	}
+
+	wg.Wait()
+	close(conns)
+
+	var moves, watching int
+	game.Do(func() {
+		moves = len(game.Moves)
+		watching = countSpectators(game)
+	})
+
+	if moves != 1 || replies["move:"+protocol.TypeAck] != 1 || replies["move:"+protocol.TypeError] != tabs-1 {
+		t.Errorf("expected exactly one move to be played, got %d moves and replies %v", moves, replies)
+	}
+
+	if watching != spectators || replies["resync:"+protocol.TypeMissedMoves] != spectators {
+		t.Errorf("expected %d spectators, got %d and replies %v", spectators, watching, replies)
+	}
+
+	for conn := range conns {
+		conn.Close()
+	}
+}
+
+// readReply skips server events until the reply to id and returns its type.
+func readReply(t *testing.T, conn *websocket.Conn, id string) string {
+	for {
+		var envelope protocol.Envelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			t.Error(err)
+			return ""
+		}
+
+		if envelope.ID == id {
+			return envelope.Type
+		}
+	}
+}
+
+// namedUserApp treats the user ID in a token as the username.
+type namedUserApp struct {
+	mockUserApp
+}
+
+func (m *namedUserApp) GetUserByID(id string) (*types.User, error) {
+	return &types.User{ID: id, Username: id}, nil
+}
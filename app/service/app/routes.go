@@ -1,39 +1,48 @@
 package app
 
 import (
+	"ChessApp/config"
+	"ChessApp/protocol"
 	"ChessApp/service/auth"
+	"ChessApp/socket"
 	"ChessApp/types"
 	"ChessApp/utils"
+	"ChessApp/variant"
 	"fmt"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/notnil/chess"
 	"net/http"
+	"strconv"
+	"time"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 type Handler struct {
-	app     types.ChessApp
-	userApp types.UserApp
+	app       types.ChessApp
+	userApp   types.UserApp
+	ratingApp types.RatingApp
+	lobby     types.Lobby
 }
 
-func NewHandler(app types.ChessApp, userApp types.UserApp) *Handler {
+func NewHandler(app types.ChessApp, userApp types.UserApp, ratingApp types.RatingApp, lobby types.Lobby) *Handler {
 	return &Handler{
-		app:     app,
-		userApp: userApp,
+		app:       app,
+		userApp:   userApp,
+		ratingApp: ratingApp,
+		lobby:     lobby,
 	}
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/create", h.createGame).Methods(http.MethodPost)
-	router.HandleFunc("/game/{id}/join", h.handleJoin).Methods(http.MethodPost)
-	router.HandleFunc("/game/{id}", h.handleGame).Methods(http.MethodGet)
+	auth.SetAccess(router.HandleFunc("/create", h.createGame).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/game/{id}/join", h.handleJoin).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/game/{id}", h.handleGame).Methods(http.MethodGet), auth.Optional)
+	auth.SetAccess(router.HandleFunc("/game/{id}/pgn", h.handlePGN).Methods(http.MethodGet), auth.Optional)
+	auth.SetAccess(router.HandleFunc("/games/import", h.handleImport).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/games/open", h.handleOpenGames).Methods(http.MethodGet), auth.Public)
+	auth.SetAccess(router.HandleFunc("/protocol/schema", h.handleSchema).Methods(http.MethodGet), auth.Public)
 
 }
 
@@ -52,8 +61,134 @@ func (h *Handler) createGame(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.app.CreateGame(payload.InitialTime, payload.TimeControl, payload.Color)
+	variantName, botLevel := payload.GameMode, 0
+	if payload.GameMode == types.GameModeBot {
+		variantName, botLevel = variant.Standard, payload.Level
+	}
+
+	if payload.FEN != "" {
+		start, err := variant.NewGame(variantName, payload.FEN)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+		if start.Outcome() != chess.NoOutcome {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid fen: the game is already over by %s", start.Method()))
+			return
+		}
+	}
+
+	username := auth.GetUsernameFromContext(r.Context())
+
+	// The rating is needed to list the challenge, so fetch it before there
+	// is a game to clean up
+	var rating int
+	if botLevel == 0 {
+		var err error
+		rating, err = creatorRating(h.ratingApp, username, variantName, payload.InitialTime, payload.TimeControl)
+		if err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+	}
+
+	// Ratings only count games between people from the variant's own start
+	// position
+	created, err := h.app.CreateGame(types.GameOptions{
+		InitialTime:    payload.InitialTime,
+		TimeControl:    payload.TimeControl,
+		Color:          payload.Color,
+		Rated:          payload.Rated && payload.FEN == "" && botLevel == 0,
+		SpectatorDelay: payload.SpectatorDelay,
+		Variant:        variantName,
+		InitialFEN:     payload.FEN,
+		BotLevel:       botLevel,
+	})
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	color, err := GameStore.Join(created.ID, username)
+	if err != nil {
+		GameStore.Abort(created.ID)
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if botLevel > 0 {
+		if err := GameStore.StartBot(created.ID); err != nil {
+			GameStore.Abort(created.ID)
+			utils.WriteError(w, http.StatusServiceUnavailable, fmt.Errorf("failed to start the bot: %v", err))
+			return
+		}
+	} else if game, ok := GameStore.Get(created.ID); ok {
+		ttl := time.Duration(config.Envs.ChallengeExpirationInSeconds) * time.Second
+		game.Do(func() { openChallenge(game, h.lobby, rating, ttl) })
+	}
 
+	httpScheme, wsScheme := "http", "ws"
+	if r.TLS != nil {
+		httpScheme, wsScheme = "https", "wss"
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, types.CreateGameResponse{
+		ID:           created.ID,
+		JoinURL:      fmt.Sprintf("%s://%s/api/v1/game/%s/join", httpScheme, r.Host, created.ID),
+		WebSocketURL: fmt.Sprintf("%s://%s/api/v1/game/%s", wsScheme, r.Host, created.ID),
+		InitialTime:  created.InitialTime,
+		TimeControl:  created.TimeControl,
+		Color:        color,
+		Rated:        created.Rated,
+		Variant:      created.Variant,
+	})
+}
+
+// handleOpenGames lists the challenges waiting for an opponent. Filters and
+// the page are taken from the query string.
+func (h *Handler) handleOpenGames(w http.ResponseWriter, r *http.Request) {
+
+	query := types.OpenGamesQuery{
+		TimeClass: r.URL.Query().Get("time_class"),
+		Variant:   r.URL.Query().Get("variant"),
+		Limit:     20,
+	}
+
+	for key, value := range map[string]*int{"min_rating": &query.MinRating, "max_rating": &query.MaxRating, "limit": &query.Limit, "offset": &query.Offset} {
+		if raw := r.URL.Query().Get(key); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid %s %q", key, raw))
+				return
+			}
+			*value = n
+		}
+	}
+
+	if raw := r.URL.Query().Get("rated"); raw != "" {
+		rated, err := strconv.ParseBool(raw)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid rated %q", raw))
+			return
+		}
+		query.Rated = &rated
+	}
+
+	// Validate query
+	if err := utils.Validate.Struct(query); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid query %v", errors))
+		return
+	}
+
+	games, total := filterOpenGames(openGames(GameStore), query)
+
+	utils.WriteJSON(w, http.StatusOK, types.OpenGamesResponse{
+		Games:  games,
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	})
 }
 
 func (h *Handler) handleJoin(w http.ResponseWriter, r *http.Request) {
@@ -61,60 +196,129 @@ func (h *Handler) handleJoin(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	gameID := vars["id"]
 
-	game, exists := GameStore[gameID]
-	if !exists {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("game does not exist"))
+	username := auth.GetUsernameFromContext(r.Context())
+
+	color, err := GameStore.Join(gameID, username)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	username := auth.GetUsernameFromJWT(r, h.userApp)
+	utils.WriteJSON(w, http.StatusOK, types.JoinGameResponse{
+		GameID: gameID,
+		Color:  color,
+	})
+}
+
+func (h *Handler) handlePGN(w http.ResponseWriter, r *http.Request) {
 
-	if username == "" {
-		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("user has no JWT"))
+	vars := mux.Vars(r)
+	gameID := vars["id"]
+
+	game, err := h.app.GetGameByID(gameID)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("game does not exist"))
 		return
 	}
 
-	if err := JoinGame(game, username); err != nil {
+	moves, err := h.app.GetMovesByGameID(gameID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// Until the game is over, everyone but the players gets the moves as
+	// late as the spectator feed does
+	username := auth.GetUsernameFromContext(r.Context())
+	isPlayer := username != "" && (username == game.PlayerWhite || username == game.PlayerBlack)
+	if game.Status != types.GameStatusFinished && !isPlayer {
+		moves = delayedMoves(moves, game.SpectatorDelay, time.Now())
+	}
+
+	w.Header().Add("Content-Type", "application/x-chess-pgn")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pgn\"", gameID))
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(exportPGN(*game, moves)))
+}
+
+func (h *Handler) handleImport(w http.ResponseWriter, r *http.Request) {
+
+	var payload types.ImportGamePayload
+
+	if err := utils.ParseJSON(r, &payload); err != nil {
 		utils.WriteError(w, http.StatusBadRequest, err)
 		return
 	}
+	// Validate payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
 
+	record, moves, err := importPGN(payload.PGN)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	record.Owner = auth.GetUsernameFromContext(r.Context())
+
+	game, err := h.app.ImportGame(record, moves)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, game)
+}
+
+// handleSchema serves the JSON Schema of the WebSocket protocol.
+func (h *Handler) handleSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Content-Type", "application/schema+json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(protocol.SchemaFile)
 }
 
 func (h *Handler) handleGame(w http.ResponseWriter, r *http.Request) {
 
 	vars := mux.Vars(r)
 	gameID := vars["id"]
-	username := auth.GetUsernameFromJWT(r, h.userApp)
+	username := auth.GetUsernameFromContext(r.Context())
 
-	game, exists := GameStore[gameID]
+	game, exists := GameStore.Get(gameID)
 	if !exists {
 		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("game does not exist"))
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	ws, err := utils.Upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		http.Error(w, "Could not upgrade to WebSocket", http.StatusInternalServerError)
 		return
 	}
+	conn := socket.New(ws)
 	defer conn.Close()
 	fmt.Printf("WebSocket connection established for game: %s\n", gameID)
 
-	game.mu.Lock()
-	game.Connections = append(game.Connections, conn)
-	game.mu.Unlock()
+	client := &Client{Conn: conn, Username: username}
 
-	defer func() {
-		game.mu.Lock()
-		for i, connections := range game.Connections {
-			if connections == conn {
-				game.Connections = append(game.Connections[:i], game.Connections[i+1:]...)
-				fmt.Printf("WebSocket disconnected for game: %s", gameID)
-				break
-			}
+	// Everything that touches the game or writes to a socket runs on the
+	// game's goroutine, this one only reads.
+	joined := game.Do(func() {
+		client.Role = clientRole(game, username)
+		send(client, protocol.NewEnvelope("", newStateMessage(game, client.Role)))
+		for _, absence := range absentPlayers(game, username, time.Now()) {
+			send(client, protocol.NewEnvelope("", absence))
 		}
-		game.mu.Unlock()
+		addClient(game, client)
+	})
+	if !joined {
+		return
+	}
+
+	defer func() {
+		game.Do(func() { removeClient(game, client) })
+		fmt.Printf("WebSocket disconnected for game: %s", gameID)
 
 		conn.Close()
 	}()
@@ -122,37 +326,94 @@ func (h *Handler) handleGame(w http.ResponseWriter, r *http.Request) {
 	for {
 
 		// Read Incoming Message
-		var message map[string]interface{}
-		if err := conn.ReadJSON(&message); err != nil {
-			utils.SendJSON(conn, false, http.StatusBadRequest, fmt.Sprintf("error reading message %v", err))
+		var envelope protocol.Envelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			if _, ok := err.(*websocket.CloseError); !ok {
+				game.Do(func() {
+					send(client, protocol.NewError("", protocol.ErrBadRequest, fmt.Sprintf("error reading message %v", err)))
+				})
+			}
 			break
 		}
 
-		// Handle message by type
-		msgType, ok := message["type"].(string)
-		if !ok {
-			utils.SendJSON(conn, false, http.StatusBadRequest, "missing or invalid message type")
-			continue
+		if !game.Do(func() { h.handleMessage(game, client, envelope) }) {
+			break
 		}
+	}
+}
 
-		switch msgType {
+// handleMessage answers one envelope from client. It runs on the game's
+// goroutine.
+func (h *Handler) handleMessage(game *ChessGame, client *Client, envelope protocol.Envelope) {
 
-		case "move":
+	if envelope.Type == protocol.TypeHello {
+		var hello protocol.Hello
+		if err := envelope.Decode(&hello); err != nil {
+			send(client, protocol.NewError(envelope.ID, protocol.ErrBadRequest, err.Error()))
+			return
+		}
 
-			move, ok := message["move"].(string)
-			if !ok {
-				utils.SendJSON(conn, false, http.StatusBadRequest, "no move in request")
-				continue
-			}
+		if hello.Version != protocol.Version {
+			send(client, protocol.NewError(envelope.ID, protocol.ErrUnsupportedVersion, fmt.Sprintf("server speaks protocol version %d", protocol.Version)))
+			return
+		}
 
-			if err := h.handleMove(game, username, move); err != nil {
-				utils.SendJSON(conn, false, http.StatusBadRequest, err.Error())
-				continue
-			}
+		client.handshake = true
+		send(client, protocol.NewEnvelope(envelope.ID, &protocol.Welcome{Version: protocol.Version, Role: client.Role}))
+		return
+	}
+
+	if !client.handshake {
+		send(client, protocol.NewError(envelope.ID, protocol.ErrHandshakeRequired, "send hello first"))
+		return
+	}
+
+	// Spectators are read only
+	if client.Role == RoleSpectator && envelope.Type != protocol.TypeResync {
+		send(client, protocol.NewError(envelope.ID, protocol.ErrForbidden, "spectators cannot send messages"))
+		return
+	}
+
+	switch envelope.Type {
+
+	case protocol.TypeResync:
+
+		var request protocol.Resync
+		if err := envelope.Decode(&request); err != nil {
+			send(client, protocol.NewError(envelope.ID, protocol.ErrBadRequest, err.Error()))
+			return
+		}
+
+		send(client, protocol.NewEnvelope(envelope.ID, newResyncMessage(game, client.Role, request.Ply)))
+
+	case protocol.TypeMove:
+
+		var request protocol.Move
+		if err := envelope.Decode(&request); err != nil || request.Move == "" {
+			send(client, protocol.NewError(envelope.ID, protocol.ErrBadRequest, "no move in request"))
+			return
+		}
 
-		default:
-			utils.SendJSON(conn, false, http.StatusBadRequest, "missing type ('move')")
+		if err := h.handleMove(game, client.Username, request.Move); err != nil {
+			send(client, protocol.NewError(envelope.ID, protocol.ErrIllegalMove, err.Error()))
+			return
 		}
+
+		send(client, protocol.NewEnvelope(envelope.ID, &protocol.Ack{}))
+
+	case protocol.TypeResign, protocol.TypeOfferDraw, protocol.TypeAcceptDraw, protocol.TypeDeclineDraw,
+		protocol.TypeTakebackRequest, protocol.TypeTakebackAccept, protocol.TypeAbort,
+		protocol.TypeClaimVictory, protocol.TypeClaimDraw:
+
+		if err := h.handleAction(game, client.Username, envelope.Type); err != nil {
+			send(client, protocol.NewError(envelope.ID, protocol.ErrNotAllowed, err.Error()))
+			return
+		}
+
+		send(client, protocol.NewEnvelope(envelope.ID, &protocol.Ack{}))
+
+	default:
+		send(client, protocol.NewError(envelope.ID, protocol.ErrUnknownType, fmt.Sprintf("unknown message type %q", envelope.Type)))
 	}
 }
 
@@ -162,40 +423,71 @@ func (h *Handler) handleMove(game *ChessGame, username, move string) error {
 		return fmt.Errorf("game has not started")
 	}
 
-	if !(username == game.PlayerWhite || username == game.PlayerBlack) {
+	if playerColor(game, username) == chess.NoColor {
 		return fmt.Errorf("user is not part of the game")
 	}
 
-	fmt.Println(game.CurrentTurn, username, game.PlayerWhite, game.PlayerBlack)
-
 	if game.CurrentTurn == "w" {
-		if !(username == game.PlayerWhite){
-			return fmt.Errorf("not blacks turn")
+		if !(username == game.PlayerWhite) {
+			return fmt.Errorf("not whites turn")
 		}
 	}
 
 	if game.CurrentTurn == "b" {
-		if !(username == game.PlayerBlack){
+		if !(username == game.PlayerBlack) {
 			return fmt.Errorf("not blacks turn")
 		}
 	}
 
-	
-	newFen, err := MakeMove(game, move)
+	return playMove(game, move)
+}
+
+// playMove makes move for the side to move and broadcasts it, then lets a
+// bot reply.
+func playMove(game *ChessGame, move string) error {
+
+	_, err := MakeMove(game, move)
 	if err != nil {
+		if err == errOutOfTime {
+			broadcastDelayed(game, newClockMessage(game))
+			broadcastDelayed(game, newGameOverMessage(game))
+		}
 		return fmt.Errorf("error making move %v", err)
 	}
 
-	broadcastFen(game, newFen)
+	broadcastDelayed(game, newMoveMadeMessage(game))
+	broadcastDelayed(game, newClockMessage(game))
 
+	if game.Outcome != "" {
+		broadcastDelayed(game, newGameOverMessage(game))
+	}
+
+	botTurn(game)
 	return nil
 }
 
-func broadcastFen(game *ChessGame, fen string) {
-	game.mu.Lock()
-	defer game.mu.Unlock()
+// handleAction runs the resign, draw, takeback, abort or claim command
+// msgType for username and broadcasts the result.
+func (h *Handler) handleAction(game *ChessGame, username, msgType string) error {
+
+	color := playerColor(game, username)
+	if color == chess.NoColor {
+		return fmt.Errorf("user is not part of the game")
+	}
+
+	messages, err := actions[msgType](game, color, time.Now())
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		broadcastDelayed(game, message)
+	}
 
-	for _, conn := range game.Connections {
-		utils.SendJSON(conn, true, http.StatusOK, fen)
+	for _, message := range botAnswer(game) {
+		broadcastDelayed(game, message)
 	}
+	botTurn(game)
+
+	return nil
 }
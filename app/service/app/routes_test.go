@@ -0,0 +1,594 @@
+package app
+
+import (
+	"ChessApp/config"
+	"ChessApp/protocol"
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+)
+
+func TestCreateGame(t *testing.T) {
+	handler := NewHandler(&mockChessApp{}, &mockUserApp{}, nil, nil)
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), "1", "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create_payloads := []struct {
+		Name     string
+		Payload  types.NewGamePayload
+		Token    string
+		Expected int
+	}{
+		{
+			Name: "Valid Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "white",
+				InitialTime: 5,
+				TimeControl: 3,
+			},
+			Token:    token,
+			Expected: http.StatusCreated,
+		},
+		{
+			Name: "Missing JWT Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "white",
+				InitialTime: 5,
+				TimeControl: 3,
+			},
+			Expected: http.StatusUnauthorized,
+		},
+		{
+			Name: "Random Color Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "random",
+				InitialTime: 5,
+				TimeControl: 3,
+			},
+			Token:    token,
+			Expected: http.StatusCreated,
+		},
+		{
+			Name: "Unknown Color Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "green",
+				InitialTime: 5,
+				TimeControl: 3,
+			},
+			Token:    token,
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Name: "Custom FEN Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "white",
+				InitialTime: 5,
+				TimeControl: 3,
+				Rated:       true,
+				FEN:         "4k3/8/8/3pP3/8/8/8/4K3 w - d6 0 1",
+			},
+			Token:    token,
+			Expected: http.StatusCreated,
+		},
+		{
+			Name: "Illegal FEN Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "white",
+				InitialTime: 5,
+				TimeControl: 3,
+				FEN:         "4k3/8/8/8/8/8/8/4K2K w - - 0 1",
+			},
+			Token:    token,
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Name: "Checkmate FEN Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "white",
+				InitialTime: 5,
+				TimeControl: 3,
+				FEN:         "R3k3/8/4K3/8/8/8/8/8 b - - 0 1",
+			},
+			Token:    token,
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Name: "No Increment Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "white",
+				InitialTime: 5,
+			},
+			Token:    token,
+			Expected: http.StatusCreated,
+		},
+		{
+			Name: "Negative Time Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				Color:       "white",
+				InitialTime: -5,
+				TimeControl: 3,
+			},
+			Token:    token,
+			Expected: http.StatusBadRequest,
+		},
+		{
+			Name: "Missing Color Create Payload",
+			Payload: types.NewGamePayload{
+				GameMode:    "standard",
+				InitialTime: 5,
+				TimeControl: 3,
+			},
+			Token:    token,
+			Expected: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range create_payloads {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			marshalled, _ := json.Marshal(tc.Payload)
+
+			req, err := http.NewRequest(http.MethodPost, "/create", bytes.NewBuffer(marshalled))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.Token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.Token)
+			}
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+
+			router.Use(auth.Middleware(&mockUserApp{}))
+
+			auth.SetAccess(router.HandleFunc("/create", handler.createGame), auth.Authenticated)
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.Expected {
+				t.Errorf("expected status code %d, got %d", tc.Expected, rr.Code)
+			}
+
+			if rr.Code != http.StatusCreated {
+				return
+			}
+
+			var response types.CreateGameResponse
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+
+			game, ok := GameStore.Get(response.ID)
+			if !ok {
+				t.Fatal("expected game to be registered")
+			}
+
+			if tc.Payload.FEN != "" && response.Rated {
+				t.Error("expected a game from a custom position to be casual")
+			}
+
+			var seat string
+			game.Do(func() { seat = colorName(playerColor(game, "testuser")) })
+			if seat != response.Color || (tc.Payload.Color != "random" && seat != tc.Payload.Color) {
+				t.Errorf("expected creator to be seated as %s, got %q", response.Color, seat)
+			}
+		})
+	}
+}
+
+func TestCreateGameFailures(t *testing.T) {
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), "1", "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failures := []struct {
+		Name      string
+		Payload   types.NewGamePayload
+		RatingApp types.RatingApp
+		Expected  int
+		Created   bool
+	}{
+		{
+			Name:      "Rating Unavailable",
+			Payload:   types.NewGamePayload{GameMode: "standard", Color: "white", InitialTime: 5, TimeControl: 3},
+			RatingApp: &failingRatingApp{},
+			Expected:  http.StatusInternalServerError,
+		},
+		{
+			Name:     "Bot Unavailable",
+			Payload:  types.NewGamePayload{GameMode: "vs_bot", Color: "white", InitialTime: 5, TimeControl: 3, Level: 1},
+			Expected: http.StatusServiceUnavailable,
+			Created:  true,
+		},
+	}
+
+	start := StartEngine
+	StartEngine = func() (Engine, error) { return nil, fmt.Errorf("no engine") }
+	defer func() { StartEngine = start }()
+
+	for _, tc := range failures {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			chessApp := &mockChessApp{}
+			handler := NewHandler(chessApp, &mockUserApp{}, tc.RatingApp, nil)
+
+			marshalled, _ := json.Marshal(tc.Payload)
+
+			req, err := http.NewRequest(http.MethodPost, "/create", bytes.NewBuffer(marshalled))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+			router.Use(auth.Middleware(&mockUserApp{}))
+			auth.SetAccess(router.HandleFunc("/create", handler.createGame), auth.Authenticated)
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.Expected {
+				t.Fatalf("expected status code %d, got %d: %s", tc.Expected, rr.Code, rr.Body)
+			}
+			if (chessApp.created > 0) != tc.Created {
+				t.Fatalf("expected a game to be created %v, got %d", tc.Created, chessApp.created)
+			}
+			if !tc.Created {
+				return
+			}
+
+			if game, ok := GameStore.Get("mock1"); ok {
+				var outcome, method string
+				if !game.Do(func() { outcome, method = game.Outcome, game.Method }) {
+					outcome, method = game.Outcome, game.Method
+				}
+				if outcome != "*" || method != "aborted" {
+					t.Errorf("expected the game to be aborted, got %q by %q", outcome, method)
+				}
+			}
+		})
+	}
+}
+
+type mockChessApp struct {
+	created int
+	games   map[string]types.Game
+	moves   map[string][]types.Move
+}
+
+func (m *mockChessApp) CreateGame(options types.GameOptions) (*types.Game, error) {
+	m.created++
+	id := fmt.Sprintf("mock%d", m.created)
+
+	GameStore.Add(&ChessGame{
+		ID:          id,
+		InitialTime: options.InitialTime,
+		TimeControl: options.TimeControl,
+		Color:       options.Color,
+		Rated:       options.Rated,
+		Variant:     options.Variant,
+		InitialFEN:  options.InitialFEN,
+		BotLevel:    options.BotLevel,
+	})
+
+	return &types.Game{ID: id, InitialTime: options.InitialTime, TimeControl: options.TimeControl, Color: options.Color, Rated: options.Rated, Variant: options.Variant, InitialFEN: options.InitialFEN, BotLevel: options.BotLevel}, nil
+}
+
+func (m *mockChessApp) GetGameByID(id string) (*types.Game, error) {
+	game, ok := m.games[id]
+	if !ok {
+		return nil, fmt.Errorf("game not found")
+	}
+	return &game, nil
+}
+
+func (m *mockChessApp) GetUnfinishedGames() ([]types.Game, error) {
+	games := []types.Game{}
+	for _, game := range m.games {
+		if game.Status != types.GameStatusFinished {
+			games = append(games, game)
+		}
+	}
+	return games, nil
+}
+
+func (m *mockChessApp) UpdateGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) FinishGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) ImportGame(game types.Game, moves []types.Move) (*types.Game, error) {
+	game.ID = "imported"
+	return &game, nil
+}
+
+func (m *mockChessApp) CreateMove(move types.Move) error {
+	return nil
+}
+
+func (m *mockChessApp) DeleteMovesAfter(gameID string, ply int) error {
+	return nil
+}
+
+func (m *mockChessApp) GetMovesByGameID(gameID string) ([]types.Move, error) {
+	return m.moves[gameID], nil
+}
+
+type mockUserApp struct{}
+
+func (m *mockUserApp) GetUserByEmail(email string) (*types.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockUserApp) GetUserByUsername(username string) (*types.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockUserApp) GetUserByID(id string) (*types.User, error) {
+	return &types.User{ID: id, Username: "testuser"}, nil
+}
+
+func (m *mockUserApp) CreateUser(user types.User) error {
+	return nil
+}
+
+func (m *mockUserApp) CreateRefreshToken(token types.RefreshToken) error {
+	return nil
+}
+
+func (m *mockUserApp) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	return nil, fmt.Errorf("refresh token not found")
+}
+
+func (m *mockUserApp) UseRefreshToken(id string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockUserApp) RevokeRefreshTokenFamily(familyID string) error {
+	return nil
+}
+
+func TestSpectatorState(t *testing.T) {
+	handler := NewHandler(&mockChessApp{}, &mockUserApp{}, nil, nil)
+
+	game := &ChessGame{ID: "spectated", Color: "white", InitialTime: 5}
+	GameStore.Add(game)
+	game.Do(func() {
+		JoinGame(game, "alice")
+		JoinGame(game, "bob")
+		MakeMove(game, "e4")
+	})
+	defer game.Do(func() { game.Clock.stopTimer() })
+
+	router := mux.NewRouter()
+	router.Use(auth.Middleware(&mockUserApp{}))
+	auth.SetAccess(router.HandleFunc("/game/{id}", handler.handleGame), auth.Optional)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/game/spectated", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var envelope protocol.Envelope
+	if err := conn.ReadJSON(&envelope); err != nil {
+		t.Fatal(err)
+	}
+
+	var state protocol.State
+	if err := envelope.Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Role != RoleSpectator || len(state.Moves) != 1 || state.PlayerWhite != "alice" {
+		t.Errorf("unexpected state %+v", state)
+	}
+
+	conn.WriteJSON(protocol.NewEnvelope("1", &protocol.Move{Move: "e5"}))
+	expectError(t, conn, "1", protocol.ErrHandshakeRequired)
+
+	conn.WriteJSON(protocol.NewEnvelope("2", &protocol.Hello{Version: protocol.Version}))
+	conn.WriteJSON(protocol.NewEnvelope("3", &protocol.Move{Move: "e5"}))
+	expectError(t, conn, "3", protocol.ErrForbidden)
+}
+
+func TestSpectatorPromotedOnJoin(t *testing.T) {
+	handler := NewHandler(&mockChessApp{}, &mockUserApp{}, nil, nil)
+
+	game := &ChessGame{ID: "promoted", Color: "white", InitialTime: 5}
+	GameStore.Add(game)
+	game.Do(func() { JoinGame(game, "alice") })
+	defer game.Do(func() { game.Clock.stopTimer() })
+
+	token, err := auth.CreateJWT([]byte(config.Envs.JWTSecret), "1", "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	router.Use(auth.Middleware(&mockUserApp{}))
+	auth.SetAccess(router.HandleFunc("/game/{id}", handler.handleGame), auth.Optional)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	header := http.Header{"Authorization": []string{"Bearer " + token}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/game/promoted", header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	state := readState(t, conn)
+	if state.Role != RoleSpectator {
+		t.Fatalf("expected to watch before joining, got %s", state.Role)
+	}
+
+	game.Do(func() {
+		if err := JoinGame(game, "testuser"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	if state := readState(t, conn); state.Role != RolePlayer || state.PlayerBlack != "testuser" {
+		t.Errorf("expected to play black after joining, got %+v", state)
+	}
+
+	conn.WriteJSON(protocol.NewEnvelope("1", &protocol.Hello{Version: protocol.Version}))
+	conn.WriteJSON(protocol.NewEnvelope("2", &protocol.Move{Move: "e5"}))
+	expectError(t, conn, "2", protocol.ErrIllegalMove)
+}
+
+func TestDelayedSpectatorOrder(t *testing.T) {
+	handler := NewHandler(&mockChessApp{}, &mockUserApp{}, nil, nil)
+
+	game := &ChessGame{ID: "delayedorder", Color: "white", InitialTime: 5, SpectatorDelay: 1}
+	GameStore.Add(game)
+	game.Do(func() {
+		JoinGame(game, "alice")
+		JoinGame(game, "bob")
+	})
+
+	router := mux.NewRouter()
+	router.Use(auth.Middleware(&mockUserApp{}))
+	auth.SetAccess(router.HandleFunc("/game/{id}", handler.handleGame), auth.Optional)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/game/delayedorder", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	readState(t, conn)
+
+	game.Do(func() {
+		for _, move := range []string{"f3", "e5", "g4", "Qh4"} {
+			if err := playMove(game, move); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	expected := []string{}
+	for i := 0; i < 4; i++ {
+		expected = append(expected, protocol.TypeMoveMade, protocol.TypeClock)
+	}
+	expected = append(expected, protocol.TypeGameOver)
+
+	received := []string{}
+	ply := 0
+	for len(received) < len(expected) {
+		var envelope protocol.Envelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			t.Fatal(err)
+		}
+		if envelope.Type != protocol.TypeMoveMade && envelope.Type != protocol.TypeClock && envelope.Type != protocol.TypeGameOver {
+			continue
+		}
+		received = append(received, envelope.Type)
+
+		if envelope.Type == protocol.TypeMoveMade {
+			var move protocol.MoveMade
+			if err := envelope.Decode(&move); err != nil {
+				t.Fatal(err)
+			}
+			if move.Ply != ply+1 {
+				t.Errorf("expected ply %d, got %d", ply+1, move.Ply)
+			}
+			ply = move.Ply
+		}
+	}
+
+	if strings.Join(received, " ") != strings.Join(expected, " ") {
+		t.Errorf("expected spectators to get %v, got %v", expected, received)
+	}
+}
+
+// readState skips server events until the next state message.
+func readState(t *testing.T, conn *websocket.Conn) protocol.State {
+	t.Helper()
+
+	for {
+		var envelope protocol.Envelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			t.Fatal(err)
+		}
+
+		var state protocol.State
+		if envelope.Type == protocol.TypeState {
+			if err := envelope.Decode(&state); err != nil {
+				t.Fatal(err)
+			}
+			return state
+		}
+	}
+}
+
+// expectError skips server events until the reply to id and checks that it
+// is an error with code.
+func expectError(t *testing.T, conn *websocket.Conn, id, code string) {
+	t.Helper()
+
+	for {
+		var envelope protocol.Envelope
+		if err := conn.ReadJSON(&envelope); err != nil {
+			t.Fatal(err)
+		}
+
+		if envelope.ID != id {
+			continue
+		}
+
+		var reply protocol.Error
+		if err := envelope.Decode(&reply); err != nil {
+			t.Fatal(err)
+		}
+
+		if reply.Code != code {
+			t.Errorf("expected %s, got %+v", code, reply)
+		}
+		return
+	}
+}
+
+type failingRatingApp struct{}
+
+func (m *failingRatingApp) GetRating(username, timeClass string) (*types.Rating, error) {
+	return nil, fmt.Errorf("ratings unavailable")
+}
+
+func (m *failingRatingApp) GetRatings(username string) ([]types.Rating, error) {
+	return nil, fmt.Errorf("ratings unavailable")
+}
+
+func (m *failingRatingApp) RecordGame(game types.Game) error {
+	return nil
+}
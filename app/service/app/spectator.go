@@ -0,0 +1,171 @@
+package app
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/socket"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	RolePlayer    = "player"
+	RoleSpectator = "spectator"
+)
+
+type Client struct {
+	Conn     *socket.Conn
+	Username string
+	Role     string
+
+	handshake bool
+}
+
+func clientRole(game *ChessGame, username string) string {
+	if playerColor(game, username) != chess.NoColor {
+		return RolePlayer
+	}
+	return RoleSpectator
+}
+
+// The functions below run on the game's goroutine, see registry.go.
+
+func addClient(game *ChessGame, client *Client) {
+	game.Connections = append(game.Connections, client)
+
+	if reconnected := playerConnected(game, client); reconnected != nil {
+		broadcast(game, reconnected)
+	}
+	broadcastSpectators(game)
+}
+
+// promoteClients turns the sockets username opened as a spectator into
+// player connections once they take a seat, and sends them the undelayed
+// state.
+func promoteClients(game *ChessGame, username string) {
+	promoted := false
+	for _, client := range game.Connections {
+		if username == "" || client.Username != username || client.Role != RoleSpectator {
+			continue
+		}
+
+		client.Role = RolePlayer
+		if reconnected := playerConnected(game, client); reconnected != nil {
+			broadcast(game, reconnected)
+		}
+		send(client, protocol.NewEnvelope("", newStateMessage(game, client.Role)))
+		promoted = true
+	}
+
+	if promoted {
+		broadcastSpectators(game)
+	}
+}
+
+func removeClient(game *ChessGame, client *Client) {
+	for i, c := range game.Connections {
+		if c == client {
+			game.Connections = append(game.Connections[:i], game.Connections[i+1:]...)
+			break
+		}
+	}
+
+	if disconnected := playerDisconnected(game, client, time.Now()); disconnected != nil {
+		broadcast(game, disconnected)
+	}
+	broadcastSpectators(game)
+}
+
+// send queues a reply to a single client. Sends never block, a client that
+// falls too far behind is disconnected by its socket.
+func send(client *Client, envelope protocol.Envelope) {
+	client.Conn.Send(envelope)
+}
+
+func broadcast(game *ChessGame, message protocol.Message) {
+	sendToClients(game, message, func(*Client) bool { return true })
+}
+
+// delayedMessage is a message waiting to reach the spectators at due.
+type delayedMessage struct {
+	message protocol.Message
+	due     time.Time
+}
+
+// broadcastDelayed sends message to players right away and to spectators
+// after the game's spectator delay, so nobody can relay moves to a player.
+// Delayed messages wait in one queue with a single timer so spectators get
+// them in the order they were sent.
+func broadcastDelayed(game *ChessGame, message protocol.Message) {
+	delay := time.Duration(game.SpectatorDelay) * time.Second
+
+	if delay == 0 {
+		broadcast(game, message)
+		return
+	}
+
+	sendToRole(game, RolePlayer, message)
+
+	game.delayed = append(game.delayed, delayedMessage{message: message, due: time.Now().Add(delay)})
+	if game.delayedTimer == nil {
+		game.delayedTimer = time.AfterFunc(delay, func() {
+			game.Do(func() { flushDelayed(game, time.Now()) })
+		})
+	}
+}
+
+// flushDelayed sends the spectators every queued message that is due and
+// sets the timer for the next one.
+func flushDelayed(game *ChessGame, now time.Time) {
+	sent := 0
+	for _, delayed := range game.delayed {
+		if delayed.due.After(now) {
+			break
+		}
+		sendToRole(game, RoleSpectator, delayed.message)
+		sent++
+	}
+	game.delayed = game.delayed[sent:]
+
+	if len(game.delayed) == 0 {
+		game.delayedTimer = nil
+		return
+	}
+	game.delayedTimer.Reset(game.delayed[0].due.Sub(now))
+}
+
+func sendToRole(game *ChessGame, role string, message protocol.Message) {
+	sendToClients(game, message, func(client *Client) bool { return client.Role == role })
+}
+
+// sendToClients encodes message once and queues it for every matching
+// client.
+func sendToClients(game *ChessGame, message protocol.Message, match func(*Client) bool) {
+	data, err := json.Marshal(protocol.NewEnvelope("", message))
+	if err != nil {
+		log.Println("Error marshalling message:", err)
+		return
+	}
+
+	for _, client := range game.Connections {
+		if match(client) {
+			client.Conn.SendRaw(data)
+		}
+	}
+}
+
+func broadcastSpectators(game *ChessGame) {
+	broadcast(game, &protocol.Spectators{Count: countSpectators(game)})
+}
+
+func countSpectators(game *ChessGame) int {
+	count := 0
+	for _, client := range game.Connections {
+		if client.Role == RoleSpectator {
+			count++
+		}
+	}
+	return count
+}
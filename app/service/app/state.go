@@ -0,0 +1,132 @@
+package app
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/types"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func gameStatus(game *ChessGame) string {
+	switch {
+	case game.Outcome != "":
+		return types.GameStatusFinished
+	case game.GameStarted:
+		return types.GameStatusPlaying
+	default:
+		return types.GameStatusWaiting
+	}
+}
+
+// visibleMoves returns the moves role may see. Spectators do not see moves
+// younger than the spectator delay.
+func visibleMoves(game *ChessGame, role string, now time.Time) []types.Move {
+	if role != RoleSpectator {
+		return game.Moves
+	}
+	return delayedMoves(game.Moves, game.SpectatorDelay, now)
+}
+
+// delayedMoves drops the moves played less than delay seconds before now.
+func delayedMoves(moves []types.Move, delay int, now time.Time) []types.Move {
+	if delay == 0 {
+		return moves
+	}
+
+	cutoff := now.Add(-time.Duration(delay) * time.Second)
+	for len(moves) > 0 && moves[len(moves)-1].CreatedAt.After(cutoff) {
+		moves = moves[:len(moves)-1]
+	}
+	return moves
+}
+
+// newStateMessage is sent on every connect so a client never has to wait for
+// the next move to know where the game stands.
+func newStateMessage(game *ChessGame, role string) *protocol.State {
+	now := time.Now()
+
+	state := &protocol.State{
+		Version:       game.Version,
+		Role:          role,
+		Status:        gameStatus(game),
+		PlayerWhite:   game.PlayerWhite,
+		PlayerBlack:   game.PlayerBlack,
+		Variant:       game.Variant,
+		Moves:         []string{},
+		PendingOffers: pendingOffers(game),
+		Spectators:    countSpectators(game),
+	}
+
+	moves := visibleMoves(game, role, now)
+	for _, move := range moves {
+		state.Moves = append(state.Moves, move.SAN)
+	}
+	state.Ply = len(moves)
+
+	state.FEN, state.Turn, state.WhiteTime, state.BlackTime = positionAt(game, moves, now)
+
+	if len(moves) == len(game.Moves) {
+		state.Outcome = game.Outcome
+		state.Method = game.Method
+	} else {
+		state.Status = types.GameStatusPlaying
+	}
+
+	return state
+}
+
+// newResyncMessage answers a client that last saw ply with the moves it
+// missed. A ply the client cannot have seen gets the full move list.
+func newResyncMessage(game *ChessGame, role string, ply int) *protocol.MissedMoves {
+	now := time.Now()
+	moves := visibleMoves(game, role, now)
+
+	if ply < 0 || ply > len(moves) {
+		ply = 0
+	}
+
+	resync := &protocol.MissedMoves{
+		Version: game.Version,
+		FromPly: ply,
+		Moves:   append([]types.Move{}, moves[ply:]...),
+		Status:  gameStatus(game),
+	}
+
+	resync.FEN, resync.Turn, resync.WhiteTime, resync.BlackTime = positionAt(game, moves, now)
+
+	if len(moves) == len(game.Moves) {
+		resync.Outcome = game.Outcome
+		resync.Method = game.Method
+	} else {
+		resync.Status = types.GameStatusPlaying
+	}
+
+	return resync
+}
+
+// positionAt returns the position and clocks after the last of moves, which
+// may lag behind the live game for delayed spectators.
+func positionAt(game *ChessGame, moves []types.Move, now time.Time) (string, string, int64, int64) {
+	initial := (time.Duration(game.InitialTime) * time.Minute).Milliseconds()
+
+	if !game.GameStarted {
+		return startFEN(game), chess.White.String(), initial, initial
+	}
+
+	if len(moves) == len(game.Moves) {
+		turn := game.Game.Position().Turn()
+		return game.Game.Position().String(), turn.String(),
+			game.Clock.Remaining(chess.White, turn, now).Milliseconds(),
+			game.Clock.Remaining(chess.Black, turn, now).Milliseconds()
+	}
+
+	if len(moves) == 0 {
+		start := game.Game.Positions()[0]
+		return start.String(), start.Turn().String(), initial, initial
+	}
+
+	last := moves[len(moves)-1]
+	position := game.Game.Positions()[len(moves)]
+	return last.FEN, position.Turn().String(), last.WhiteTime, last.BlackTime
+}
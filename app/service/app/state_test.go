@@ -0,0 +1,48 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestResync(t *testing.T) {
+	game := &ChessGame{ID: "resync", Color: "white", InitialTime: 5}
+	JoinGame(game, "alice")
+	JoinGame(game, "bob")
+	defer game.Clock.stopTimer()
+
+	for _, move := range []string{"e4", "e5", "Nf3"} {
+		if _, err := MakeMove(game, move); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	plies := []struct {
+		Name     string
+		Ply      int
+		Expected []string
+	}{
+		{Name: "Missed Two Moves", Ply: 1, Expected: []string{"e5", "Nf3"}},
+		{Name: "Up To Date", Ply: 3, Expected: []string{}},
+		{Name: "Unknown Ply", Ply: 10, Expected: []string{"e4", "e5", "Nf3"}},
+	}
+
+	for _, tc := range plies {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			resync := newResyncMessage(game, RolePlayer, tc.Ply)
+			if len(resync.Moves) != len(tc.Expected) {
+				t.Fatalf("expected %d moves, got %d", len(tc.Expected), len(resync.Moves))
+			}
+
+			for i, move := range resync.Moves {
+				if move.SAN != tc.Expected[i] {
+					t.Errorf("expected %s, got %s", tc.Expected[i], move.SAN)
+				}
+			}
+
+			if resync.Turn != "b" || resync.FEN != game.Game.Position().String() {
+				t.Errorf("expected black to move in the current position, got %s %s", resync.Turn, resync.FEN)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package app
+
+import (
+	"ChessApp/types"
+	"ChessApp/variant"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/matoous/go-nanoid/v2"
+	"github.com/notnil/chess"
+)
+
+type App struct {
+	db        *sql.DB
+	ratingApp types.RatingApp
+}
+
+func NewApp(db *sql.DB, ratingApp types.RatingApp) *App {
+	return &App{db: db, ratingApp: ratingApp}
+}
+
+func (a *App) CreateGame(options types.GameOptions) (*types.Game, error) {
+	gameID, err := gonanoid.New(10)
+	if err != nil {
+		return nil, err
+	}
+
+	game := &ChessGame{
+		ID:             gameID,
+		InitialTime:    options.InitialTime,
+		TimeControl:    options.TimeControl,
+		Color:          options.Color,
+		Rated:          options.Rated,
+		SpectatorDelay: options.SpectatorDelay,
+		Variant:        options.Variant,
+		InitialFEN:     options.InitialFEN,
+		BotLevel:       options.BotLevel,
+		GameStarted:    false,
+		CreatedAt:      time.Now(),
+		app:            a,
+	}
+
+	if game.Variant == "" {
+		game.Variant = variant.Standard
+	}
+
+	// Chess960 games keep the start position they were dealt
+	if fen := variant.StartFEN(game.Variant); game.InitialFEN == "" && fen != variant.StandardFEN {
+		game.InitialFEN = fen
+	}
+
+	record := game.record()
+
+	_, err = a.db.Exec(
+		"INSERT INTO games (id, player_white, player_black, color, initial_time, time_control, status, outcome, method, white_time, black_time, created_at, updated_at, rated, spectator_delay, variant, initial_fen, bot_level, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		record.ID, record.PlayerWhite, record.PlayerBlack, record.Color, record.InitialTime, record.TimeControl,
+		record.Status, record.Outcome, record.Method, record.WhiteTime, record.BlackTime, record.CreatedAt, record.UpdatedAt, record.Rated, record.SpectatorDelay,
+		record.Variant, record.InitialFEN, record.BotLevel, record.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	GameStore.Add(game)
+	return &record, nil
+}
+
+// ImportGame stores a finished game owned by game.Owner together with its
+// moves.
+func (a *App) ImportGame(game types.Game, moves []types.Move) (*types.Game, error) {
+	gameID, err := gonanoid.New(10)
+	if err != nil {
+		return nil, err
+	}
+
+	game.ID = gameID
+	game.CreatedAt = time.Now()
+	game.UpdatedAt = game.CreatedAt
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(
+		"INSERT INTO games (id, player_white, player_black, color, initial_time, time_control, status, outcome, method, white_time, black_time, created_at, updated_at, rated, owner, variant, initial_fen) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		game.ID, game.PlayerWhite, game.PlayerBlack, game.Color, game.InitialTime, game.TimeControl,
+		game.Status, game.Outcome, game.Method, game.WhiteTime, game.BlackTime, game.CreatedAt, game.UpdatedAt, false, game.Owner, game.Variant, game.InitialFEN,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, move := range moves {
+		_, err = tx.Exec(
+			"INSERT INTO moves (game_id, ply, san, uci, fen, white_time, black_time, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			game.ID, move.Ply, move.SAN, move.UCI, move.FEN, move.WhiteTime, move.BlackTime, game.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &game, nil
+}
+
+func (a *App) GetGameByID(id string) (*types.Game, error) {
+
+	rows, err := a.db.Query("SELECT * FROM games WHERE id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	g := new(types.Game)
+
+	for rows.Next() {
+		g, err = scanRowIntoGame(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if g.ID == "" {
+		return nil, fmt.Errorf("game not found")
+	}
+
+	return g, nil
+}
+
+func (a *App) GetUnfinishedGames() ([]types.Game, error) {
+
+	rows, err := a.db.Query("SELECT * FROM games WHERE status != ?", types.GameStatusFinished)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	games := []types.Game{}
+
+	for rows.Next() {
+		g, err := scanRowIntoGame(rows)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, *g)
+	}
+
+	return games, rows.Err()
+}
+
+func (a *App) UpdateGame(game types.Game) error {
+
+	_, err := a.db.Exec(
+		"UPDATE games SET player_white = ?, player_black = ?, status = ?, outcome = ?, method = ?, white_time = ?, black_time = ?, updated_at = ?, version = ? WHERE id = ?",
+		game.PlayerWhite, game.PlayerBlack, game.Status, game.Outcome, game.Method, game.WhiteTime, game.BlackTime, game.UpdatedAt, game.Version, game.ID,
+	)
+	return err
+}
+
+// FinishGame saves the final state of a game and, if it was rated, updates
+// both players' ratings. Aborted games have no result and are not rated.
+func (a *App) FinishGame(game types.Game) error {
+
+	if err := a.UpdateGame(game); err != nil {
+		return err
+	}
+
+	if !game.Rated || game.Outcome == chess.NoOutcome.String() || a.ratingApp == nil {
+		return nil
+	}
+
+	return a.ratingApp.RecordGame(game)
+}
+
+func (a *App) CreateMove(move types.Move) error {
+
+	_, err := a.db.Exec(
+		"INSERT INTO moves (game_id, ply, san, uci, fen, white_time, black_time, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		move.GameID, move.Ply, move.SAN, move.UCI, move.FEN, move.WhiteTime, move.BlackTime, move.CreatedAt,
+	)
+	return err
+}
+
+func (a *App) DeleteMovesAfter(gameID string, ply int) error {
+
+	_, err := a.db.Exec("DELETE FROM moves WHERE game_id = ? AND ply > ?", gameID, ply)
+	return err
+}
+
+func (a *App) GetMovesByGameID(gameID string) ([]types.Move, error) {
+
+	rows, err := a.db.Query("SELECT * FROM moves WHERE game_id = ? ORDER BY ply", gameID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moves := []types.Move{}
+
+	for rows.Next() {
+		m, err := scanRowIntoMove(rows)
+		if err != nil {
+			return nil, err
+		}
+		moves = append(moves, *m)
+	}
+
+	return moves, rows.Err()
+}
+
+func scanRowIntoGame(rows *sql.Rows) (*types.Game, error) {
+	game := new(types.Game)
+
+	err := rows.Scan(
+		&game.ID,
+		&game.PlayerWhite,
+		&game.PlayerBlack,
+		&game.Color,
+		&game.InitialTime,
+		&game.TimeControl,
+		&game.Status,
+		&game.Outcome,
+		&game.Method,
+		&game.WhiteTime,
+		&game.BlackTime,
+		&game.CreatedAt,
+		&game.UpdatedAt,
+		&game.Rated,
+		&game.Owner,
+		&game.SpectatorDelay,
+		&game.Variant,
+		&game.InitialFEN,
+		&game.BotLevel,
+		&game.Version,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return game, nil
+}
+
+func scanRowIntoMove(rows *sql.Rows) (*types.Move, error) {
+	move := new(types.Move)
+
+	err := rows.Scan(
+		&move.GameID,
+		&move.Ply,
+		&move.SAN,
+		&move.UCI,
+		&move.FEN,
+		&move.WhiteTime,
+		&move.BlackTime,
+		&move.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return move, nil
+}
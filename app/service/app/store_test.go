@@ -0,0 +1,236 @@
+package app
+
+import (
+	"ChessApp/db"
+	"ChessApp/types"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/notnil/chess"
+)
+
+func TestStore(t *testing.T) {
+	a := NewApp(newTestDB(t), nil)
+	game := startStoredGame(t, a, "e2e4", "e7e5")
+
+	stored, err := a.GetGameByID(game.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != types.GameStatusPlaying || stored.PlayerWhite != "alice" || stored.PlayerBlack != "bob" || stored.Variant != "standard" {
+		t.Errorf("expected alice and bob to be playing a standard game, got %+v", stored)
+	}
+
+	moves, err := a.GetMovesByGameID(game.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 2 || moves[0].UCI != "e2e4" || moves[1].SAN != "e5" || moves[1].FEN == "" {
+		t.Fatalf("expected e4 and e5 to be stored, got %+v", moves)
+	}
+
+	if unfinished := unfinishedIDs(t, a); len(unfinished) != 1 || unfinished[0] != game.ID {
+		t.Errorf("expected the game to be unfinished, got %v", unfinished)
+	}
+
+	game.Do(func() {
+		if _, err := resign(game, chess.White, time.Now()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	stored, err = a.GetGameByID(game.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != types.GameStatusFinished || stored.Outcome != "0-1" || stored.Method != "resignation" {
+		t.Errorf("expected black to win by resignation, got %+v", stored)
+	}
+
+	if unfinished := unfinishedIDs(t, a); len(unfinished) != 0 {
+		t.Errorf("expected no unfinished games, got %v", unfinished)
+	}
+}
+
+func TestLoadStoredGames(t *testing.T) {
+	a := NewApp(newTestDB(t), nil)
+	game := startStoredGame(t, a, "e2e4", "e7e5")
+
+	var fen string
+	var version int
+	game.Do(func() {
+		if _, err := offerDraw(game, chess.White, time.Now()); err != nil {
+			t.Error(err)
+		}
+		fen, version = currentFEN(game), game.Version
+		game.Clock.stopTimer()
+	})
+
+	// Forget the game as a restart would
+	GameStore.remove(game.ID)
+
+	if err := LoadGames(a, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, ok := GameStore.Get(game.ID)
+	if !ok {
+		t.Fatal("expected the game to be restored")
+	}
+	defer restored.Do(func() { restored.Clock.stopTimer() })
+
+	restored.Do(func() {
+		if currentFEN(restored) != fen || restored.Version != version || restored.CurrentTurn != "w" {
+			t.Errorf("expected the position after e5 with white to move, got %s at version %d, expected %d", currentFEN(restored), restored.Version, version)
+		}
+		if restored.PlayerWhite != "alice" || restored.PlayerBlack != "bob" || !restored.GameStarted {
+			t.Errorf("expected alice and bob to be playing, got %s and %s", restored.PlayerWhite, restored.PlayerBlack)
+		}
+
+		if _, err := MakeMove(restored, "g1f3"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	moves, err := a.GetMovesByGameID(game.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(moves) != 3 || moves[2].SAN != "Nf3" {
+		t.Errorf("expected the game to carry on after the restart, got %+v", moves)
+	}
+}
+
+func TestLoadBrokenGame(t *testing.T) {
+	a := NewApp(newTestDB(t), nil)
+	game := startStoredGame(t, a, "e2e4")
+	game.Do(func() { game.Clock.stopTimer() })
+	GameStore.remove(game.ID)
+
+	// A move that cannot be replayed on the stored board
+	if err := a.CreateMove(types.Move{GameID: game.ID, Ply: 2, SAN: "e4", UCI: "e2e4"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadGames(a, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := GameStore.Get(game.ID); ok {
+		t.Error("expected the broken game not to be restored")
+	}
+
+	stored, err := a.GetGameByID(game.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != types.GameStatusFinished || stored.Outcome != chess.NoOutcome.String() || stored.Method != "aborted" {
+		t.Errorf("expected the broken game to be aborted, got %+v", stored)
+	}
+
+	if unfinished := unfinishedIDs(t, a); len(unfinished) != 0 {
+		t.Errorf("expected no unfinished games, got %v", unfinished)
+	}
+}
+
+func TestImportStoredGame(t *testing.T) {
+	a := NewApp(newTestDB(t), nil)
+
+	record, moves, err := importPGN("[Variant \"Crazyhouse\"]\n[Result \"0-1\"]\n\n1. e4 d5 2. exd5 Qxd5 3. Nc3 Qa5 4. P@b4 0-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.Owner = "alice"
+
+	imported, err := a.ImportGame(record, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := a.GetGameByID(imported.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Variant != "crazyhouse" || stored.Owner != "alice" || stored.Status != types.GameStatusFinished {
+		t.Errorf("expected alice's finished crazyhouse game, got %+v", stored)
+	}
+
+	storedMoves, err := a.GetMovesByGameID(imported.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storedMoves) != 7 || storedMoves[6].UCI != "P@b4" {
+		t.Errorf("expected the drop to be stored, got %+v", storedMoves)
+	}
+}
+
+// newTestDB opens an empty in-memory database with the current schema.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	// Every connection would get a database of its own
+	conn.SetMaxOpenConns(1)
+
+	if err := db.Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// startStoredGame creates a game through a, seats alice as white and bob as
+// black and plays moves in UCI notation.
+func startStoredGame(t *testing.T, a *App, moves ...string) *ChessGame {
+	t.Helper()
+
+	created, err := a.CreateGame(types.GameOptions{InitialTime: 5, Color: "white"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := a.GetGameByID(created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != types.GameStatusWaiting {
+		t.Errorf("expected a new game to be waiting, got %s", stored.Status)
+	}
+
+	for _, username := range []string{"alice", "bob"} {
+		if _, err := GameStore.Join(created.ID, username); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	game, _ := GameStore.Get(created.ID)
+	game.Do(func() {
+		for _, move := range moves {
+			if _, err := MakeMove(game, move); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	return game
+}
+
+func unfinishedIDs(t *testing.T, a *App) []string {
+	t.Helper()
+
+	games, err := a.GetUnfinishedGames()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := []string{}
+	for _, game := range games {
+		ids = append(ids, game.ID)
+	}
+	return ids
+}
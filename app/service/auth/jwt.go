@@ -1,24 +1,39 @@
 package auth
 
 import (
-	"ChessApp/types"
 	"ChessApp/config"
-	"time"
-	"net/http"
-	"log"
+	"ChessApp/types"
 	"fmt"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 )
 
+type Claims struct {
+	Username string `json:"username"`
+	jwt.RegisteredClaims
+}
+
 func CreateJWT(secret []byte, userID, username string) (string, error) {
 
 	expiration := time.Second * time.Duration(config.Envs.JWTExpirationInSeconds)
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID":    userID,
-		"username":  username,
-		"expiredAt": time.Now().Add(expiration).Unix(),
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, Claims{
+		Username: username,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    config.Envs.JWTIssuer,
+			Audience:  jwt.ClaimStrings{config.Envs.JWTAudience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiration)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ID:        uuid.NewString(),
+		},
 	})
 
 	tokenString, err := token.SignedString(secret)
@@ -31,50 +46,73 @@ func CreateJWT(secret []byte, userID, username string) (string, error) {
 
 }
 
-func GetUsernameFromJWT(r *http.Request, app types.UserApp) string{
+// authenticate returns the user the request's token belongs to.
+func authenticate(r *http.Request, app types.UserApp) (*types.User, error) {
 
 	tokenString := getTokenFromRequest(r)
-
-	token, err := validateToken(tokenString)
-	if err != nil {
-		log.Printf("failed to validate token: %v", err)
-		return ""
+	if tokenString == "" {
+		return nil, errMissingToken
 	}
 
-	if !token.Valid {
-		log.Println("invalid token")
-		return ""
+	claims, err := validateToken(tokenString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate token: %v", err)
 	}
 
-	claims := token.Claims.(jwt.MapClaims)
-	userID := claims["userID"].(string)
-
-	user, err := app.GetUserByID(userID)
+	user, err := app.GetUserByID(claims.Subject)
 	if err != nil {
-		log.Printf("failed to fetch user: %v", err)
-		return ""
+		return nil, fmt.Errorf("failed to fetch user: %v", err)
 	}
 
-	return user.Username
+	return user, nil
 
 }
 
-func validateToken(token string) (*jwt.Token, error) {
-	return jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected singing method: %v", token.Header["alg"])
-		}
+// validateToken checks the signature and every registered claim. exp is
+// required, nbf is checked by jwt/v5 whenever it is present.
+func validateToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		return []byte(config.Envs.JWTSecret), nil
-	})
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithIssuer(config.Envs.JWTIssuer),
+		jwt.WithAudience(config.Envs.JWTAudience),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.Subject == "" {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
 }
 
 func getTokenFromRequest(r *http.Request) string {
 	tokenAuth := r.Header.Get("Authorization")
 
-	if tokenAuth == "" {
-		return ""
-	}
+	if tokenAuth != "" {
+		scheme, token, found := strings.Cut(tokenAuth, " ")
+		if !found {
+			return tokenAuth
+		}
+
+		if !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
 
-	return tokenAuth
+		return strings.TrimSpace(token)
+	}
 
-}
\ No newline at end of file
+	// Browsers cannot set headers on WebSocket upgrades. Anywhere else the
+	// token would only end up in access logs
+	if !websocket.IsWebSocketUpgrade(r) {
+		return ""
+	}
+	return r.URL.Query().Get("token")
+}
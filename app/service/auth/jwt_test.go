@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"ChessApp/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/mux"
+)
+
+func TestValidateToken(t *testing.T) {
+	secret := []byte(config.Envs.JWTSecret)
+
+	valid, err := CreateJWT(secret, "1", "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := signClaims(t, secret, Claims{
+		Username: "testuser",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "1",
+			Issuer:    config.Envs.JWTIssuer,
+			Audience:  jwt.ClaimStrings{config.Envs.JWTAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		},
+	})
+
+	noExpiry := signClaims(t, secret, Claims{
+		Username: "testuser",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  "1",
+			Issuer:   config.Envs.JWTIssuer,
+			Audience: jwt.ClaimStrings{config.Envs.JWTAudience},
+		},
+	})
+
+	wrongSecret, err := CreateJWT([]byte("wrong"), "1", "testuser")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := []struct {
+		Name     string
+		Token    string
+		Expected bool
+	}{
+		{Name: "Valid Token", Token: valid, Expected: true},
+		{Name: "Expired Token", Token: expired, Expected: false},
+		{Name: "Token Without Expiry", Token: noExpiry, Expected: false},
+		{Name: "Wrong Secret Token", Token: wrongSecret, Expected: false},
+		{Name: "Garbage Token", Token: "not.a.token", Expected: false},
+	}
+
+	for _, tc := range tokens {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			claims, err := validateToken(tc.Token)
+			if (err == nil) != tc.Expected {
+				t.Errorf("expected valid %v, got error %v", tc.Expected, err)
+			}
+
+			if err == nil && claims.Subject != "1" {
+				t.Errorf("expected subject 1, got %s", claims.Subject)
+			}
+		})
+	}
+}
+
+func TestGetTokenFromRequest(t *testing.T) {
+	headers := []struct {
+		Name     string
+		Header   string
+		Expected string
+	}{
+		{Name: "Bearer Header", Header: "Bearer abc", Expected: "abc"},
+		{Name: "Lowercase Bearer Header", Header: "bearer abc", Expected: "abc"},
+		{Name: "Raw Header", Header: "abc", Expected: "abc"},
+		{Name: "Basic Header", Header: "Basic abc", Expected: ""},
+		{Name: "Empty Header", Header: "", Expected: ""},
+	}
+
+	for _, tc := range headers {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodGet, "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", tc.Header)
+
+			if got := getTokenFromRequest(req); got != tc.Expected {
+				t.Errorf("expected %q, got %q", tc.Expected, got)
+			}
+		})
+	}
+
+	queryTokens := []struct {
+		Name     string
+		Upgrade  bool
+		Expected string
+	}{
+		{Name: "WebSocket Upgrade", Upgrade: true, Expected: "abc"},
+		{Name: "Plain Request", Upgrade: false, Expected: ""},
+	}
+
+	for _, tc := range queryTokens {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodGet, "/?token=abc", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.Upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+
+			if got := getTokenFromRequest(req); got != tc.Expected {
+				t.Errorf("expected %q, got %q", tc.Expected, got)
+			}
+		})
+	}
+}
+
+func TestUndeclaredRouteNeedsToken(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware(nil))
+	router.HandleFunc("/undeclared", func(w http.ResponseWriter, r *http.Request) {})
+	SetAccess(router.HandleFunc("/public", func(w http.ResponseWriter, r *http.Request) {}), Public)
+
+	routes := []struct {
+		Name     string
+		Path     string
+		Expected int
+	}{
+		{Name: "Undeclared Route", Path: "/undeclared", Expected: http.StatusUnauthorized},
+		{Name: "Public Route", Path: "/public", Expected: http.StatusOK},
+	}
+
+	for _, tc := range routes {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodGet, tc.Path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tc.Expected {
+				t.Errorf("expected status code %d, got %d", tc.Expected, rr.Code)
+			}
+		})
+	}
+}
+
+func signClaims(t *testing.T, secret []byte, claims Claims) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"ChessApp/types"
+	"ChessApp/utils"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+type Access int
+
+// Authenticated comes first so that the zero value of Access never opens a
+// route by mistake.
+const (
+	// Authenticated routes answer 401 without a valid token.
+	Authenticated Access = iota
+	// Public routes never look at the token.
+	Public
+	// Optional routes add the user to the context when the token is valid
+	// and let anonymous requests through otherwise.
+	Optional
+)
+
+type contextKey string
+
+const (
+	userIDKey   contextKey = "userID"
+	usernameKey contextKey = "username"
+)
+
+var errMissingToken = fmt.Errorf("missing token")
+
+var (
+	routeAccess   = make(map[*mux.Route]Access)
+	routeAccessMu sync.RWMutex
+)
+
+// SetAccess declares who may call route. Routes without a declaration need
+// a valid token, so public routes have to declare themselves.
+func SetAccess(route *mux.Route, access Access) *mux.Route {
+	routeAccessMu.Lock()
+	defer routeAccessMu.Unlock()
+
+	routeAccess[route] = access
+	return route
+}
+
+func getAccess(r *http.Request) Access {
+	// Without a route the router answers 404, there is nothing to protect
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return Public
+	}
+
+	routeAccessMu.RLock()
+	defer routeAccessMu.RUnlock()
+
+	access, ok := routeAccess[route]
+	if !ok {
+		return Authenticated
+	}
+	return access
+}
+
+// Middleware validates the token of requests to non-public routes and puts
+// the user ID and username into the request context.
+func Middleware(app types.UserApp) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			access := getAccess(r)
+			if access == Public {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			user, err := authenticate(r, app)
+			if err != nil {
+				if access == Optional {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				log.Printf("auth: %v", err)
+				utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("permission denied"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), userIDKey, user.ID)
+			ctx = context.WithValue(ctx, usernameKey, user.Username)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func GetUserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(userIDKey).(string)
+	return userID
+}
+
+func GetUsernameFromContext(ctx context.Context) string {
+	username, _ := ctx.Value(usernameKey).(string)
+	return username
+}
@@ -0,0 +1,209 @@
+package challenge
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/service/app"
+	"ChessApp/types"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/matoous/go-nanoid/v2"
+)
+
+// App keeps the pending direct challenges. They only live in memory: a
+// challenge nobody answers before it expires is simply dropped.
+type App struct {
+	chessApp   types.ChessApp
+	userApp    types.UserApp
+	lobby      types.Lobby
+	ttl        time.Duration
+	challenges map[string]*pending
+	mu         sync.Mutex
+}
+
+var errNotFound = fmt.Errorf("challenge not found")
+
+type pending struct {
+	challenge types.Challenge
+	expiry    *time.Timer
+}
+
+func NewApp(chessApp types.ChessApp, userApp types.UserApp, lobby types.Lobby, ttl time.Duration) *App {
+	return &App{
+		chessApp:   chessApp,
+		userApp:    userApp,
+		lobby:      lobby,
+		ttl:        ttl,
+		challenges: make(map[string]*pending),
+	}
+}
+
+// Create challenges payload.Username on behalf of challenger and notifies
+// the target.
+func (a *App) Create(challenger string, payload types.ChallengePayload) (*types.Challenge, error) {
+	if payload.Username == challenger {
+		return nil, fmt.Errorf("cannot challenge yourself")
+	}
+
+	if _, err := a.userApp.GetUserByUsername(payload.Username); err != nil {
+		return nil, fmt.Errorf("user %s not found", payload.Username)
+	}
+
+	id, err := gonanoid.New(10)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, p := range a.challenges {
+		if p.challenge.Challenger == challenger && p.challenge.Target == payload.Username {
+			return nil, fmt.Errorf("already challenged %s", payload.Username)
+		}
+	}
+
+	now := time.Now()
+	challenge := types.Challenge{
+		ID:          id,
+		Challenger:  challenger,
+		Target:      payload.Username,
+		InitialTime: payload.InitialTime,
+		TimeControl: payload.TimeControl,
+		Color:       payload.Color,
+		Rated:       payload.Rated,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(a.ttl),
+	}
+
+	a.challenges[id] = &pending{
+		challenge: challenge,
+		expiry:    time.AfterFunc(a.ttl, func() { a.expire(id) }),
+	}
+
+	a.send(challenge.Target, &protocol.ChallengeReceived{Challenge: challenge})
+	return &challenge, nil
+}
+
+// List returns the challenges username received and sent, oldest first.
+func (a *App) List(username string) types.ChallengesResponse {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	response := types.ChallengesResponse{
+		Incoming: []types.Challenge{},
+		Outgoing: []types.Challenge{},
+	}
+
+	for _, p := range a.challenges {
+		switch username {
+		case p.challenge.Target:
+			response.Incoming = append(response.Incoming, p.challenge)
+		case p.challenge.Challenger:
+			response.Outgoing = append(response.Outgoing, p.challenge)
+		}
+	}
+
+	for _, challenges := range [][]types.Challenge{response.Incoming, response.Outgoing} {
+		sort.Slice(challenges, func(i, j int) bool {
+			return challenges[i].CreatedAt.Before(challenges[j].CreatedAt)
+		})
+	}
+
+	return response
+}
+
+// Accept creates the game for a challenge sent to username and seats both
+// players in it.
+func (a *App) Accept(id, username string) (*types.AcceptChallengeResponse, error) {
+	challenge, err := a.take(id, func(c types.Challenge) bool { return c.Target == username })
+	if err != nil {
+		return nil, err
+	}
+
+	white, black := challenge.Challenger, challenge.Target
+	if challenge.Color == "black" || (challenge.Color == "random" && rand.Intn(2) == 0) {
+		white, black = black, white
+	}
+
+	created, err := app.CreatePairedGame(a.chessApp, types.GameOptions{
+		InitialTime: challenge.InitialTime,
+		TimeControl: challenge.TimeControl,
+		Rated:       challenge.Rated,
+	}, white, black)
+	if err != nil {
+		return nil, err
+	}
+
+	a.send(white, &protocol.ChallengeAccepted{ChallengeID: id, GameID: created.ID, Color: "white", Opponent: black})
+	a.send(black, &protocol.ChallengeAccepted{ChallengeID: id, GameID: created.ID, Color: "black", Opponent: white})
+
+	response := &types.AcceptChallengeResponse{GameID: created.ID, Color: "white", Opponent: black}
+	if username == black {
+		response.Color, response.Opponent = "black", white
+	}
+	return response, nil
+}
+
+// Decline turns down a challenge sent to username.
+func (a *App) Decline(id, username, reason string) error {
+	challenge, err := a.take(id, func(c types.Challenge) bool { return c.Target == username })
+	if err != nil {
+		return err
+	}
+
+	a.send(challenge.Challenger, &protocol.ChallengeDeclined{ChallengeID: id, Reason: reason})
+	return nil
+}
+
+// Cancel withdraws a challenge username sent.
+func (a *App) Cancel(id, username string) error {
+	challenge, err := a.take(id, func(c types.Challenge) bool { return c.Challenger == username })
+	if err != nil {
+		return err
+	}
+
+	a.cancelled(challenge, "cancelled")
+	return nil
+}
+
+func (a *App) expire(id string) {
+	challenge, err := a.take(id, func(types.Challenge) bool { return true })
+	if err != nil {
+		return
+	}
+
+	a.cancelled(challenge, "expired")
+}
+
+// take removes the challenge with id if allowed accepts it. Each challenge
+// is taken at most once, so it cannot be both accepted and expired.
+func (a *App) take(id string, allowed func(types.Challenge) bool) (types.Challenge, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, ok := a.challenges[id]
+	if !ok || !allowed(p.challenge) {
+		return types.Challenge{}, errNotFound
+	}
+
+	p.expiry.Stop()
+	delete(a.challenges, id)
+	return p.challenge, nil
+}
+
+func (a *App) cancelled(challenge types.Challenge, reason string) {
+	message := &protocol.ChallengeCancelled{ChallengeID: challenge.ID, Reason: reason}
+	a.send(challenge.Challenger, message)
+	a.send(challenge.Target, message)
+}
+
+func (a *App) send(username string, message protocol.Message) {
+	if a.lobby == nil {
+		return
+	}
+	a.lobby.SendToUser(username, protocol.NewEnvelope("", message))
+}
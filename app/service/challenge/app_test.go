@@ -0,0 +1,316 @@
+package challenge
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/service/app"
+	"ChessApp/types"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCreateChallenge(t *testing.T) {
+	a := NewApp(&mockChessApp{}, &mockUserApp{}, &mockLobby{}, time.Minute)
+
+	challenges := []struct {
+		Name       string
+		Challenger string
+		Target     string
+		Valid      bool
+	}{
+		{Name: "Valid Challenge", Challenger: "alice", Target: "bob", Valid: true},
+		{Name: "Duplicate Challenge", Challenger: "alice", Target: "bob", Valid: false},
+		{Name: "Challenge Back", Challenger: "bob", Target: "alice", Valid: true},
+		{Name: "Challenge Yourself", Challenger: "alice", Target: "alice", Valid: false},
+		{Name: "Unknown User", Challenger: "alice", Target: "nobody", Valid: false},
+	}
+
+	for _, tc := range challenges {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			_, err := a.Create(tc.Challenger, types.ChallengePayload{Username: tc.Target, InitialTime: 5, Color: "white"})
+			if (err == nil) != tc.Valid {
+				t.Errorf("expected valid %v, got %v", tc.Valid, err)
+			}
+		})
+	}
+
+	list := a.List("alice")
+	if len(list.Outgoing) != 1 || len(list.Incoming) != 1 {
+		t.Errorf("expected one incoming and one outgoing challenge, got %+v", list)
+	}
+}
+
+func TestAnswerChallenge(t *testing.T) {
+	answers := []struct {
+		Name     string
+		Answer   func(a *App, id string) error
+		Expected map[string]string
+	}{
+		{
+			Name: "Accept",
+			Answer: func(a *App, id string) error {
+				_, err := a.Accept(id, "bob")
+				return err
+			},
+			Expected: map[string]string{"alice": protocol.TypeChallengeAccepted, "bob": protocol.TypeChallengeAccepted},
+		},
+		{
+			Name: "Decline",
+			Answer: func(a *App, id string) error {
+				return a.Decline(id, "bob", "busy")
+			},
+			Expected: map[string]string{"alice": protocol.TypeChallengeDeclined},
+		},
+		{
+			Name: "Accepted By Challenger",
+			Answer: func(a *App, id string) error {
+				_, err := a.Accept(id, "alice")
+				if err == nil {
+					return fmt.Errorf("challenger accepted their own challenge")
+				}
+				return nil
+			},
+			Expected: map[string]string{},
+		},
+		{
+			Name: "Expire",
+			Answer: func(a *App, id string) error {
+				time.Sleep(100 * time.Millisecond)
+				return nil
+			},
+			Expected: map[string]string{"alice": protocol.TypeChallengeCancelled, "bob": protocol.TypeChallengeCancelled},
+		},
+	}
+
+	for _, tc := range answers {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			lobby := &mockLobby{}
+			a := NewApp(&mockChessApp{}, &mockUserApp{}, lobby, 50*time.Millisecond)
+
+			challenge, err := a.Create("alice", types.ChallengePayload{Username: "bob", InitialTime: 5, Color: "black"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			lobby.reset()
+
+			if err := tc.Answer(a, challenge.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			received := lobby.received()
+			if len(received) != len(tc.Expected) {
+				t.Errorf("expected %v, got %v", tc.Expected, received)
+			}
+			for username, msgType := range tc.Expected {
+				if received[username] != msgType {
+					t.Errorf("expected %s to receive %s, got %s", username, msgType, received[username])
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptSeatsPlayers(t *testing.T) {
+	a := NewApp(&mockChessApp{}, &mockUserApp{}, nil, time.Minute)
+
+	challenge, err := a.Create("alice", types.ChallengePayload{Username: "bob", InitialTime: 5, Color: "black"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := a.Accept(challenge.ID, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Color != "white" || response.Opponent != "alice" {
+		t.Errorf("expected bob to play white against alice, got %+v", response)
+	}
+
+	game, ok := app.GameStore.Get(response.GameID)
+	if !ok {
+		t.Fatal("expected game to be registered")
+	}
+
+	var white, black string
+	var started bool
+	game.Do(func() {
+		white, black, started = game.PlayerWhite, game.PlayerBlack, game.GameStarted
+		game.Clock.Stop(time.Now(), game.Game.Position().Turn())
+	})
+
+	if white != "bob" || black != "alice" || !started {
+		t.Errorf("expected started game bob vs alice, got %s vs %s", white, black)
+	}
+
+	if _, err := a.Accept(challenge.ID, "bob"); err != errNotFound {
+		t.Errorf("expected accepted challenge to be gone, got %v", err)
+	}
+}
+
+func TestAcceptAbortsUnseatedGame(t *testing.T) {
+	chessApp := &mockChessApp{seated: []string{"carol", "dave"}}
+	a := NewApp(chessApp, &mockUserApp{}, nil, time.Minute)
+
+	challenge, err := a.Create("alice", types.ChallengePayload{Username: "bob", InitialTime: 5, Color: "white"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.Accept(challenge.ID, "bob"); err == nil {
+		t.Fatal("expected the players not to be seated")
+	}
+
+	game := chessApp.games[0]
+
+	// A retired game has no goroutine left to ask
+	var outcome, method string
+	if !game.Do(func() { outcome, method = game.Outcome, game.Method }) {
+		outcome, method = game.Outcome, game.Method
+	}
+
+	if outcome != "*" || method != "aborted" {
+		t.Errorf("expected the game to be aborted, got %q by %q", outcome, method)
+	}
+}
+
+type mockChessApp struct {
+	created int
+	// Players already in every created game
+	seated []string
+	games  []*app.ChessGame
+	mu     sync.Mutex
+}
+
+func (m *mockChessApp) CreateGame(options types.GameOptions) (*types.Game, error) {
+	m.mu.Lock()
+	m.created++
+	id := fmt.Sprintf("challenge%d", m.created)
+	m.mu.Unlock()
+
+	game := &app.ChessGame{
+		ID:          id,
+		InitialTime: options.InitialTime,
+		TimeControl: options.TimeControl,
+		Color:       options.Color,
+		Rated:       options.Rated,
+	}
+	if len(m.seated) == 2 {
+		game.PlayerWhite, game.PlayerBlack = m.seated[0], m.seated[1]
+	}
+	app.GameStore.Add(game)
+
+	m.mu.Lock()
+	m.games = append(m.games, game)
+	m.mu.Unlock()
+
+	return &types.Game{ID: id, InitialTime: options.InitialTime, TimeControl: options.TimeControl, Color: options.Color, Rated: options.Rated}, nil
+}
+
+func (m *mockChessApp) GetGameByID(id string) (*types.Game, error) {
+	return nil, fmt.Errorf("game not found")
+}
+
+func (m *mockChessApp) GetUnfinishedGames() ([]types.Game, error) {
+	return nil, nil
+}
+
+func (m *mockChessApp) UpdateGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) FinishGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) ImportGame(game types.Game, moves []types.Move) (*types.Game, error) {
+	return &game, nil
+}
+
+func (m *mockChessApp) CreateMove(move types.Move) error {
+	return nil
+}
+
+func (m *mockChessApp) DeleteMovesAfter(gameID string, ply int) error {
+	return nil
+}
+
+func (m *mockChessApp) GetMovesByGameID(gameID string) ([]types.Move, error) {
+	return nil, nil
+}
+
+type mockUserApp struct{}
+
+func (m *mockUserApp) GetUserByEmail(email string) (*types.User, error) {
+	return nil, fmt.Errorf("user not found")
+}
+
+func (m *mockUserApp) GetUserByUsername(username string) (*types.User, error) {
+	if username == "nobody" {
+		return nil, fmt.Errorf("user not found")
+	}
+	return &types.User{ID: username, Username: username}, nil
+}
+
+func (m *mockUserApp) GetUserByID(id string) (*types.User, error) {
+	return &types.User{ID: id, Username: id}, nil
+}
+
+func (m *mockUserApp) CreateUser(user types.User) error {
+	return nil
+}
+
+func (m *mockUserApp) CreateRefreshToken(token types.RefreshToken) error {
+	return nil
+}
+
+func (m *mockUserApp) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	return nil, fmt.Errorf("refresh token not found")
+}
+
+func (m *mockUserApp) UseRefreshToken(id string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockUserApp) RevokeRefreshTokenFamily(familyID string) error {
+	return nil
+}
+
+// mockLobby remembers the last message type each user was sent.
+type mockLobby struct {
+	messages map[string]string
+	mu       sync.Mutex
+}
+
+func (m *mockLobby) Broadcast(message any) {}
+
+func (m *mockLobby) SendToUser(username string, message any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.messages == nil {
+		m.messages = make(map[string]string)
+	}
+	m.messages[username] = message.(protocol.Envelope).Type
+}
+
+func (m *mockLobby) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
+
+func (m *mockLobby) received() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	received := map[string]string{}
+	for username, msgType := range m.messages {
+		received[username] = msgType
+	}
+	return received
+}
@@ -0,0 +1,121 @@
+package challenge
+
+import (
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"ChessApp/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	app *App
+}
+
+func NewHandler(app *App) *Handler {
+	return &Handler{app: app}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	auth.SetAccess(router.HandleFunc("/challenges", h.handleCreate).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/challenges", h.handleList).Methods(http.MethodGet), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/challenges/{id}/accept", h.handleAccept).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/challenges/{id}/decline", h.handleDecline).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/challenges/{id}", h.handleCancel).Methods(http.MethodDelete), auth.Authenticated)
+}
+
+func (h *Handler) handleCreate(w http.ResponseWriter, r *http.Request) {
+
+	var payload types.ChallengePayload
+
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	// Validate payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	username := auth.GetUsernameFromContext(r.Context())
+
+	challenge, err := h.app.Create(username, payload)
+	if err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, challenge)
+}
+
+func (h *Handler) handleList(w http.ResponseWriter, r *http.Request) {
+
+	username := auth.GetUsernameFromContext(r.Context())
+
+	utils.WriteJSON(w, http.StatusOK, h.app.List(username))
+}
+
+func (h *Handler) handleAccept(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	username := auth.GetUsernameFromContext(r.Context())
+
+	response, err := h.app.Accept(vars["id"], username)
+	if err == errNotFound {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusCreated, response)
+}
+
+func (h *Handler) handleDecline(w http.ResponseWriter, r *http.Request) {
+
+	var payload types.DeclineChallengePayload
+
+	// The reason is optional, so is the body
+	if r.ContentLength != 0 {
+		if err := utils.ParseJSON(r, &payload); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+	// Validate payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	vars := mux.Vars(r)
+	username := auth.GetUsernameFromContext(r.Context())
+
+	if err := h.app.Decline(vars["id"], username, payload.Reason); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleCancel(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	username := auth.GetUsernameFromContext(r.Context())
+
+	if err := h.app.Cancel(vars["id"], username); err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,86 @@
+package lobby
+
+import (
+	"ChessApp/socket"
+	"sync"
+)
+
+// Hub keeps the lobby sockets of every online user so other services can
+// notify them outside of a game.
+type Hub struct {
+	clients map[string][]*socket.Conn
+	leave   []func(username string)
+	mu      sync.Mutex
+}
+
+func NewHub() *Hub {
+	return &Hub{clients: make(map[string][]*socket.Conn)}
+}
+
+func (h *Hub) Add(username string, conn *socket.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clients[username] = append(h.clients[username], conn)
+}
+
+// OnLeave registers fn to be called when the last lobby socket of a user
+// is removed.
+func (h *Hub) OnLeave(fn func(username string)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.leave = append(h.leave, fn)
+}
+
+func (h *Hub) Remove(username string, conn *socket.Conn) {
+	h.mu.Lock()
+
+	conns, ok := h.clients[username]
+	if !ok {
+		h.mu.Unlock()
+		return
+	}
+
+	for i, c := range conns {
+		if c == conn {
+			conns = append(conns[:i], conns[i+1:]...)
+			break
+		}
+	}
+
+	if len(conns) > 0 {
+		h.clients[username] = conns
+		h.mu.Unlock()
+		return
+	}
+
+	delete(h.clients, username)
+	leave := h.leave
+	h.mu.Unlock()
+
+	// Called without the lock so they may message the hub themselves
+	for _, fn := range leave {
+		fn(username)
+	}
+}
+
+func (h *Hub) SendToUser(username string, message any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, conn := range h.clients[username] {
+		conn.Send(message)
+	}
+}
+
+func (h *Hub) Broadcast(message any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, conns := range h.clients {
+		for _, conn := range conns {
+			conn.Send(message)
+		}
+	}
+}
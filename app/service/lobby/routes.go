@@ -0,0 +1,50 @@
+package lobby
+
+import (
+	"ChessApp/service/auth"
+	"ChessApp/socket"
+	"ChessApp/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	hub *Hub
+}
+
+func NewHandler(hub *Hub) *Handler {
+	return &Handler{hub: hub}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	auth.SetAccess(router.HandleFunc("/lobby", h.handleLobby).Methods(http.MethodGet), auth.Authenticated)
+}
+
+func (h *Handler) handleLobby(w http.ResponseWriter, r *http.Request) {
+
+	username := auth.GetUsernameFromContext(r.Context())
+
+	ws, err := utils.Upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		http.Error(w, "Could not upgrade to WebSocket", http.StatusInternalServerError)
+		return
+	}
+	conn := socket.New(ws)
+	fmt.Printf("Lobby connection established for user: %s\n", username)
+
+	h.hub.Add(username, conn)
+
+	defer func() {
+		h.hub.Remove(username, conn)
+		conn.Close()
+	}()
+
+	// The lobby is push only, reading just notices when the client leaves
+	for {
+		if _, err := conn.ReadMessage(); err != nil {
+			break
+		}
+	}
+}
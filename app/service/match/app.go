@@ -0,0 +1,256 @@
+package match
+
+import (
+	"ChessApp/protocol"
+	"ChessApp/service/app"
+	"ChessApp/service/lobby"
+	"ChessApp/service/rating"
+	"ChessApp/types"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRating = int(rating.DefaultRating)
+
+	// The rating window starts at baseWindow and grows by windowGrowth for
+	// every second a seek waits, up to maxWindow.
+	baseWindow   = 100
+	windowGrowth = 10
+	maxWindow    = 700
+)
+
+type Seek struct {
+	Username    string
+	Rating      int
+	InitialTime int
+	TimeControl int
+	CreatedAt   time.Time
+}
+
+type queueKey struct {
+	InitialTime int
+	TimeControl int
+}
+
+type Matchmaker struct {
+	chessApp  types.ChessApp
+	ratingApp types.RatingApp
+	hub       *lobby.Hub
+	queues    map[queueKey][]*Seek
+	mu        sync.Mutex
+}
+
+// NewMatchmaker creates a matchmaker that sends matches through hub. Users
+// leave the queue when their last lobby socket closes, as they could no
+// longer be told about a match.
+func NewMatchmaker(chessApp types.ChessApp, ratingApp types.RatingApp, hub *lobby.Hub) *Matchmaker {
+	m := &Matchmaker{
+		chessApp:  chessApp,
+		ratingApp: ratingApp,
+		hub:       hub,
+		queues:    make(map[queueKey][]*Seek),
+	}
+
+	hub.OnLeave(func(username string) { m.Cancel(username) })
+
+	return m
+}
+
+// Run pairs the queues every interval until stop is closed.
+func (m *Matchmaker) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.pair(time.Now())
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (m *Matchmaker) Seek(username string, initialTime, timeControl int) error {
+	seekRating := defaultRating
+	if m.ratingApp != nil {
+		r, err := m.ratingApp.GetRating(username, rating.TimeClass(initialTime, timeControl))
+		if err != nil {
+			return err
+		}
+		seekRating = int(r.Rating)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findSeek(username) != nil {
+		return fmt.Errorf("already seeking a game")
+	}
+
+	key := queueKey{InitialTime: initialTime, TimeControl: timeControl}
+	m.queues[key] = append(m.queues[key], &Seek{
+		Username:    username,
+		Rating:      seekRating,
+		InitialTime: initialTime,
+		TimeControl: timeControl,
+		CreatedAt:   time.Now(),
+	})
+
+	return nil
+}
+
+func (m *Matchmaker) Cancel(username string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, queue := range m.queues {
+		for i, seek := range queue {
+			if seek.Username == username {
+				m.queues[key] = append(queue[:i], queue[i+1:]...)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (m *Matchmaker) Stats() []types.QueueStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stats := []types.QueueStats{}
+	for key, queue := range m.queues {
+		if len(queue) == 0 {
+			continue
+		}
+
+		stats = append(stats, types.QueueStats{
+			InitialTime: key.InitialTime,
+			TimeControl: key.TimeControl,
+			Seekers:     len(queue),
+		})
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].InitialTime != stats[j].InitialTime {
+			return stats[i].InitialTime < stats[j].InitialTime
+		}
+		return stats[i].TimeControl < stats[j].TimeControl
+	})
+
+	return stats
+}
+
+func (m *Matchmaker) findSeek(username string) *Seek {
+	for _, queue := range m.queues {
+		for _, seek := range queue {
+			if seek.Username == username {
+				return seek
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Matchmaker) pair(now time.Time) {
+	m.mu.Lock()
+
+	pairs := [][2]*Seek{}
+	for key, queue := range m.queues {
+		matched, rest := findPairs(queue, now)
+		pairs = append(pairs, matched...)
+		m.queues[key] = rest
+	}
+
+	m.mu.Unlock()
+
+	for _, pair := range pairs {
+		if err := m.startGame(pair[0], pair[1]); err != nil {
+			log.Printf("failed to start matched game for %s and %s: %v", pair[0].Username, pair[1].Username, err)
+		}
+	}
+}
+
+// findPairs matches the oldest seeks first. Two seeks are compatible when
+// their ratings are within both of their windows.
+func findPairs(queue []*Seek, now time.Time) ([][2]*Seek, []*Seek) {
+	pairs := [][2]*Seek{}
+	matched := make([]bool, len(queue))
+
+	for i, a := range queue {
+		if matched[i] {
+			continue
+		}
+
+		for j := i + 1; j < len(queue); j++ {
+			b := queue[j]
+			if matched[j] {
+				continue
+			}
+
+			window := min(ratingWindow(a, now), ratingWindow(b, now))
+			if abs(a.Rating-b.Rating) <= window {
+				matched[i], matched[j] = true, true
+				pairs = append(pairs, [2]*Seek{a, b})
+				break
+			}
+		}
+	}
+
+	rest := []*Seek{}
+	for i, seek := range queue {
+		if !matched[i] {
+			rest = append(rest, seek)
+		}
+	}
+
+	return pairs, rest
+}
+
+func ratingWindow(seek *Seek, now time.Time) int {
+	waited := int(now.Sub(seek.CreatedAt).Seconds())
+	return min(baseWindow+waited*windowGrowth, maxWindow)
+}
+
+func (m *Matchmaker) startGame(a, b *Seek) error {
+	white, black := a, b
+	if rand.Intn(2) == 0 {
+		white, black = b, a
+	}
+
+	created, err := app.CreatePairedGame(m.chessApp, types.GameOptions{
+		InitialTime: a.InitialTime,
+		TimeControl: a.TimeControl,
+		Rated:       true,
+	}, white.Username, black.Username)
+	if err != nil {
+		return err
+	}
+
+	m.hub.SendToUser(white.Username, protocol.NewEnvelope("", &protocol.MatchFound{
+		GameID:   created.ID,
+		Color:    "white",
+		Opponent: black.Username,
+	}))
+	m.hub.SendToUser(black.Username, protocol.NewEnvelope("", &protocol.MatchFound{
+		GameID:   created.ID,
+		Color:    "black",
+		Opponent: white.Username,
+	}))
+
+	return nil
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,239 @@
+package match
+
+import (
+	"ChessApp/service/app"
+	"ChessApp/service/lobby"
+	"ChessApp/socket"
+	"ChessApp/types"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFindPairs(t *testing.T) {
+	now := time.Now()
+
+	queues := []struct {
+		Name     string
+		Queue    []*Seek
+		Expected int
+	}{
+		{
+			Name: "Close Ratings",
+			Queue: []*Seek{
+				{Username: "a", Rating: 1500, CreatedAt: now},
+				{Username: "b", Rating: 1550, CreatedAt: now},
+			},
+			Expected: 1,
+		},
+		{
+			Name: "Far Ratings",
+			Queue: []*Seek{
+				{Username: "a", Rating: 1500, CreatedAt: now},
+				{Username: "b", Rating: 1900, CreatedAt: now},
+			},
+			Expected: 0,
+		},
+		{
+			Name: "Far Ratings After Waiting",
+			Queue: []*Seek{
+				{Username: "a", Rating: 1500, CreatedAt: now.Add(-40 * time.Second)},
+				{Username: "b", Rating: 1900, CreatedAt: now.Add(-30 * time.Second)},
+			},
+			Expected: 1,
+		},
+		{
+			Name: "Odd Queue",
+			Queue: []*Seek{
+				{Username: "a", Rating: 1500, CreatedAt: now},
+				{Username: "b", Rating: 1500, CreatedAt: now},
+				{Username: "c", Rating: 1500, CreatedAt: now},
+			},
+			Expected: 1,
+		},
+	}
+
+	for _, tc := range queues {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			pairs, rest := findPairs(tc.Queue, now)
+			if len(pairs) != tc.Expected {
+				t.Errorf("expected %d pairs, got %d", tc.Expected, len(pairs))
+			}
+
+			if len(pairs)*2+len(rest) != len(tc.Queue) {
+				t.Errorf("expected every seek to be paired or left in the queue")
+			}
+		})
+	}
+}
+
+func TestSeekAndCancel(t *testing.T) {
+	m := NewMatchmaker(&mockChessApp{}, nil, lobby.NewHub())
+
+	if err := m.Seek("alice", 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Seek("alice", 3, 2); err == nil {
+		t.Error("expected a second seek to be rejected")
+	}
+
+	stats := m.Stats()
+	if len(stats) != 1 || stats[0].InitialTime != 5 || stats[0].Seekers != 1 {
+		t.Errorf("expected one seeker for 5+0, got %+v", stats)
+	}
+
+	if !m.Cancel("alice") {
+		t.Error("expected the seek to be cancelled")
+	}
+	if m.Cancel("alice") {
+		t.Error("expected no seek left to cancel")
+	}
+	if stats := m.Stats(); len(stats) != 0 {
+		t.Errorf("expected empty queues, got %+v", stats)
+	}
+}
+
+func TestPairStartsGame(t *testing.T) {
+	chessApp := &mockChessApp{}
+	m := NewMatchmaker(chessApp, nil, lobby.NewHub())
+
+	for _, username := range []string{"alice", "bob"} {
+		if err := m.Seek(username, 5, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m.pair(time.Now())
+
+	if len(chessApp.games) != 1 {
+		t.Fatalf("expected one game, got %d", len(chessApp.games))
+	}
+
+	game := chessApp.games[0]
+	game.Do(func() {
+		players := []string{game.PlayerWhite, game.PlayerBlack}
+		sort.Strings(players)
+		if players[0] != "alice" || players[1] != "bob" || !game.Rated {
+			t.Errorf("expected a rated game between alice and bob, got %v", players)
+		}
+	})
+
+	if stats := m.Stats(); len(stats) != 0 {
+		t.Errorf("expected the paired seeks to leave the queue, got %+v", stats)
+	}
+}
+
+func TestStartGameAbortsUnseatedGame(t *testing.T) {
+	chessApp := &mockChessApp{seated: []string{"carol", "dave"}}
+	m := NewMatchmaker(chessApp, nil, lobby.NewHub())
+
+	alice := &Seek{Username: "alice", InitialTime: 5, CreatedAt: time.Now()}
+	bob := &Seek{Username: "bob", InitialTime: 5, CreatedAt: time.Now()}
+	if err := m.startGame(alice, bob); err == nil {
+		t.Fatal("expected the players not to be seated")
+	}
+
+	game := chessApp.games[0]
+
+	// A retired game has no goroutine left to ask
+	var outcome, method string
+	if !game.Do(func() { outcome, method = game.Outcome, game.Method }) {
+		outcome, method = game.Outcome, game.Method
+	}
+
+	if outcome != "*" || method != "aborted" {
+		t.Errorf("expected the game to be aborted, got %q by %q", outcome, method)
+	}
+}
+
+func TestLeavingLobbyCancelsSeek(t *testing.T) {
+	hub := lobby.NewHub()
+	m := NewMatchmaker(&mockChessApp{}, nil, hub)
+
+	// The hub only compares connections, it never uses them here
+	first, second := &socket.Conn{}, &socket.Conn{}
+	hub.Add("alice", first)
+	hub.Add("alice", second)
+
+	if err := m.Seek("alice", 5, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	hub.Remove("alice", first)
+	if stats := m.Stats(); len(stats) != 1 {
+		t.Errorf("expected the seek to stay while a lobby socket is open, got %+v", stats)
+	}
+
+	hub.Remove("alice", second)
+	if stats := m.Stats(); len(stats) != 0 {
+		t.Errorf("expected the seek to be cancelled, got %+v", stats)
+	}
+}
+
+type mockChessApp struct {
+	created int
+	// Players already in every created game
+	seated []string
+	games  []*app.ChessGame
+	mu     sync.Mutex
+}
+
+func (m *mockChessApp) CreateGame(options types.GameOptions) (*types.Game, error) {
+	m.mu.Lock()
+	m.created++
+	id := fmt.Sprintf("match%d", m.created)
+	m.mu.Unlock()
+
+	game := &app.ChessGame{
+		ID:          id,
+		InitialTime: options.InitialTime,
+		TimeControl: options.TimeControl,
+		Color:       options.Color,
+		Rated:       options.Rated,
+	}
+	if len(m.seated) == 2 {
+		game.PlayerWhite, game.PlayerBlack = m.seated[0], m.seated[1]
+	}
+	app.GameStore.Add(game)
+
+	m.mu.Lock()
+	m.games = append(m.games, game)
+	m.mu.Unlock()
+
+	return &types.Game{ID: id, InitialTime: options.InitialTime, TimeControl: options.TimeControl, Color: options.Color, Rated: options.Rated}, nil
+}
+
+func (m *mockChessApp) GetGameByID(id string) (*types.Game, error) {
+	return nil, fmt.Errorf("game not found")
+}
+
+func (m *mockChessApp) GetUnfinishedGames() ([]types.Game, error) {
+	return nil, nil
+}
+
+func (m *mockChessApp) UpdateGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) FinishGame(game types.Game) error {
+	return nil
+}
+
+func (m *mockChessApp) ImportGame(game types.Game, moves []types.Move) (*types.Game, error) {
+	return &game, nil
+}
+
+func (m *mockChessApp) CreateMove(move types.Move) error {
+	return nil
+}
+
+func (m *mockChessApp) DeleteMovesAfter(gameID string, ply int) error {
+	return nil
+}
+
+func (m *mockChessApp) GetMovesByGameID(gameID string) ([]types.Move, error) {
+	return nil, nil
+}
@@ -0,0 +1,67 @@
+package match
+
+import (
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"ChessApp/utils"
+	"fmt"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	matchmaker *Matchmaker
+}
+
+func NewHandler(matchmaker *Matchmaker) *Handler {
+	return &Handler{matchmaker: matchmaker}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	auth.SetAccess(router.HandleFunc("/seek", h.handleSeek).Methods(http.MethodPost), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/seek", h.handleCancel).Methods(http.MethodDelete), auth.Authenticated)
+	auth.SetAccess(router.HandleFunc("/seek/stats", h.handleStats).Methods(http.MethodGet), auth.Public)
+}
+
+func (h *Handler) handleSeek(w http.ResponseWriter, r *http.Request) {
+
+	var payload types.SeekPayload
+
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+	// Validate payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	username := auth.GetUsernameFromContext(r.Context())
+
+	if err := h.matchmaker.Seek(username, payload.InitialTime, payload.TimeControl); err != nil {
+		utils.WriteError(w, http.StatusConflict, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusAccepted, nil)
+}
+
+func (h *Handler) handleCancel(w http.ResponseWriter, r *http.Request) {
+
+	username := auth.GetUsernameFromContext(r.Context())
+
+	if !h.matchmaker.Cancel(username) {
+		utils.WriteError(w, http.StatusNotFound, fmt.Errorf("no active seek"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleStats(w http.ResponseWriter, r *http.Request) {
+	utils.WriteJSON(w, http.StatusOK, h.matchmaker.Stats())
+}
@@ -0,0 +1,181 @@
+package rating
+
+import (
+	"ChessApp/types"
+	"ChessApp/variant"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type App struct {
+	db      *sql.DB
+	userApp types.UserApp
+}
+
+func NewApp(db *sql.DB, userApp types.UserApp) *App {
+	return &App{db: db, userApp: userApp}
+}
+
+// GetRating returns the rating of username in timeClass, or the default
+// provisional rating if they have not played it yet.
+func (a *App) GetRating(username, timeClass string) (*types.Rating, error) {
+
+	u, err := a.userApp.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.getRating(a.db, u.ID, timeClass)
+}
+
+func (a *App) GetRatings(username string) ([]types.Rating, error) {
+
+	u, err := a.userApp.GetUserByUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	ratings := []types.Rating{}
+	categories := append([]string{"bullet", "blitz", "rapid", "classical"}, variant.Names[1:]...)
+	for _, timeClass := range categories {
+		r, err := a.getRating(a.db, u.ID, timeClass)
+		if err != nil {
+			return nil, err
+		}
+		ratings = append(ratings, *r)
+	}
+
+	return ratings, nil
+}
+
+// RecordGame applies a finished rated game to both players' ratings and
+// writes a history row for each.
+func (a *App) RecordGame(game types.Game) error {
+
+	score, ok := map[string]float64{"1-0": 1, "0-1": 0, "1/2-1/2": 0.5}[game.Outcome]
+	if !ok {
+		return fmt.Errorf("game %s has no result", game.ID)
+	}
+
+	white, err := a.userApp.GetUserByUsername(game.PlayerWhite)
+	if err != nil {
+		return err
+	}
+
+	black, err := a.userApp.GetUserByUsername(game.PlayerBlack)
+	if err != nil {
+		return err
+	}
+
+	timeClass := Category(game.Variant, game.InitialTime, game.TimeControl)
+
+	tx, err := a.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	whiteRating, err := a.getRating(tx, white.ID, timeClass)
+	if err != nil {
+		return err
+	}
+
+	blackRating, err := a.getRating(tx, black.ID, timeClass)
+	if err != nil {
+		return err
+	}
+
+	newWhite := Update(toGlicko(whiteRating), toGlicko(blackRating), score)
+	newBlack := Update(toGlicko(blackRating), toGlicko(whiteRating), 1-score)
+
+	now := time.Now()
+	updates := []struct {
+		before *types.Rating
+		after  Glicko
+	}{
+		{whiteRating, newWhite},
+		{blackRating, newBlack},
+	}
+
+	for _, u := range updates {
+		_, err = tx.Exec(
+			`INSERT INTO ratings (user_id, time_class, rating, deviation, volatility, games, updated_at) VALUES (?, ?, ?, ?, ?, 1, ?)
+			ON CONFLICT (user_id, time_class) DO UPDATE SET rating = excluded.rating, deviation = excluded.deviation, volatility = excluded.volatility, games = games + 1, updated_at = excluded.updated_at`,
+			u.before.UserID, timeClass, u.after.Rating, u.after.Deviation, u.after.Volatility, now,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(
+			"INSERT INTO rating_history (game_id, user_id, time_class, rating_before, rating_after, deviation_after, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			game.ID, u.before.UserID, timeClass, u.before.Rating, u.after.Rating, u.after.Deviation, now,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func (a *App) getRating(q querier, userID, timeClass string) (*types.Rating, error) {
+
+	rows, err := q.Query("SELECT * FROM ratings WHERE user_id = ? AND time_class = ?", userID, timeClass)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	defaults := DefaultGlicko()
+	r := &types.Rating{
+		UserID:     userID,
+		TimeClass:  timeClass,
+		Rating:     defaults.Rating,
+		Deviation:  defaults.Deviation,
+		Volatility: defaults.Volatility,
+	}
+
+	for rows.Next() {
+		r, err = scanRowIntoRating(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	r.Provisional = r.Deviation > ProvisionalDeviation
+	return r, rows.Err()
+}
+
+func toGlicko(r *types.Rating) Glicko {
+	return Glicko{
+		Rating:     r.Rating,
+		Deviation:  r.Deviation,
+		Volatility: r.Volatility,
+	}
+}
+
+func scanRowIntoRating(rows *sql.Rows) (*types.Rating, error) {
+	rating := new(types.Rating)
+
+	err := rows.Scan(
+		&rating.UserID,
+		&rating.TimeClass,
+		&rating.Rating,
+		&rating.Deviation,
+		&rating.Volatility,
+		&rating.Games,
+		&rating.UpdatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return rating, nil
+}
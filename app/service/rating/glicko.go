@@ -0,0 +1,135 @@
+package rating
+
+import (
+	"ChessApp/variant"
+	"math"
+)
+
+// Glicko-2 as described in http://www.glicko.net/glicko/glicko2.pdf, with a
+// rating period of a single game.
+const (
+	DefaultRating     = 1500.0
+	DefaultDeviation  = 350.0
+	DefaultVolatility = 0.06
+
+	// Ratings with a deviation above this are shown as provisional
+	ProvisionalDeviation = 110.0
+
+	minDeviation = 45.0
+	glickoScale  = 173.7178
+	tau          = 0.5
+	epsilon      = 0.000001
+)
+
+type Glicko struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+func DefaultGlicko() Glicko {
+	return Glicko{
+		Rating:     DefaultRating,
+		Deviation:  DefaultDeviation,
+		Volatility: DefaultVolatility,
+	}
+}
+
+// Update returns the new rating of player after scoring score (1, 0.5 or 0)
+// against opponent.
+func Update(player, opponent Glicko, score float64) Glicko {
+	mu := (player.Rating - DefaultRating) / glickoScale
+	phi := player.Deviation / glickoScale
+	muOpponent := (opponent.Rating - DefaultRating) / glickoScale
+	phiOpponent := opponent.Deviation / glickoScale
+
+	g := glickoG(phiOpponent)
+	e := 1 / (1 + math.Exp(-g*(mu-muOpponent)))
+
+	v := 1 / (g * g * e * (1 - e))
+	delta := v * g * (score - e)
+
+	sigma := newVolatility(phi, v, delta, player.Volatility)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + newPhi*newPhi*g*(score-e)
+
+	deviation := math.Min(math.Max(newPhi*glickoScale, minDeviation), DefaultDeviation)
+
+	return Glicko{
+		Rating:     newMu*glickoScale + DefaultRating,
+		Deviation:  deviation,
+		Volatility: sigma,
+	}
+}
+
+func glickoG(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// newVolatility solves for the new volatility with the Illinois algorithm
+// (step 5 of the paper).
+func newVolatility(phi, v, delta, sigma float64) float64 {
+	a := math.Log(sigma * sigma)
+
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		num := ex * (delta*delta - phi*phi - v - ex)
+		den := 2 * math.Pow(phi*phi+v+ex, 2)
+		return num/den - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA = fA / 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
+
+// TimeClass buckets a time control by its estimated duration, initial time
+// plus forty moves of increment.
+func TimeClass(initialTime, timeControl int) string {
+	estimate := initialTime*60 + 40*timeControl
+
+	switch {
+	case estimate < 180:
+		return "bullet"
+	case estimate < 480:
+		return "blitz"
+	case estimate < 1500:
+		return "rapid"
+	default:
+		return "classical"
+	}
+}
+
+// Category is what a game is rated in: its time class in standard chess,
+// and the variant itself at any time control otherwise.
+func Category(variantName string, initialTime, timeControl int) string {
+	if variantName == "" || variantName == variant.Standard {
+		return TimeClass(initialTime, timeControl)
+	}
+	return variantName
+}
@@ -0,0 +1,52 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+// Player and first opponent of the example in the Glicko-2 paper.
+func TestUpdate(t *testing.T) {
+	player := Glicko{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	opponent := Glicko{Rating: 1400, Deviation: 30, Volatility: 0.06}
+
+	won := Update(player, opponent, 1)
+	if won.Rating <= player.Rating {
+		t.Errorf("expected rating to rise after a win, got %f", won.Rating)
+	}
+	if won.Deviation >= player.Deviation {
+		t.Errorf("expected deviation to shrink after a game, got %f", won.Deviation)
+	}
+
+	lost := Update(player, opponent, 0)
+	if lost.Rating >= player.Rating {
+		t.Errorf("expected rating to fall after a loss, got %f", lost.Rating)
+	}
+
+	expected := 1563.6
+	if math.Abs(won.Rating-expected) > 1 {
+		t.Errorf("expected rating close to %f, got %f", expected, won.Rating)
+	}
+}
+
+func TestTimeClass(t *testing.T) {
+	controls := []struct {
+		Name        string
+		InitialTime int
+		TimeControl int
+		Expected    string
+	}{
+		{Name: "One Minute", InitialTime: 1, TimeControl: 0, Expected: "bullet"},
+		{Name: "Three Plus Two", InitialTime: 3, TimeControl: 2, Expected: "blitz"},
+		{Name: "Ten Minutes", InitialTime: 10, TimeControl: 0, Expected: "rapid"},
+		{Name: "Thirty Minutes", InitialTime: 30, TimeControl: 0, Expected: "classical"},
+	}
+
+	for _, tc := range controls {
+		t.Run(tc.Name, func(t *testing.T) {
+			if got := TimeClass(tc.InitialTime, tc.TimeControl); got != tc.Expected {
+				t.Errorf("expected %s, got %s", tc.Expected, got)
+			}
+		})
+	}
+}
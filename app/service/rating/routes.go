@@ -0,0 +1,36 @@
+package rating
+
+import (
+	"ChessApp/service/auth"
+	"ChessApp/types"
+	"ChessApp/utils"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+type Handler struct {
+	app types.RatingApp
+}
+
+func NewHandler(app types.RatingApp) *Handler {
+	return &Handler{app: app}
+}
+
+func (h *Handler) RegisterRoutes(router *mux.Router) {
+	auth.SetAccess(router.HandleFunc("/user/{username}/ratings", h.handleRatings).Methods(http.MethodGet), auth.Public)
+}
+
+func (h *Handler) handleRatings(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	username := vars["username"]
+
+	ratings, err := h.app.GetRatings(username)
+	if err != nil {
+		utils.WriteError(w, http.StatusNotFound, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, ratings)
+}
@@ -116,3 +116,80 @@ func (a *App) CreateUser(user types.User) error {
 	}
 	return nil
 }
+
+func (a *App) CreateRefreshToken(token types.RefreshToken) error {
+
+	_, err := a.db.Exec(
+		"INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, expires_at, used, revoked, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		token.ID, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt, token.Used, token.Revoked, token.CreatedAt,
+	)
+	return err
+}
+
+func (a *App) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+
+	rows, err := a.db.Query("SELECT * FROM refresh_tokens WHERE token_hash = ?", hash)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	t := new(types.RefreshToken)
+
+	for rows.Next() {
+		t, err = scanRowIntoRefreshToken(rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if t.ID == "" {
+		return nil, fmt.Errorf("refresh token not found")
+	}
+
+	return t, nil
+}
+
+// UseRefreshToken marks the token as rotated. It returns false if the token
+// had already been used, which means it is being replayed.
+func (a *App) UseRefreshToken(id string) (bool, error) {
+
+	result, err := a.db.Exec("UPDATE refresh_tokens SET used = 1 WHERE id = ? AND used = 0", id)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (a *App) RevokeRefreshTokenFamily(familyID string) error {
+
+	_, err := a.db.Exec("UPDATE refresh_tokens SET revoked = 1 WHERE family_id = ?", familyID)
+	return err
+}
+
+func scanRowIntoRefreshToken(rows *sql.Rows) (*types.RefreshToken, error) {
+	token := new(types.RefreshToken)
+
+	err := rows.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.FamilyID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.Used,
+		&token.Revoked,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
@@ -6,9 +6,12 @@ import (
 	"ChessApp/types"
 	"ChessApp/utils"
 	"fmt"
+	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 )
 
@@ -21,8 +24,10 @@ func NewHandler(app types.UserApp) *Handler {
 }
 
 func (h *Handler) RegisterRoutes(router *mux.Router) {
-	router.HandleFunc("/login", h.handleLogin).Methods(http.MethodPost)
-	router.HandleFunc("/register", h.handleRegister).Methods(http.MethodPost)
+	auth.SetAccess(router.HandleFunc("/login", h.handleLogin).Methods(http.MethodPost), auth.Public)
+	auth.SetAccess(router.HandleFunc("/register", h.handleRegister).Methods(http.MethodPost), auth.Public)
+	auth.SetAccess(router.HandleFunc("/token/refresh", h.handleRefresh).Methods(http.MethodPost), auth.Public)
+	auth.SetAccess(router.HandleFunc("/logout", h.handleLogout).Methods(http.MethodPost), auth.Public)
 }
 
 func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
@@ -54,15 +59,139 @@ func (h *Handler) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	secret := []byte(config.Envs.JWTSecret)
-	token, err := auth.CreateJWT(secret, u.ID, u.Username)
+	tokens, err := h.issueTokens(u, uuid.NewString())
 	if err != nil {
 		utils.WriteError(w, http.StatusInternalServerError, fmt.Errorf("invalid email or password"))
 		return
 	}
 
-	utils.WriteJSON(w, http.StatusOK, map[string]string{"token": token})
+	utils.WriteJSON(w, http.StatusOK, tokens)
+
+}
+
+func (h *Handler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+
+	// Get JSON payload
+	var payload types.RefreshTokenPayload
+
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	stored, err := h.app.GetRefreshTokenByHash(auth.HashRefreshToken(payload.RefreshToken))
+	if err != nil || stored.Revoked || time.Now().After(stored.ExpiresAt) {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid refresh token"))
+		return
+	}
+
+	fresh, err := h.app.UseRefreshToken(stored.ID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	// A rotated token coming back means it was stolen, kill the whole session
+	if !fresh {
+		log.Printf("refresh token reuse detected for user %s, revoking family %s", stored.UserID, stored.FamilyID)
+
+		if err := h.app.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+			utils.WriteError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid refresh token"))
+		return
+	}
+
+	u, err := h.app.GetUserByID(stored.UserID)
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid refresh token"))
+		return
+	}
+
+	tokens, err := h.issueTokens(u, stored.FamilyID)
+	if err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	utils.WriteJSON(w, http.StatusOK, tokens)
+}
+
+func (h *Handler) handleLogout(w http.ResponseWriter, r *http.Request) {
+
+	// Get JSON payload
+	var payload types.RefreshTokenPayload
+
+	if err := utils.ParseJSON(r, &payload); err != nil {
+		utils.WriteError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	// Validate payload
+	if err := utils.Validate.Struct(payload); err != nil {
+		errors := err.(validator.ValidationErrors)
+		utils.WriteError(w, http.StatusBadRequest, fmt.Errorf("invalid payload %v", errors))
+		return
+	}
+
+	stored, err := h.app.GetRefreshTokenByHash(auth.HashRefreshToken(payload.RefreshToken))
+	if err != nil {
+		utils.WriteError(w, http.StatusUnauthorized, fmt.Errorf("invalid refresh token"))
+		return
+	}
+
+	if err := h.app.RevokeRefreshTokenFamily(stored.FamilyID); err != nil {
+		utils.WriteError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// issueTokens creates an access token and the next refresh token of the
+// session family.
+func (h *Handler) issueTokens(u *types.User, familyID string) (*types.TokenResponse, error) {
+
+	secret := []byte(config.Envs.JWTSecret)
+	token, err := auth.CreateJWT(secret, u.ID, u.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, hash, err := auth.CreateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	expiration := time.Second * time.Duration(config.Envs.RefreshTokenExpirationInSeconds)
+
+	err = h.app.CreateRefreshToken(types.RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    u.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: now.Add(expiration),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
 
+	return &types.TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    config.Envs.JWTExpirationInSeconds,
+	}, nil
 }
 
 func (h *Handler) handleRegister(w http.ResponseWriter, r *http.Request) {
@@ -166,30 +166,28 @@ func TestUserRegister(t *testing.T) {
 		},
 	}
 
-
 	for _, tc := range register_payloads {
 		t.Run(tc.Name, func(t *testing.T) {
 
-	
 			marshalled, _ := json.Marshal(tc.Payload)
-	
+
 			req, err := http.NewRequest(http.MethodPost, "/register", bytes.NewBuffer(marshalled))
 			if err != nil {
 				t.Fatal(err)
 			}
-	
+
 			rr := httptest.NewRecorder()
 			router := mux.NewRouter()
-	
+
 			router.HandleFunc("/register", handler.handleRegister)
 			router.ServeHTTP(rr, req)
-	
+
 			if rr.Code != tc.Expected {
 				t.Errorf("expected status code %d, got %d", tc.Expected, rr.Code)
 			}
 		})
 	}
-	
+
 }
 
 func TestUserLogin(t *testing.T) {
@@ -274,30 +272,73 @@ func TestUserLogin(t *testing.T) {
 		},
 	}
 
-
 	for _, tc := range login_payloads {
 		t.Run(tc.Name, func(t *testing.T) {
 
-	
 			marshalled, _ := json.Marshal(tc.Payload)
-	
+
 			req, err := http.NewRequest(http.MethodPost, "/login", bytes.NewBuffer(marshalled))
 			if err != nil {
 				t.Fatal(err)
 			}
-	
+
 			rr := httptest.NewRecorder()
 			router := mux.NewRouter()
-	
+
 			router.HandleFunc("/login", handler.handleLogin)
 			router.ServeHTTP(rr, req)
-	
+
 			if rr.Code != tc.Expected {
 				t.Errorf("expected status code %d, got %d", tc.Expected, rr.Code)
 			}
 		})
 	}
-	
+
+}
+
+func TestTokenRefresh(t *testing.T) {
+	userApp := &mockUserAppTokens{tokens: map[string]*types.RefreshToken{}}
+	handler := NewHandler(userApp)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/login", handler.handleLogin)
+	router.HandleFunc("/token/refresh", handler.handleRefresh)
+
+	post := func(path string, payload any) (*httptest.ResponseRecorder, types.TokenResponse) {
+		marshalled, _ := json.Marshal(payload)
+
+		req, err := http.NewRequest(http.MethodPost, path, bytes.NewBuffer(marshalled))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		var tokens types.TokenResponse
+		json.NewDecoder(rr.Body).Decode(&tokens)
+		return rr, tokens
+	}
+
+	rr, login := post("/login", types.LoginUserPayload{Username: "testuser", Password: "strongpassword"})
+	if rr.Code != http.StatusOK || login.RefreshToken == "" {
+		t.Fatalf("expected login to return a refresh token, got %d", rr.Code)
+	}
+
+	rr, rotated := post("/token/refresh", types.RefreshTokenPayload{RefreshToken: login.RefreshToken})
+	if rr.Code != http.StatusOK || rotated.RefreshToken == login.RefreshToken {
+		t.Fatalf("expected refresh to rotate the token, got %d", rr.Code)
+	}
+
+	rr, _ = post("/token/refresh", types.RefreshTokenPayload{RefreshToken: login.RefreshToken})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected replayed token to be rejected, got %d", rr.Code)
+	}
+
+	rr, _ = post("/token/refresh", types.RefreshTokenPayload{RefreshToken: rotated.RefreshToken})
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected family to be revoked after reuse, got %d", rr.Code)
+	}
 }
 
 type mockUserAppRegister struct{}
@@ -316,6 +357,22 @@ func (m *mockUserAppRegister) CreateUser(user types.User) error {
 	return nil
 }
 
+func (m *mockUserAppRegister) CreateRefreshToken(token types.RefreshToken) error {
+	return nil
+}
+
+func (m *mockUserAppRegister) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	return nil, fmt.Errorf("refresh token not found")
+}
+
+func (m *mockUserAppRegister) UseRefreshToken(id string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockUserAppRegister) RevokeRefreshTokenFamily(familyID string) error {
+	return nil
+}
+
 type mockUserAppLogin struct{}
 
 func (m *mockUserAppLogin) GetUserByEmail(email string) (*types.User, error) {
@@ -333,9 +390,9 @@ func (m *mockUserAppLogin) GetUserByUsername(username string) (*types.User, erro
 		return nil, fmt.Errorf("error")
 	}
 	return &types.User{
-		ID: "",
+		ID:       "",
 		Username: username,
-		Email: "",
+		Email:    "",
 		Password: password,
 	}, nil
 }
@@ -346,4 +403,62 @@ func (m *mockUserAppLogin) GetUserByID(id string) (*types.User, error) {
 
 func (m *mockUserAppLogin) CreateUser(user types.User) error {
 	return nil
-}
\ No newline at end of file
+}
+
+func (m *mockUserAppLogin) CreateRefreshToken(token types.RefreshToken) error {
+	return nil
+}
+
+func (m *mockUserAppLogin) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	return nil, fmt.Errorf("refresh token not found")
+}
+
+func (m *mockUserAppLogin) UseRefreshToken(id string) (bool, error) {
+	return true, nil
+}
+
+func (m *mockUserAppLogin) RevokeRefreshTokenFamily(familyID string) error {
+	return nil
+}
+
+type mockUserAppTokens struct {
+	mockUserAppLogin
+	tokens map[string]*types.RefreshToken
+}
+
+func (m *mockUserAppTokens) GetUserByID(id string) (*types.User, error) {
+	return &types.User{ID: id, Username: "testuser"}, nil
+}
+
+func (m *mockUserAppTokens) CreateRefreshToken(token types.RefreshToken) error {
+	m.tokens[token.TokenHash] = &token
+	return nil
+}
+
+func (m *mockUserAppTokens) GetRefreshTokenByHash(hash string) (*types.RefreshToken, error) {
+	token, ok := m.tokens[hash]
+	if !ok {
+		return nil, fmt.Errorf("refresh token not found")
+	}
+	return token, nil
+}
+
+func (m *mockUserAppTokens) UseRefreshToken(id string) (bool, error) {
+	for _, token := range m.tokens {
+		if token.ID == id {
+			used := token.Used
+			token.Used = true
+			return !used, nil
+		}
+	}
+	return false, fmt.Errorf("refresh token not found")
+}
+
+func (m *mockUserAppTokens) RevokeRefreshTokenFamily(familyID string) error {
+	for _, token := range m.tokens {
+		if token.FamilyID == familyID {
+			token.Revoked = true
+		}
+	}
+	return nil
+}
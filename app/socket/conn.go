@@ -0,0 +1,176 @@
+// Package socket wraps WebSocket connections with a buffered send queue and
+// a writer goroutine, so a slow client never blocks the code sending to it.
+package socket
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the peer
+	writeWait = 10 * time.Second
+
+	// Time allowed to read the next pong from the peer
+	pongWait = 60 * time.Second
+
+	// Pings are sent a little more often than pongWait
+	pingPeriod = pongWait * 9 / 10
+
+	// Largest message accepted from the peer
+	maxMessageSize = 64 * 1024
+
+	// Messages queued for a client before it is evicted
+	sendBuffer = 256
+)
+
+// Conn is a WebSocket connection whose writes go through a queue drained by
+// its own goroutine. Send may be called from any goroutine; reads must
+// still come from a single one.
+type Conn struct {
+	ws      *websocket.Conn
+	send    chan []byte
+	closed  chan struct{}
+	once    sync.Once
+	evicted bool
+}
+
+func New(ws *websocket.Conn) *Conn {
+	return newConn(ws, sendBuffer)
+}
+
+func newConn(ws *websocket.Conn, buffer int) *Conn {
+	c := &Conn{
+		ws:     ws,
+		send:   make(chan []byte, buffer),
+		closed: make(chan struct{}),
+	}
+
+	ws.SetReadLimit(maxMessageSize)
+	ws.SetReadDeadline(time.Now().Add(pongWait))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	go c.writePump()
+	return c
+}
+
+// Send marshals message and queues it. It returns false if the connection
+// is closed or its queue is full, in which case the client is evicted.
+func (c *Conn) Send(message any) bool {
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Println("Error marshalling message:", err)
+		return false
+	}
+
+	return c.SendRaw(data)
+}
+
+// SendRaw queues an already encoded message, for broadcasts that marshal
+// once for many connections.
+func (c *Conn) SendRaw(data []byte) bool {
+	select {
+	case <-c.closed:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return true
+	default:
+		log.Printf("Evicting WebSocket client %s: send queue full", c.ws.RemoteAddr())
+		c.evict()
+		return false
+	}
+}
+
+func (c *Conn) ReadMessage() ([]byte, error) {
+	_, data, err := c.ws.ReadMessage()
+	return data, err
+}
+
+func (c *Conn) ReadJSON(v any) error {
+	return c.ws.ReadJSON(v)
+}
+
+// Close ends the connection once the queued messages are written. It is
+// safe to call more than once.
+func (c *Conn) Close() {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+}
+
+// Done is closed once the connection is closed or evicted.
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+// evict drops the client at once. Closing the socket also aborts a write
+// that is stuck on the slow peer.
+func (c *Conn) evict() {
+	c.once.Do(func() {
+		c.evicted = true
+		close(c.closed)
+		c.ws.Close()
+	})
+}
+
+// writePump is the only writer of ws. Closing the socket when it exits also
+// ends the reader, which is how an evicted client is noticed.
+func (c *Conn) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.ws.Close()
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Println("Error sending WebSocket message:", err)
+				return
+			}
+
+		case <-ticker.C:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+
+		case <-c.closed:
+			if c.evicted {
+				return
+			}
+			c.drain()
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			c.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
+
+// drain writes what is still queued when the connection is closed
+// normally, so a final game_over is not lost.
+func (c *Conn) drain() {
+	for {
+		select {
+		case data := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
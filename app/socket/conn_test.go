@@ -0,0 +1,88 @@
+package socket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// serve upgrades a single connection with the given queue size and hands it
+// to the test.
+func serve(t *testing.T, buffer int) (*Conn, *websocket.Conn) {
+	conns := make(chan *Conn, 1)
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- newConn(ws, buffer)
+	}))
+	t.Cleanup(server.Close)
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+
+	return <-conns, client
+}
+
+func TestEvictSlowClient(t *testing.T) {
+	conn, _ := serve(t, 4)
+	payload := strings.Repeat("x", 64*1024)
+
+	// The client never reads, so the socket buffers and then the queue fill up
+	evicted := false
+	for i := 0; i < 10000 && !evicted; i++ {
+		evicted = !conn.Send(payload)
+	}
+
+	if !evicted {
+		t.Fatal("expected slow client to be evicted")
+	}
+
+	select {
+	case <-conn.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected evicted connection to be closed")
+	}
+
+	if conn.Send("late") {
+		t.Error("expected send after eviction to fail")
+	}
+}
+
+func TestCloseFlushesQueue(t *testing.T) {
+	conn, client := serve(t, 16)
+
+	messages := []string{"one", "two", "three"}
+	for _, message := range messages {
+		if !conn.Send(message) {
+			t.Fatalf("expected %s to be queued", message)
+		}
+	}
+	conn.Close()
+
+	for _, expected := range messages {
+		var message string
+		if err := client.ReadJSON(&message); err != nil {
+			t.Fatal(err)
+		}
+
+		if message != expected {
+			t.Errorf("expected %s, got %s", expected, message)
+		}
+	}
+
+	if _, _, err := client.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("expected normal close, got %v", err)
+	}
+}
@@ -7,10 +7,41 @@ type UserApp interface {
 	GetUserByUsername(username string) (*User, error)
 	GetUserByID(id string) (*User, error)
 	CreateUser(User) error
+	CreateRefreshToken(RefreshToken) error
+	GetRefreshTokenByHash(hash string) (*RefreshToken, error)
+	UseRefreshToken(id string) (bool, error)
+	RevokeRefreshTokenFamily(familyID string) error
 }
 
 type ChessApp interface {
-	CreateGame(initialTime, timeControl int, color string) error
+	CreateGame(options GameOptions) (*Game, error)
+	GetGameByID(id string) (*Game, error)
+	GetUnfinishedGames() ([]Game, error)
+	UpdateGame(Game) error
+	FinishGame(Game) error
+	ImportGame(game Game, moves []Move) (*Game, error)
+	CreateMove(Move) error
+	DeleteMovesAfter(gameID string, ply int) error
+	GetMovesByGameID(gameID string) ([]Move, error)
+}
+
+// Lobby pushes messages to users connected to the lobby socket.
+type Lobby interface {
+	Broadcast(message any)
+	SendToUser(username string, message any)
+}
+
+type RatingApp interface {
+	GetRating(username, timeClass string) (*Rating, error)
+	GetRatings(username string) ([]Rating, error)
+	RecordGame(Game) error
+}
+
+// AnalysisStore keeps the results of post-game analyses.
+type AnalysisStore interface {
+	GetAnalysis(gameID string) (*Analysis, error)
+	GetPendingAnalyses() ([]Analysis, error)
+	SaveAnalysis(Analysis) error
 }
 
 type User struct {
@@ -21,6 +52,119 @@ type User struct {
 	CreatedAt time.Time `json:"createdAt"`
 }
 
+type Rating struct {
+	UserID      string    `json:"userId"`
+	TimeClass   string    `json:"time_class"`
+	Rating      float64   `json:"rating"`
+	Deviation   float64   `json:"deviation"`
+	Volatility  float64   `json:"volatility"`
+	Games       int       `json:"games"`
+	Provisional bool      `json:"provisional"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+const (
+	GameStatusWaiting  = "waiting"
+	GameStatusPlaying  = "playing"
+	GameStatusFinished = "finished"
+)
+
+// GameModeBot is the game mode of standard games against an engine.
+const GameModeBot = "vs_bot"
+
+type Game struct {
+	ID             string    `json:"id"`
+	PlayerWhite    string    `json:"player_white"`
+	PlayerBlack    string    `json:"player_black"`
+	Color          string    `json:"color"`
+	InitialTime    int       `json:"initial_time"`
+	TimeControl    int       `json:"time_control"`
+	Status         string    `json:"status"`
+	Outcome        string    `json:"outcome"`
+	Method         string    `json:"method"`
+	WhiteTime      int64     `json:"white_time"`
+	BlackTime      int64     `json:"black_time"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+	Rated          bool      `json:"rated"`
+	Owner          string    `json:"owner,omitempty"`
+	SpectatorDelay int       `json:"spectator_delay"`
+	Variant        string    `json:"variant"`
+	InitialFEN     string    `json:"initial_fen,omitempty"`
+	BotLevel       int       `json:"bot_level,omitempty"`
+	Version        int       `json:"version"`
+}
+
+type GameOptions struct {
+	InitialTime    int
+	TimeControl    int
+	Color          string
+	Rated          bool
+	SpectatorDelay int
+	Variant        string
+	InitialFEN     string
+	BotLevel       int
+}
+
+type Move struct {
+	GameID    string    `json:"game_id"`
+	Ply       int       `json:"ply"`
+	SAN       string    `json:"san"`
+	UCI       string    `json:"uci"`
+	FEN       string    `json:"fen"`
+	WhiteTime int64     `json:"white_time"`
+	BlackTime int64     `json:"black_time"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+const (
+	AnalysisQueued  = "queued"
+	AnalysisRunning = "running"
+	AnalysisDone    = "done"
+	AnalysisFailed  = "failed"
+)
+
+// Analysis is an engine's review of a finished game. Progress is the share
+// of positions searched so far, in percent.
+type Analysis struct {
+	GameID        string         `json:"game_id"`
+	Status        string         `json:"status"`
+	Progress      int            `json:"progress"`
+	WhiteAccuracy float64        `json:"white_accuracy"`
+	BlackAccuracy float64        `json:"black_accuracy"`
+	Error         string         `json:"error,omitempty"`
+	Moves         []AnalysedMove `json:"moves"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+}
+
+// AnalysedMove is the verdict on one move. Eval and Mate score the position
+// after it from white's point of view; a mate is also counted as 10000
+// centipawns. BestMove and PV are the engine's choice in the position
+// before it.
+type AnalysedMove struct {
+	Ply            int      `json:"ply"`
+	SAN            string   `json:"san"`
+	Eval           int      `json:"eval"`
+	Mate           int      `json:"mate,omitempty"`
+	BestMove       string   `json:"best_move"`
+	PV             []string `json:"pv"`
+	Classification string   `json:"classification"`
+	Loss           int      `json:"loss"`
+	Accuracy       float64  `json:"accuracy"`
+}
+
+type RefreshToken struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	FamilyID  string    `json:"familyId"`
+	TokenHash string    `json:"-"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Used      bool      `json:"used"`
+	Revoked   bool      `json:"revoked"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
 type LoginUserPayload struct {
 	Username string `json:"username" validate:"required,min=4,max=32"`
 	Password string `json:"password" validate:"required,min=8,max=64"`
@@ -32,15 +176,127 @@ type RegisterUserPayload struct {
 	Password string `json:"password" validate:"required,min=8,max=64"`
 }
 
+type RefreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type TokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
 type NewGamePayload struct {
-	GameMode    string `json:"game_mode" validate:"required"`
-	Color       string `json:"color" validate:"required"`
+	GameMode       string `json:"game_mode" validate:"required,oneof=standard chess960 king_of_the_hill three_check crazyhouse vs_bot"`
+	Color          string `json:"color" validate:"required,oneof=white black random"`
+	InitialTime    int    `json:"initial_time" validate:"required,min=1"`
+	TimeControl    int    `json:"time_control" validate:"min=0"`
+	Rated          bool   `json:"rated"`
+	SpectatorDelay int    `json:"spectator_delay" validate:"min=0,max=300"`
+
+	// Optional start position, games from one are always casual
+	FEN string `json:"fen" validate:"omitempty,max=128"`
+
+	// Strength of the engine in vs_bot games, from 1 to 8
+	Level int `json:"level" validate:"required_if=GameMode vs_bot,omitempty,min=1,max=8"`
+}
+
+type SeekPayload struct {
+	InitialTime int `json:"initial_time" validate:"required"`
+	TimeControl int `json:"time_control" validate:"min=0"`
+}
+
+type QueueStats struct {
+	InitialTime int `json:"initial_time"`
+	TimeControl int `json:"time_control"`
+	Seekers     int `json:"seekers"`
+}
+
+type ImportGamePayload struct {
+	PGN string `json:"pgn" validate:"required,max=100000"`
+}
+
+type CreateGameResponse struct {
+	ID           string `json:"id"`
+	JoinURL      string `json:"join_url"`
+	WebSocketURL string `json:"websocket_url"`
+	InitialTime  int    `json:"initial_time"`
+	TimeControl  int    `json:"time_control"`
+	Color        string `json:"color"`
+	Rated        bool   `json:"rated"`
+	Variant      string `json:"variant"`
+}
+
+// JoinGameResponse tells a joining player which colour they were given.
+type JoinGameResponse struct {
+	GameID string `json:"game_id"`
+	Color  string `json:"color"`
+}
+
+// OpenGame is a challenge waiting for an opponent, as listed in the lobby.
+type OpenGame struct {
+	ID          string    `json:"id"`
+	Creator     string    `json:"creator"`
+	Rating      int       `json:"rating"`
+	InitialTime int       `json:"initial_time"`
+	TimeControl int       `json:"time_control"`
+	TimeClass   string    `json:"time_class"`
+	Variant     string    `json:"variant"`
+	Color       string    `json:"color"`
+	Rated       bool      `json:"rated"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type OpenGamesQuery struct {
+	TimeClass string `validate:"omitempty,oneof=bullet blitz rapid classical"`
+	Variant   string `validate:"omitempty,oneof=standard chess960 king_of_the_hill three_check crazyhouse"`
+	Rated     *bool
+	MinRating int `validate:"min=0"`
+	MaxRating int `validate:"min=0"`
+	Limit     int `validate:"min=1,max=100"`
+	Offset    int `validate:"min=0"`
+}
+
+type OpenGamesResponse struct {
+	Games  []OpenGame `json:"games"`
+	Total  int        `json:"total"`
+	Limit  int        `json:"limit"`
+	Offset int        `json:"offset"`
+}
+
+// Challenge is an invitation from one user to another to play a game.
+type Challenge struct {
+	ID          string    `json:"id"`
+	Challenger  string    `json:"challenger"`
+	Target      string    `json:"target"`
+	InitialTime int       `json:"initial_time"`
+	TimeControl int       `json:"time_control"`
+	Color       string    `json:"color"`
+	Rated       bool      `json:"rated"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type ChallengePayload struct {
+	Username    string `json:"username" validate:"required"`
 	InitialTime int    `json:"initial_time" validate:"required"`
-	TimeControl int    `json:"time_control" validate:"required"`
+	TimeControl int    `json:"time_control" validate:"min=0"`
+	Color       string `json:"color" validate:"required,oneof=white black random"`
+	Rated       bool   `json:"rated"`
+}
+
+type DeclineChallengePayload struct {
+	Reason string `json:"reason" validate:"max=200"`
 }
 
-type ErrorMessage struct {
-	Success bool   `json:"success"`
-	Message string `json:"message"`
-	Status  int    `json:"status"`
-}
\ No newline at end of file
+type ChallengesResponse struct {
+	Incoming []Challenge `json:"incoming"`
+	Outgoing []Challenge `json:"outgoing"`
+}
+
+type AcceptChallengeResponse struct {
+	GameID   string `json:"game_id"`
+	Color    string `json:"color"`
+	Opponent string `json:"opponent"`
+}
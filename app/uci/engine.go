@@ -0,0 +1,367 @@
+// Package uci drives chess engines that speak the Universal Chess Interface
+// over their standard input and output.
+package uci
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// handshakeTimeout bounds every exchange that is not a search.
+const handshakeTimeout = 10 * time.Second
+
+// Option is a setting the engine announced after "uci".
+type Option struct {
+	Name    string
+	Type    string
+	Default string
+	Min     int
+	Max     int
+}
+
+// Engine is a running engine process. Its methods may be called from any
+// goroutine but run one at a time.
+type Engine struct {
+	Name    string
+	Options map[string]Option
+
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan string
+	mu    sync.Mutex
+}
+
+// Limits bound a search. Zero values are left out of the "go" command, and
+// a search without any limit is not allowed.
+type Limits struct {
+	WhiteTime time.Duration
+	BlackTime time.Duration
+	WhiteInc  time.Duration
+	BlackInc  time.Duration
+	MoveTime  time.Duration
+	Depth     int
+	Nodes     int
+}
+
+// Analysis is what a search found: the best move, the score from the point
+// of view of the side to move and the line the engine expects. Mate is the
+// number of moves to mate, negative if the side to move gets mated, and 0
+// when the score is in centipawns.
+type Analysis struct {
+	BestMove string
+	Score    int
+	Mate     int
+	Depth    int
+	PV       []string
+}
+
+// Start runs the engine at path and waits for it to finish the UCI
+// handshake.
+func Start(path string, args ...string) (*Engine, error) {
+	cmd := exec.Command(path, args...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start engine %s: %v", path, err)
+	}
+
+	e := &Engine{
+		Options: make(map[string]Option),
+		cmd:     cmd,
+		stdin:   stdin,
+		lines:   make(chan string, 64),
+	}
+	go e.read(stdout)
+
+	if err := e.handshake(); err != nil {
+		e.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+func (e *Engine) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		e.lines <- scanner.Text()
+	}
+	close(e.lines)
+}
+
+func (e *Engine) handshake() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send("uci"); err != nil {
+		return err
+	}
+
+	return e.readUntil("uciok", handshakeTimeout, func(line string) {
+		switch {
+		case strings.HasPrefix(line, "id name "):
+			e.Name = strings.TrimPrefix(line, "id name ")
+		case strings.HasPrefix(line, "option name "):
+			option := parseOption(line)
+			e.Options[option.Name] = option
+		}
+	})
+}
+
+// parseOption reads a line such as
+// "option name Skill Level type spin default 20 min 0 max 20". Names may
+// contain spaces, so they run up to the "type" keyword.
+func parseOption(line string) Option {
+	fields := strings.Fields(strings.TrimPrefix(line, "option name "))
+	option := Option{}
+
+	key, name := "name", []string{}
+	for _, field := range fields {
+		switch field {
+		case "type", "default", "min", "max", "var":
+			key = field
+			continue
+		}
+
+		switch key {
+		case "name":
+			name = append(name, field)
+		case "type":
+			option.Type = field
+		case "default":
+			option.Default = field
+		case "min":
+			option.Min, _ = strconv.Atoi(field)
+		case "max":
+			option.Max, _ = strconv.Atoi(field)
+		}
+	}
+
+	option.Name = strings.Join(name, " ")
+	return option
+}
+
+// HasOption reports whether the engine announced the option name.
+func (e *Engine) HasOption(name string) bool {
+	_, ok := e.Options[name]
+	return ok
+}
+
+// SetOption sets an option and waits for the engine to apply it.
+func (e *Engine) SetOption(name, value string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send(fmt.Sprintf("setoption name %s value %s", name, value)); err != nil {
+		return err
+	}
+	return e.sync()
+}
+
+// NewGame tells the engine the next search belongs to a different game.
+func (e *Engine) NewGame() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if err := e.send("ucinewgame"); err != nil {
+		return err
+	}
+	return e.sync()
+}
+
+// BestMove searches the position reached from fen by moves, given in UCI
+// notation, and returns the move the engine picked. An empty fen is the
+// standard start position. The search is given up after timeout.
+func (e *Engine) BestMove(fen string, moves []string, limits Limits, timeout time.Duration) (string, error) {
+	analysis, err := e.Analyse(fen, moves, limits, timeout)
+	if err != nil {
+		return "", err
+	}
+	return analysis.BestMove, nil
+}
+
+// Analyse searches like BestMove and also returns the score and principal
+// variation of the last "info" line the engine sent.
+func (e *Engine) Analyse(fen string, moves []string, limits Limits, timeout time.Duration) (Analysis, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	position := "position startpos"
+	if fen != "" {
+		position = "position fen " + fen
+	}
+	if len(moves) > 0 {
+		position += " moves " + strings.Join(moves, " ")
+	}
+
+	goCommand := limits.command()
+	if goCommand == "go" {
+		return Analysis{}, fmt.Errorf("search has no limits")
+	}
+
+	if err := e.send(position); err != nil {
+		return Analysis{}, err
+	}
+	if err := e.send(goCommand); err != nil {
+		return Analysis{}, err
+	}
+
+	analysis := Analysis{}
+	err := e.readUntil("bestmove", timeout, func(line string) {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) > 1 && fields[0] == "bestmove":
+			analysis.BestMove = fields[1]
+		case len(fields) > 0 && fields[0] == "info":
+			parseInfo(fields[1:], &analysis)
+		}
+	})
+	if err != nil {
+		// The late answer must not be taken for the next search's
+		e.send("stop")
+		e.readUntil("bestmove", handshakeTimeout, func(string) {})
+		return Analysis{}, err
+	}
+
+	best := analysis.BestMove
+	if best == "" || best == "(none)" || best == "0000" {
+		return Analysis{}, fmt.Errorf("engine found no move")
+	}
+	if len(analysis.PV) == 0 || analysis.PV[0] != best {
+		analysis.PV = []string{best}
+	}
+	return analysis, nil
+}
+
+// parseInfo reads the score, depth and principal variation of an "info"
+// line such as "info depth 12 score cp 31 nodes 9000 pv e2e4 e7e5". Lines
+// without a score, or for a second multipv line, are left out.
+func parseInfo(fields []string, analysis *Analysis) {
+	info := Analysis{}
+	scored := false
+
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "multipv":
+			if i+1 < len(fields) && fields[i+1] != "1" {
+				return
+			}
+		case "depth":
+			if i+1 < len(fields) {
+				info.Depth, _ = strconv.Atoi(fields[i+1])
+			}
+		case "score":
+			if i+2 < len(fields) {
+				value, err := strconv.Atoi(fields[i+2])
+				if err != nil {
+					return
+				}
+				switch fields[i+1] {
+				case "cp":
+					info.Score, scored = value, true
+				case "mate":
+					info.Mate, scored = value, true
+				}
+				i += 2
+			}
+		case "pv":
+			info.PV = append([]string{}, fields[i+1:]...)
+			i = len(fields)
+		case "string":
+			return
+		}
+	}
+
+	if !scored {
+		return
+	}
+	analysis.Score, analysis.Mate, analysis.Depth, analysis.PV = info.Score, info.Mate, info.Depth, info.PV
+}
+
+func (l Limits) command() string {
+	parts := []string{"go"}
+
+	for _, limit := range []struct {
+		name  string
+		value int64
+	}{
+		{"wtime", l.WhiteTime.Milliseconds()},
+		{"btime", l.BlackTime.Milliseconds()},
+		{"winc", l.WhiteInc.Milliseconds()},
+		{"binc", l.BlackInc.Milliseconds()},
+		{"movetime", l.MoveTime.Milliseconds()},
+		{"depth", int64(l.Depth)},
+		{"nodes", int64(l.Nodes)},
+	} {
+		if limit.value > 0 {
+			parts = append(parts, limit.name, strconv.FormatInt(limit.value, 10))
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Close asks the engine to quit and kills it if it does not.
+func (e *Engine) Close() error {
+	e.send("quit")
+	e.stdin.Close()
+
+	done := make(chan error, 1)
+	go func() { done <- e.cmd.Wait() }()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(handshakeTimeout):
+		e.cmd.Process.Kill()
+		return <-done
+	}
+}
+
+// sync waits until the engine has processed every command sent so far.
+func (e *Engine) sync() error {
+	if err := e.send("isready"); err != nil {
+		return err
+	}
+	return e.readUntil("readyok", handshakeTimeout, func(string) {})
+}
+
+func (e *Engine) send(command string) error {
+	_, err := io.WriteString(e.stdin, command+"\n")
+	return err
+}
+
+// readUntil passes every line to fn until one starts with prefix.
+func (e *Engine) readUntil(prefix string, timeout time.Duration, fn func(string)) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case line, ok := <-e.lines:
+			if !ok {
+				return fmt.Errorf("engine exited")
+			}
+			fn(line)
+			if strings.HasPrefix(line, prefix) {
+				return nil
+			}
+		case <-timer.C:
+			return fmt.Errorf("engine did not answer with %s in %v", prefix, timeout)
+		}
+	}
+}
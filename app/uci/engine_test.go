@@ -0,0 +1,161 @@
+package uci
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary double as the stub engine: started again
+// with UCI_STUB_ENGINE set, it plays instead of running the tests.
+func TestMain(m *testing.M) {
+	if os.Getenv("UCI_STUB_ENGINE") != "" {
+		Stub(os.Stdin, os.Stdout)
+		os.Exit(0)
+	}
+
+	os.Setenv("UCI_STUB_ENGINE", "1")
+	os.Exit(m.Run())
+}
+
+func TestEngine(t *testing.T) {
+	engine, err := Start(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer engine.Close()
+
+	if engine.Name != "Stub" {
+		t.Errorf("expected engine name Stub, got %q", engine.Name)
+	}
+
+	option, ok := engine.Options["Skill Level"]
+	if !ok || option.Type != "spin" || option.Min != 0 || option.Max != 20 {
+		t.Errorf("unexpected Skill Level option %+v", option)
+	}
+
+	if err := engine.SetOption("Skill Level", "3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.NewGame(); err != nil {
+		t.Fatal(err)
+	}
+
+	positions := []struct {
+		Name     string
+		FEN      string
+		Moves    []string
+		Expected string
+	}{
+		{
+			Name:  "Start Position",
+			Moves: []string{"e2e4", "e7e5"},
+		},
+		{
+			Name:     "Only Move",
+			FEN:      "k7/8/1K6/8/8/8/7P/8 b - - 0 1",
+			Expected: "a8b8",
+		},
+	}
+
+	for _, tc := range positions {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			move, err := engine.BestMove(tc.FEN, tc.Moves, Limits{Depth: 1}, 5*time.Second)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tc.Expected != "" && move != tc.Expected {
+				t.Errorf("expected %s, got %s", tc.Expected, move)
+			}
+			if len(move) < 4 {
+				t.Errorf("expected a UCI move, got %q", move)
+			}
+		})
+	}
+
+	if _, err := engine.BestMove("", nil, Limits{}, time.Second); err == nil {
+		t.Error("expected a search without limits to be rejected")
+	}
+
+	if _, err := engine.BestMove("k7/8/1Q6/8/8/8/8/7K b - - 0 1", nil, Limits{Depth: 1}, time.Second); err == nil {
+		t.Error("expected no move in a stalemate")
+	}
+
+	analysis, err := engine.Analyse("k7/8/1K6/8/8/8/7P/8 b - - 0 1", nil, Limits{Depth: 1}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if analysis.BestMove != "a8b8" || analysis.Depth != 1 || len(analysis.PV) != 1 || analysis.PV[0] != "a8b8" {
+		t.Errorf("unexpected analysis %+v", analysis)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	lines := []struct {
+		Name     string
+		Line     string
+		Expected Analysis
+	}{
+		{
+			Name:     "Centipawns",
+			Line:     "info depth 12 seldepth 16 multipv 1 score cp -31 nodes 9000 nps 90000 pv e7e5 g1f3 b8c6",
+			Expected: Analysis{Score: -31, Depth: 12, PV: []string{"e7e5", "g1f3", "b8c6"}},
+		},
+		{
+			Name:     "Mate",
+			Line:     "info depth 3 score mate 2 pv a1a7 h8g8 b2b8",
+			Expected: Analysis{Mate: 2, Depth: 3, PV: []string{"a1a7", "h8g8", "b2b8"}},
+		},
+		{
+			Name:     "Bound",
+			Line:     "info depth 20 score cp 45 lowerbound pv d2d4",
+			Expected: Analysis{Score: 45, Depth: 20, PV: []string{"d2d4"}},
+		},
+		{
+			Name:     "Second Line",
+			Line:     "info depth 20 multipv 2 score cp 12 pv c2c4",
+			Expected: Analysis{Score: 7, Depth: 19, PV: []string{"e2e4"}},
+		},
+		{
+			Name:     "No Score",
+			Line:     "info depth 21 currmove e2e4 currmovenumber 1",
+			Expected: Analysis{Score: 7, Depth: 19, PV: []string{"e2e4"}},
+		},
+		{
+			Name:     "String",
+			Line:     "info string NNUE evaluation using nn.nnue score cp 0",
+			Expected: Analysis{Score: 7, Depth: 19, PV: []string{"e2e4"}},
+		},
+	}
+
+	for _, tc := range lines {
+		t.Run(tc.Name, func(t *testing.T) {
+			analysis := Analysis{Score: 7, Depth: 19, PV: []string{"e2e4"}}
+			parseInfo(strings.Fields(tc.Line)[1:], &analysis)
+
+			if !reflect.DeepEqual(analysis, tc.Expected) {
+				t.Errorf("expected %+v, got %+v", tc.Expected, analysis)
+			}
+		})
+	}
+}
+
+func TestParseOption(t *testing.T) {
+	option := parseOption("option name UCI_Elo type spin default 1350 min 1320 max 3190")
+
+	if option.Name != "UCI_Elo" || option.Default != "1350" || option.Min != 1320 || option.Max != 3190 {
+		t.Errorf("unexpected option %+v", option)
+	}
+}
+
+func TestLimitsCommand(t *testing.T) {
+	limits := Limits{WhiteTime: 3 * time.Minute, BlackTime: 170 * time.Second, WhiteInc: 2 * time.Second, Depth: 5}
+
+	if command := limits.command(); command != "go wtime 180000 btime 170000 winc 2000 depth 5" {
+		t.Errorf("unexpected go command %q", command)
+	}
+}
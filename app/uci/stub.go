@@ -0,0 +1,91 @@
+package uci
+
+import (
+	"ChessApp/variant"
+	"bufio"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Stub is a tiny engine that answers every search with a random legal move.
+// It speaks just enough UCI for tests to play against it without a real
+// engine installed.
+func Stub(in io.Reader, out io.Writer) error {
+	game, _ := variant.NewGame(variant.Standard, "")
+	scanner := bufio.NewScanner(in)
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "uci":
+			fmt.Fprintln(out, "id name Stub")
+			fmt.Fprintln(out, "option name Skill Level type spin default 20 min 0 max 20")
+			fmt.Fprintln(out, "uciok")
+		case "isready":
+			fmt.Fprintln(out, "readyok")
+		case "position":
+			position, err := stubPosition(fields[1:])
+			if err != nil {
+				fmt.Fprintln(out, "info string", err)
+				continue
+			}
+			game = position
+		case "go":
+			moves := game.Position().ValidMoves()
+			if game.Outcome() != chess.NoOutcome || len(moves) == 0 {
+				fmt.Fprintln(out, "bestmove (none)")
+				continue
+			}
+			move := game.Position().UCI(moves[rand.Intn(len(moves))])
+			fmt.Fprintln(out, "info depth 1 score cp 0 pv", move)
+			fmt.Fprintln(out, "bestmove", move)
+		case "quit":
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// stubPosition replays "startpos" or "fen <fen>", then the moves after
+// "moves".
+func stubPosition(args []string) (*variant.Game, error) {
+	fen := ""
+	if len(args) > 0 && args[0] == "fen" {
+		end := len(args)
+		for i, arg := range args {
+			if arg == "moves" {
+				end = i
+				break
+			}
+		}
+		fen = strings.Join(args[1:end], " ")
+	}
+
+	game, err := variant.NewGame(variant.Standard, fen)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, arg := range args {
+		if arg != "moves" {
+			continue
+		}
+		for _, move := range args[i+1:] {
+			if err := game.MoveStr(move); err != nil {
+				return nil, err
+			}
+		}
+		break
+	}
+
+	return game, nil
+}
@@ -0,0 +1,84 @@
+package variant
+
+import "github.com/notnil/chess"
+
+var (
+	knightSteps   = [][2]int{{1, 2}, {2, 1}, {2, -1}, {1, -2}, {-1, -2}, {-2, -1}, {-2, 1}, {-1, 2}}
+	kingSteps     = [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	diagonalSteps = [][2]int{{1, 1}, {-1, 1}, {-1, -1}, {1, -1}}
+	straightSteps = [][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+)
+
+// attacked reports whether any piece of color by attacks sq on board.
+func attacked(board *chess.Board, sq chess.Square, by chess.Color) bool {
+	file, rank := int(sq.File()), int(sq.Rank())
+
+	pieceAt := func(f, r int) chess.Piece {
+		if f < 0 || f > 7 || r < 0 || r > 7 {
+			return chess.NoPiece
+		}
+		return board.Piece(chess.NewSquare(chess.File(f), chess.Rank(r)))
+	}
+
+	// Pawns attack forwards, so look backwards from sq
+	back := -1
+	if by == chess.Black {
+		back = 1
+	}
+	for _, df := range []int{-1, 1} {
+		if pieceAt(file+df, rank+back) == chess.NewPiece(chess.Pawn, by) {
+			return true
+		}
+	}
+
+	for _, step := range knightSteps {
+		if pieceAt(file+step[0], rank+step[1]) == chess.NewPiece(chess.Knight, by) {
+			return true
+		}
+	}
+
+	for _, step := range kingSteps {
+		if pieceAt(file+step[0], rank+step[1]) == chess.NewPiece(chess.King, by) {
+			return true
+		}
+	}
+
+	sliders := []struct {
+		steps [][2]int
+		piece chess.PieceType
+	}{
+		{diagonalSteps, chess.Bishop},
+		{straightSteps, chess.Rook},
+	}
+
+	for _, slider := range sliders {
+		for _, step := range slider.steps {
+			for f, r := file+step[0], rank+step[1]; f >= 0 && f < 8 && r >= 0 && r < 8; f, r = f+step[0], r+step[1] {
+				piece := pieceAt(f, r)
+				if piece == chess.NoPiece {
+					continue
+				}
+				if piece.Color() == by && (piece.Type() == slider.piece || piece.Type() == chess.Queen) {
+					return true
+				}
+				break
+			}
+		}
+	}
+
+	return false
+}
+
+func inCheck(board *chess.Board, color chess.Color) bool {
+	king := kingSquare(board, color)
+	return king != chess.NoSquare && attacked(board, king, color.Other())
+}
+
+func kingSquare(board *chess.Board, color chess.Color) chess.Square {
+	for sq, piece := range board.SquareMap() {
+		if piece == chess.NewPiece(chess.King, color) {
+			return sq
+		}
+	}
+	return chess.NoSquare
+}
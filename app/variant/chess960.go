@@ -0,0 +1,49 @@
+package variant
+
+import "strings"
+
+// knightPlacements are the squares the two knights take among the five left
+// after the bishops and queen, in Scharnagl numbering.
+var knightPlacements = [10][2]int{
+	{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4},
+}
+
+// Chess960FEN returns start position n of the 960, numbered as by Scharnagl
+// so that 518 is the standard position.
+func Chess960FEN(n int) string {
+	rank := make([]byte, 8)
+
+	rank[2*(n%4)+1] = 'b'
+	n /= 4
+	rank[2*(n%4)] = 'b'
+	n /= 4
+
+	place := func(piece byte, index int) {
+		for i := range rank {
+			if rank[i] != 0 {
+				continue
+			}
+			if index == 0 {
+				rank[i] = piece
+				return
+			}
+			index--
+		}
+	}
+
+	place('q', n%6)
+	n /= 6
+
+	// Placing the second knight first keeps the first one's index valid
+	knights := knightPlacements[n]
+	place('n', knights[1])
+	place('n', knights[0])
+
+	// The king goes between the rooks
+	place('r', 0)
+	place('k', 0)
+	place('r', 0)
+
+	black := string(rank)
+	return black + "/pppppppp/8/8/8/8/PPPPPPPP/" + strings.ToUpper(black) + " w KQkq - 0 1"
+}
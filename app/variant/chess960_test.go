@@ -0,0 +1,55 @@
+package variant
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestChess960FEN(t *testing.T) {
+	if fen := Chess960FEN(518); fen != StandardFEN {
+		t.Errorf("expected position 518 to be the standard one, got %s", fen)
+	}
+
+	seen := map[string]bool{}
+	for n := 0; n < 960; n++ {
+		fen := Chess960FEN(n)
+		seen[fen] = true
+
+		pos, err := parseFEN(Chess960, fen)
+		if err != nil {
+			t.Fatalf("position %d: %v", n, err)
+		}
+
+		rank := strings.Split(fen, "/")[0]
+		king, first, last := strings.Index(rank, "k"), strings.Index(rank, "r"), strings.LastIndex(rank, "r")
+		if first > king || king > last {
+			t.Errorf("position %d: king not between the rooks in %s", n, rank)
+		}
+
+		bishops := strings.Index(rank, "b") + strings.LastIndex(rank, "b")
+		if bishops%2 == 0 {
+			t.Errorf("position %d: bishops on the same colour in %s", n, rank)
+		}
+
+		if len(pos.castling) != 4 || pos.castlingFEN() != "KQkq" {
+			t.Errorf("position %d: expected full castling rights, got %s", n, pos.castlingFEN())
+		}
+	}
+
+	if len(seen) != 960 {
+		t.Errorf("expected 960 distinct positions, got %d", len(seen))
+	}
+}
+
+func TestChess960Game(t *testing.T) {
+	game, err := NewGame(Chess960, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if game.Position().Turn() != chess.White || game.Outcome() != chess.NoOutcome {
+		t.Errorf("expected a fresh game, got %s", game.StartFEN())
+	}
+}
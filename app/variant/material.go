@@ -0,0 +1,51 @@
+package variant
+
+import "github.com/notnil/chess"
+
+// hasMatingMaterial reports whether color could still deliver mate.
+func hasMatingMaterial(board *chess.Board, color chess.Color) bool {
+	minors := 0
+	for _, piece := range board.SquareMap() {
+		if piece.Color() != color {
+			continue
+		}
+
+		switch piece.Type() {
+		case chess.Queen, chess.Rook, chess.Pawn:
+			return true
+		case chess.Bishop, chess.Knight:
+			minors++
+		}
+	}
+
+	return minors >= 2
+}
+
+// sufficientMaterial reports whether either side can still mate, by the
+// same rules as notnil/chess: king against king, a single minor piece, or
+// bishops all on one colour of squares cannot.
+func sufficientMaterial(board *chess.Board) bool {
+	knights, bishops := 0, map[bool]int{}
+
+	for sq, piece := range board.SquareMap() {
+		switch piece.Type() {
+		case chess.Queen, chess.Rook, chess.Pawn:
+			return true
+		case chess.Knight:
+			knights++
+		case chess.Bishop:
+			light := (int(sq.File())+int(sq.Rank()))%2 == 1
+			bishops[light]++
+		}
+	}
+
+	minors := knights + bishops[true] + bishops[false]
+	switch {
+	case minors <= 1:
+		return false
+	case knights == 0 && (bishops[true] == 0 || bishops[false] == 0):
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,88 @@
+package variant
+
+import (
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestCanWin(t *testing.T) {
+	positions := []struct {
+		Name     string
+		Variant  string
+		FEN      string
+		Color    chess.Color
+		Expected bool
+	}{
+		{
+			Name:     "Lone King",
+			Variant:  Standard,
+			FEN:      "8/8/8/4k3/8/8/8/4K3 w - - 0 1",
+			Color:    chess.White,
+			Expected: false,
+		},
+		{
+			Name:     "King And Knight",
+			Variant:  Standard,
+			FEN:      "8/8/8/4k3/8/8/8/4KN2 w - - 0 1",
+			Color:    chess.White,
+			Expected: false,
+		},
+		{
+			Name:     "King And Two Bishops",
+			Variant:  Standard,
+			FEN:      "8/8/8/4k3/8/8/8/2B1KB2 w - - 0 1",
+			Color:    chess.White,
+			Expected: true,
+		},
+		{
+			Name:     "King And Pawn",
+			Variant:  Standard,
+			FEN:      "8/8/8/4k3/8/8/3p4/4K3 w - - 0 1",
+			Color:    chess.Black,
+			Expected: true,
+		},
+		{
+			Name:     "Lone King On The Hill",
+			Variant:  KingOfTheHill,
+			FEN:      "8/8/4k3/8/8/8/8/4K3 w - - 0 1",
+			Color:    chess.White,
+			Expected: true,
+		},
+		{
+			Name:     "King And Knight Giving Checks",
+			Variant:  ThreeCheck,
+			FEN:      "8/8/8/4k3/8/8/8/4KN2 w - - 0 1",
+			Color:    chess.White,
+			Expected: true,
+		},
+		{
+			Name:     "Lone King In Three Check",
+			Variant:  ThreeCheck,
+			FEN:      "8/8/8/4k3/8/8/8/4KN2 w - - 0 1",
+			Color:    chess.Black,
+			Expected: false,
+		},
+		{
+			Name:     "Lone King In Crazyhouse",
+			Variant:  Crazyhouse,
+			FEN:      "8/8/8/4k3/8/8/8/4K3[] w - - 0 1",
+			Color:    chess.White,
+			Expected: true,
+		},
+	}
+
+	for _, tc := range positions {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			game, err := NewGame(tc.Variant, tc.FEN)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := game.CanWin(tc.Color); got != tc.Expected {
+				t.Errorf("expected %v, got %v", tc.Expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,696 @@
+package variant
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+// Position is a chess.Position plus what the library does not track:
+// castling rights by rook square, so castling works from any Chess960
+// start, crazyhouse pockets and promoted pieces, and the checks given in
+// three-check. The library position never has castling rights of its own.
+type Position struct {
+	variant  string
+	pos      *chess.Position
+	castling []chess.Square
+	pockets  [3][7]int
+	promoted map[chess.Square]bool
+	checks   [3]int
+	inCheck  bool
+
+	// key identifies the position for repetitions
+	key        string
+	validMoves []*Move
+}
+
+// Move is a move in a Position. Castling is the king moving onto its own
+// rook, and crazyhouse drops have no origin square.
+type Move struct {
+	s1, s2 chess.Square
+	promo  chess.PieceType
+	drop   chess.PieceType
+	castle bool
+	move   *chess.Move
+}
+
+func (m *Move) S1() chess.Square {
+	return m.s1
+}
+
+func (m *Move) S2() chess.Square {
+	return m.s2
+}
+
+func (m *Move) Promo() chess.PieceType {
+	return m.promo
+}
+
+// Drop returns the piece type dropped, chess.NoPieceType for board moves.
+func (m *Move) Drop() chess.PieceType {
+	return m.drop
+}
+
+var dropTypes = []chess.PieceType{chess.Queen, chess.Rook, chess.Bishop, chess.Knight, chess.Pawn}
+
+// parseFEN reads a FEN, X-FEN or Shredder-FEN. Crazyhouse pockets follow the
+// board in brackets and promoted pieces are marked with a tilde; three-check
+// FENs end with the checks given, as in "+1+0".
+func parseFEN(name, fen string) (*Position, error) {
+	fields := strings.Fields(fen)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("invalid fen: %s", fen)
+	}
+	for len(fields) < 6 {
+		fields = append(fields, map[int]string{4: "0", 5: "1"}[len(fields)])
+	}
+
+	p := &Position{variant: name, promoted: map[chess.Square]bool{}}
+
+	board, pocket, _ := strings.Cut(fields[0], "[")
+	if name == Crazyhouse {
+		for _, c := range strings.TrimSuffix(pocket, "]") {
+			piece, ok := fenPieces[c]
+			if !ok || piece.Type() == chess.King {
+				return nil, fmt.Errorf("invalid fen: bad pocket %s", pocket)
+			}
+			p.pockets[piece.Color()][piece.Type()]++
+		}
+	}
+
+	board, err := p.parsePromoted(board)
+	if err != nil {
+		return nil, err
+	}
+
+	pos := &chess.Position{}
+	libraryFEN := strings.Join([]string{board, fields[1], "-", fields[3], fields[4], fields[5]}, " ")
+	if err := pos.UnmarshalText([]byte(libraryFEN)); err != nil {
+		return nil, fmt.Errorf("invalid fen: %v", err)
+	}
+	p.pos = pos
+
+	if err := p.parseCastling(fields[2]); err != nil {
+		return nil, err
+	}
+
+	if name == ThreeCheck && len(fields) > 6 {
+		if _, err := fmt.Sscanf(fields[6], "+%d+%d", &p.checks[chess.White], &p.checks[chess.Black]); err != nil {
+			return nil, fmt.Errorf("invalid fen: bad check count %s", fields[6])
+		}
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+
+	p.update()
+	return p, nil
+}
+
+// parsePromoted records the squares of pieces marked with a tilde and
+// returns the board without the marks.
+func (p *Position) parsePromoted(board string) (string, error) {
+	if !strings.Contains(board, "~") {
+		return board, nil
+	}
+
+	rank, file := 7, 0
+	for i, c := range board {
+		switch {
+		case c == '/':
+			rank, file = rank-1, 0
+		case c >= '1' && c <= '8':
+			file += int(c - '0')
+		case c == '~':
+			if i == 0 || file == 0 {
+				return "", fmt.Errorf("invalid fen: misplaced ~")
+			}
+			p.promoted[chess.NewSquare(chess.File(file-1), chess.Rank(rank))] = true
+		default:
+			file++
+		}
+	}
+
+	return strings.ReplaceAll(board, "~", ""), nil
+}
+
+// parseCastling resolves K and Q to the outermost rook on that side of the
+// king, and file letters to the rook on that file.
+func (p *Position) parseCastling(field string) error {
+	if field == "-" {
+		return nil
+	}
+
+	board := p.Board()
+	for _, c := range field {
+		color, back := chess.White, chess.Rank1
+		if c >= 'a' && c <= 'z' {
+			color, back = chess.Black, chess.Rank8
+		}
+
+		king := kingSquare(board, color)
+		if king == chess.NoSquare || king.Rank() != back {
+			return fmt.Errorf("invalid fen: castling without a king on the back rank")
+		}
+
+		rook := chess.NoSquare
+		switch lower := c | 0x20; {
+		case lower == 'k':
+			for f := int(chess.FileH); f > int(king.File()) && rook == chess.NoSquare; f-- {
+				rook = rookOn(board, chess.NewSquare(chess.File(f), back), color)
+			}
+		case lower == 'q':
+			for f := int(chess.FileA); f < int(king.File()) && rook == chess.NoSquare; f++ {
+				rook = rookOn(board, chess.NewSquare(chess.File(f), back), color)
+			}
+		case lower >= 'a' && lower <= 'h':
+			rook = rookOn(board, chess.NewSquare(chess.File(lower-'a'), back), color)
+		default:
+			return fmt.Errorf("invalid fen: bad castling rights %s", field)
+		}
+
+		if rook == chess.NoSquare {
+			return fmt.Errorf("invalid fen: no rook to castle with for %c", c)
+		}
+		p.castling = append(p.castling, rook)
+	}
+
+	return nil
+}
+
+func rookOn(board *chess.Board, sq chess.Square, color chess.Color) chess.Square {
+	if board.Piece(sq) == chess.NewPiece(chess.Rook, color) {
+		return sq
+	}
+	return chess.NoSquare
+}
+
+// validate rejects positions that cannot arise in a game: a missing or
+// extra king, pawns on the back ranks, the side not to move in check, or an
+// en passant square no pawn just skipped.
+func (p *Position) validate() error {
+	kings := map[chess.Color]int{}
+	for sq, piece := range p.Board().SquareMap() {
+		if piece.Type() == chess.King {
+			kings[piece.Color()]++
+		}
+		if piece.Type() == chess.Pawn && (sq.Rank() == chess.Rank1 || sq.Rank() == chess.Rank8) {
+			return fmt.Errorf("invalid fen: pawn on %s", sq)
+		}
+	}
+
+	if kings[chess.White] != 1 || kings[chess.Black] != 1 {
+		return fmt.Errorf("invalid fen: each side needs exactly one king")
+	}
+
+	if inCheck(p.Board(), p.Turn().Other()) {
+		return fmt.Errorf("invalid fen: the side not to move is in check")
+	}
+
+	if ep := p.pos.EnPassantSquare(); ep != chess.NoSquare && !p.validEnPassant(ep) {
+		return fmt.Errorf("invalid fen: bad en passant square %s", ep)
+	}
+
+	return nil
+}
+
+// validEnPassant reports whether an opponent pawn can just have passed over
+// ep with a double step.
+func (p *Position) validEnPassant(ep chess.Square) bool {
+	rank, step, pawn := chess.Rank6, 1, chess.BlackPawn
+	if p.Turn() == chess.Black {
+		rank, step, pawn = chess.Rank3, -1, chess.WhitePawn
+	}
+	if ep.Rank() != rank {
+		return false
+	}
+
+	board := p.Board()
+	from := chess.NewSquare(ep.File(), chess.Rank(int(rank)+step))
+	to := chess.NewSquare(ep.File(), chess.Rank(int(rank)-step))
+
+	return board.Piece(ep) == chess.NoPiece && board.Piece(from) == chess.NoPiece && board.Piece(to) == pawn
+}
+
+func (p *Position) Board() *chess.Board {
+	return p.pos.Board()
+}
+
+func (p *Position) Turn() chess.Color {
+	return p.pos.Turn()
+}
+
+func (p *Position) HalfMoveClock() int {
+	return p.pos.HalfMoveClock()
+}
+
+// InCheck reports whether the side to move is in check.
+func (p *Position) InCheck() bool {
+	return p.inCheck
+}
+
+// Checks returns how many checks color has given, counted in three-check.
+func (p *Position) Checks(color chess.Color) int {
+	return p.checks[color]
+}
+
+// Pocket returns how many pieces of type t color holds in crazyhouse.
+func (p *Position) Pocket(color chess.Color, t chess.PieceType) int {
+	return p.pockets[color][t]
+}
+
+// String returns the position as X-FEN, with the crazyhouse pockets or
+// three-check counts when the variant has them.
+func (p *Position) String() string {
+	fields := strings.Fields(p.pos.String())
+
+	board := p.boardFEN()
+	if p.variant == Crazyhouse {
+		board += "[" + p.pocketFEN() + "]"
+	}
+
+	fen := strings.Join([]string{board, fields[1], p.castlingFEN(), fields[3], fields[4], fields[5]}, " ")
+	if p.variant == ThreeCheck {
+		fen += fmt.Sprintf(" +%d+%d", p.checks[chess.White], p.checks[chess.Black])
+	}
+	return fen
+}
+
+func (p *Position) boardFEN() string {
+	if len(p.promoted) == 0 {
+		return p.Board().String()
+	}
+
+	var sb strings.Builder
+	for r := 7; r >= 0; r-- {
+		empty := 0
+		for f := 0; f < 8; f++ {
+			sq := chess.NewSquare(chess.File(f), chess.Rank(r))
+			piece := p.Board().Piece(sq)
+			if piece == chess.NoPiece {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteString(strconv.Itoa(empty))
+				empty = 0
+			}
+			sb.WriteString(fenChar(piece))
+			if p.promoted[sq] {
+				sb.WriteString("~")
+			}
+		}
+		if empty > 0 {
+			sb.WriteString(strconv.Itoa(empty))
+		}
+		if r > 0 {
+			sb.WriteString("/")
+		}
+	}
+	return sb.String()
+}
+
+func (p *Position) pocketFEN() string {
+	var sb strings.Builder
+	for _, color := range []chess.Color{chess.White, chess.Black} {
+		for _, t := range dropTypes {
+			sb.WriteString(strings.Repeat(fenChar(chess.NewPiece(t, color)), p.pockets[color][t]))
+		}
+	}
+	return sb.String()
+}
+
+// castlingFEN writes K or Q for the outermost rook on a side and the rook's
+// file otherwise, which is plain KQkq outside Chess960.
+func (p *Position) castlingFEN() string {
+	var sb strings.Builder
+	board := p.Board()
+
+	for _, color := range []chess.Color{chess.White, chess.Black} {
+		king := kingSquare(board, color)
+
+		for _, kingside := range []bool{true, false} {
+			for _, rook := range p.castling {
+				piece := board.Piece(rook)
+				if piece.Color() != color || (rook.File() > king.File()) != kingside {
+					continue
+				}
+
+				letter := rook.File().String()
+				if p.outermost(rook, king) {
+					letter = map[bool]string{true: "k", false: "q"}[kingside]
+				}
+				if color == chess.White {
+					letter = strings.ToUpper(letter)
+				}
+				sb.WriteString(letter)
+			}
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "-"
+	}
+	return sb.String()
+}
+
+// outermost reports whether no other rook stands between rook and the edge
+// of the board on its side of the king.
+func (p *Position) outermost(rook, king chess.Square) bool {
+	step := 1
+	if rook.File() < king.File() {
+		step = -1
+	}
+
+	piece := p.Board().Piece(rook)
+	for f := int(rook.File()) + step; f >= 0 && f < 8; f += step {
+		if p.Board().Piece(chess.NewSquare(chess.File(f), rook.Rank())) == piece {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidMoves returns the legal moves: the library's board moves, then
+// castling, then drops.
+func (p *Position) ValidMoves() []*Move {
+	if p.validMoves != nil {
+		return append([]*Move(nil), p.validMoves...)
+	}
+
+	moves := []*Move{}
+	for _, m := range p.pos.ValidMoves() {
+		moves = append(moves, &Move{s1: m.S1(), s2: m.S2(), promo: m.Promo(), move: m})
+	}
+	moves = append(moves, p.castleMoves()...)
+	if p.variant == Crazyhouse {
+		moves = append(moves, p.dropMoves()...)
+	}
+
+	p.validMoves = moves
+	return append([]*Move(nil), moves...)
+}
+
+func (p *Position) castleMoves() []*Move {
+	if p.inCheck {
+		return nil
+	}
+
+	turn := p.Turn()
+	board := p.Board()
+	king := kingSquare(board, turn)
+	moves := []*Move{}
+
+	for _, rook := range p.castling {
+		if board.Piece(rook) != chess.NewPiece(chess.Rook, turn) || rook.Rank() != king.Rank() {
+			continue
+		}
+
+		if p.canCastle(king, rook) {
+			moves = append(moves, &Move{s1: king, s2: rook, castle: true})
+		}
+	}
+
+	return moves
+}
+
+// canCastle applies the Chess960 rules, which cover standard castling too:
+// every square either piece crosses is empty but for the two of them, and
+// the king is not in check on any square it passes through or lands on.
+func (p *Position) canCastle(king, rook chess.Square) bool {
+	board := p.Board()
+	kingTo, rookTo := castleSquares(king, rook)
+
+	for _, span := range [][2]chess.Square{{king, kingTo}, {rook, rookTo}} {
+		for sq := min(span[0], span[1]); sq <= max(span[0], span[1]); sq++ {
+			if sq != king && sq != rook && board.Piece(sq) != chess.NoPiece {
+				return false
+			}
+		}
+	}
+
+	step := chess.Square(1)
+	if kingTo < king {
+		step = -1
+	}
+	for sq := king; sq != kingTo; {
+		sq += step
+		if attacked(board, sq, p.Turn().Other()) {
+			return false
+		}
+	}
+
+	// The rook may have been shielding the king's new square
+	return !inCheck(castled(board, king, rook), p.Turn())
+}
+
+// castleSquares returns where the king and rook end up: the g and f files
+// castling towards the h-file, the c and d files otherwise.
+func castleSquares(king, rook chess.Square) (chess.Square, chess.Square) {
+	if rook.File() > king.File() {
+		return chess.NewSquare(chess.FileG, king.Rank()), chess.NewSquare(chess.FileF, king.Rank())
+	}
+	return chess.NewSquare(chess.FileC, king.Rank()), chess.NewSquare(chess.FileD, king.Rank())
+}
+
+func castled(board *chess.Board, king, rook chess.Square) *chess.Board {
+	kingTo, rookTo := castleSquares(king, rook)
+
+	squares := board.SquareMap()
+	kingPiece, rookPiece := squares[king], squares[rook]
+	delete(squares, king)
+	delete(squares, rook)
+	squares[kingTo] = kingPiece
+	squares[rookTo] = rookPiece
+
+	return chess.NewBoard(squares)
+}
+
+// dropMoves lists the pieces in the pocket that can be put on an empty
+// square. Pawns never go on the first or last rank, and a drop must block
+// a check if there is one.
+func (p *Position) dropMoves() []*Move {
+	turn := p.Turn()
+	board := p.Board()
+	moves := []*Move{}
+
+	for _, t := range dropTypes {
+		if p.pockets[turn][t] == 0 {
+			continue
+		}
+
+		for sq := chess.A1; sq <= chess.H8; sq++ {
+			if board.Piece(sq) != chess.NoPiece {
+				continue
+			}
+			if t == chess.Pawn && (sq.Rank() == chess.Rank1 || sq.Rank() == chess.Rank8) {
+				continue
+			}
+			if p.inCheck && inCheck(dropped(board, sq, chess.NewPiece(t, turn)), turn) {
+				continue
+			}
+
+			moves = append(moves, &Move{s1: chess.NoSquare, s2: sq, drop: t})
+		}
+	}
+
+	return moves
+}
+
+func dropped(board *chess.Board, sq chess.Square, piece chess.Piece) *chess.Board {
+	squares := board.SquareMap()
+	squares[sq] = piece
+	return chess.NewBoard(squares)
+}
+
+// Update returns the position after m, which must be one of ValidMoves.
+func (p *Position) Update(m *Move) *Position {
+	turn := p.Turn()
+	next := &Position{
+		variant:  p.variant,
+		pockets:  p.pockets,
+		promoted: map[chess.Square]bool{},
+		checks:   p.checks,
+	}
+	for sq := range p.promoted {
+		next.promoted[sq] = true
+	}
+
+	switch {
+	case m.drop != chess.NoPieceType:
+		halfMove := p.HalfMoveClock() + 1
+		if m.drop == chess.Pawn {
+			halfMove = 0
+		}
+		next.pos = p.successor(dropped(p.Board(), m.s2, chess.NewPiece(m.drop, turn)), halfMove)
+		next.pockets[turn][m.drop]--
+		next.castling = p.castling
+
+	case m.castle:
+		next.pos = p.successor(castled(p.Board(), m.s1, m.s2), p.HalfMoveClock()+1)
+		for _, rook := range p.castling {
+			if p.Board().Piece(rook).Color() != turn {
+				next.castling = append(next.castling, rook)
+			}
+		}
+
+	default:
+		next.pos = p.pos.Update(m.move)
+
+		// Moving the king loses both rights, moving or losing a rook one
+		king := p.Board().Piece(m.s1).Type() == chess.King
+		for _, rook := range p.castling {
+			if rook == m.s1 || rook == m.s2 || (king && p.Board().Piece(rook).Color() == turn) {
+				continue
+			}
+			next.castling = append(next.castling, rook)
+		}
+
+		if p.variant == Crazyhouse {
+			p.capture(next, m)
+		}
+	}
+
+	next.inCheck = inCheck(next.Board(), next.Turn())
+	if next.inCheck && p.variant == ThreeCheck {
+		next.checks[turn]++
+	}
+
+	next.key = next.positionKey()
+	return next
+}
+
+// capture moves a captured piece into the capturer's pocket, as a pawn if it
+// had been promoted, and keeps track of promoted pieces.
+func (p *Position) capture(next *Position, m *Move) {
+	turn := p.Turn()
+
+	captured := p.Board().Piece(m.s2)
+	if m.move.HasTag(chess.EnPassant) {
+		captured = chess.NewPiece(chess.Pawn, turn.Other())
+	}
+
+	if captured != chess.NoPiece {
+		t := captured.Type()
+		if p.promoted[m.s2] {
+			t = chess.Pawn
+		}
+		next.pockets[turn][t]++
+	}
+
+	delete(next.promoted, m.s2)
+	if next.promoted[m.s1] || m.promo != chess.NoPieceType {
+		next.promoted[m.s2] = true
+	}
+	delete(next.promoted, m.s1)
+}
+
+// successor builds the library position after a move it cannot play
+// itself, with the other side to move and no en passant square.
+func (p *Position) successor(board *chess.Board, halfMove int) *chess.Position {
+	fields := strings.Fields(p.pos.String())
+
+	moveCount, _ := strconv.Atoi(fields[5])
+	if p.Turn() == chess.Black {
+		moveCount++
+	}
+
+	fen := fmt.Sprintf("%s %s - - %d %d", board, p.Turn().Other(), halfMove, moveCount)
+
+	pos := &chess.Position{}
+	if err := pos.UnmarshalText([]byte(fen)); err != nil {
+		panic(err)
+	}
+	return pos
+}
+
+// update fills in what is derived from the rest of the position.
+func (p *Position) update() {
+	p.inCheck = inCheck(p.Board(), p.Turn())
+	p.key = p.positionKey()
+}
+
+// positionKey is the FEN without the move counters.
+func (p *Position) positionKey() string {
+	fields := strings.Fields(p.String())
+	return strings.Join(append(fields[:4], fields[6:]...), " ")
+}
+
+// Decode finds the legal move written as s in SAN or UCI.
+func (p *Position) Decode(s string) (*Move, error) {
+	s = strings.TrimRight(s, "+#!?")
+	s = strings.Replace(s, "0-0", "O-O", 1)
+	if strings.HasPrefix(s, "@") {
+		s = "P" + s
+	}
+
+	for _, m := range p.ValidMoves() {
+		if s == p.UCI(m) || s == p.san(m) {
+			return m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("illegal move %s in %s", s, p)
+}
+
+// SAN writes m in standard algebraic notation. Drops are written as N@f3.
+func (p *Position) SAN(m *Move) string {
+	san := p.san(m)
+
+	next := p.Update(m)
+	if next.InCheck() {
+		if len(next.ValidMoves()) == 0 {
+			return san + "#"
+		}
+		return san + "+"
+	}
+	return san
+}
+
+// san is the SAN of m without the check or mate sign, which the library
+// would get wrong when a drop can block the check.
+func (p *Position) san(m *Move) string {
+	switch {
+	case m.drop != chess.NoPieceType:
+		return pieceLetter(m.drop) + "@" + m.s2.String()
+	case m.castle && m.s2.File() > m.s1.File():
+		return "O-O"
+	case m.castle:
+		return "O-O-O"
+	default:
+		return strings.TrimRight(chess.AlgebraicNotation{}.Encode(p.pos, m.move), "+#")
+	}
+}
+
+// UCI writes m in UCI notation. Chess960 castling is written as the king
+// taking its own rook, as UCI engines expect in that variant.
+func (p *Position) UCI(m *Move) string {
+	switch {
+	case m.drop != chess.NoPieceType:
+		return pieceLetter(m.drop) + "@" + m.s2.String()
+	case m.castle && p.variant == Chess960:
+		return m.s1.String() + m.s2.String()
+	case m.castle:
+		kingTo, _ := castleSquares(m.s1, m.s2)
+		return m.s1.String() + kingTo.String()
+	default:
+		return chess.UCINotation{}.Encode(p.pos, m.move)
+	}
+}
+
+var fenPieces = map[rune]chess.Piece{
+	'K': chess.WhiteKing, 'Q': chess.WhiteQueen, 'R': chess.WhiteRook,
+	'B': chess.WhiteBishop, 'N': chess.WhiteKnight, 'P': chess.WhitePawn,
+	'k': chess.BlackKing, 'q': chess.BlackQueen, 'r': chess.BlackRook,
+	'b': chess.BlackBishop, 'n': chess.BlackKnight, 'p': chess.BlackPawn,
+}
+
+func fenChar(piece chess.Piece) string {
+	if piece.Color() == chess.White {
+		return strings.ToUpper(piece.Type().String())
+	}
+	return piece.Type().String()
+}
@@ -0,0 +1,191 @@
+package variant
+
+import (
+	"testing"
+)
+
+func perft(pos *Position, depth int) int {
+	if depth == 0 {
+		return 1
+	}
+
+	moves := pos.ValidMoves()
+	if depth == 1 {
+		return len(moves)
+	}
+
+	nodes := 0
+	for _, m := range moves {
+		nodes += perft(pos.Update(m), depth-1)
+	}
+	return nodes
+}
+
+func TestPerft(t *testing.T) {
+	positions := []struct {
+		Name     string
+		Variant  string
+		FEN      string
+		Depth    int
+		Expected int
+	}{
+		{
+			Name:     "Start Position",
+			Variant:  Standard,
+			FEN:      StandardFEN,
+			Depth:    3,
+			Expected: 8902,
+		},
+		{
+			Name:     "Kiwipete",
+			Variant:  Standard,
+			FEN:      "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1",
+			Depth:    2,
+			Expected: 2039,
+		},
+		{
+			Name:     "Chess960 Shredder FEN",
+			Variant:  Chess960,
+			FEN:      "bqnb1rkr/pp3ppp/3ppn2/2p5/5P2/P2P4/NPP1P1PP/BQ1BNRKR w HFhf - 2 9",
+			Depth:    3,
+			Expected: 12189,
+		},
+		{
+			Name:     "Chess960 Castling Through Rook",
+			Variant:  Chess960,
+			FEN:      "2nnrbkr/p1qppppp/8/1ppb4/6PP/3PP3/PPP2P2/BQNNRBKR w HEhe - 1 9",
+			Depth:    3,
+			Expected: 18002,
+		},
+	}
+
+	for _, tc := range positions {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			pos, err := parseFEN(tc.Variant, tc.FEN)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if nodes := perft(pos, tc.Depth); nodes != tc.Expected {
+				t.Errorf("expected %d nodes, got %d", tc.Expected, nodes)
+			}
+		})
+	}
+}
+
+func TestCastling(t *testing.T) {
+	castles := []struct {
+		Name    string
+		Variant string
+		FEN     string
+		Move    string
+		UCI     string
+		After   string
+	}{
+		{
+			Name:    "Standard King Side",
+			Variant: Standard,
+			FEN:     "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1",
+			Move:    "O-O",
+			UCI:     "e1g1",
+			After:   "r3k2r/8/8/8/8/8/8/R4RK1 b kq - 1 1",
+		},
+		{
+			Name:    "Chess960 King Crosses The Board",
+			Variant: Chess960,
+			FEN:     "rk5r/8/8/8/8/8/8/RK4R1 w KQkq - 0 1",
+			Move:    "O-O",
+			UCI:     "b1g1",
+			After:   "rk5r/8/8/8/8/8/8/R4RK1 b kq - 1 1",
+		},
+		{
+			Name:    "Chess960 Queen Side From UCI",
+			Variant: Chess960,
+			FEN:     "1r2k1r1/8/8/8/8/8/8/1R2K1R1 w KQkq - 0 1",
+			Move:    "e1b1",
+			UCI:     "e1b1",
+			After:   "1r2k1r1/8/8/8/8/8/8/2KR2R1 b kq - 1 1",
+		},
+		{
+			Name:    "Chess960 King Already On g1",
+			Variant: Chess960,
+			FEN:     "4k3/8/8/8/8/8/8/R5KR w K - 0 1",
+			Move:    "O-O",
+			UCI:     "g1h1",
+			After:   "4k3/8/8/8/8/8/8/R4RK1 b - - 1 1",
+		},
+		{
+			Name:    "Inner Rook Keeps Its File Letter",
+			Variant: Chess960,
+			FEN:     "4k3/8/8/8/8/8/8/R1R1K3 w C - 0 1",
+			Move:    "Kf1",
+			UCI:     "e1f1",
+			After:   "4k3/8/8/8/8/8/8/R1R2K2 b - - 1 1",
+		},
+	}
+
+	for _, tc := range castles {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			game, err := NewGame(tc.Variant, tc.FEN)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pos := game.Position()
+			move, err := pos.Decode(tc.Move)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if uci := pos.UCI(move); uci != tc.UCI {
+				t.Errorf("expected %s, got %s", tc.UCI, uci)
+			}
+
+			if after := pos.Update(move).String(); after != tc.After {
+				t.Errorf("expected %s, got %s", tc.After, after)
+			}
+		})
+	}
+}
+
+func TestCastlingRightsFEN(t *testing.T) {
+	pos, err := parseFEN(Chess960, "4k3/8/8/8/8/8/8/R1R1K3 w C - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fen := pos.String(); fen != "4k3/8/8/8/8/8/8/R1R1K3 w C - 0 1" {
+		t.Errorf("expected the inner rook's file letter, got %s", fen)
+	}
+
+	if _, err := pos.Decode("O-O-O"); err != nil {
+		t.Errorf("expected to castle with the c-file rook, got %v", err)
+	}
+}
+
+func TestInvalidFEN(t *testing.T) {
+	fens := []struct {
+		Name string
+		FEN  string
+	}{
+		{Name: "Missing Fields", FEN: "8/8/8/8/8/8/8/8"},
+		{Name: "No Kings", FEN: "8/8/8/8/8/8/8/8 w - - 0 1"},
+		{Name: "Pawn On Back Rank", FEN: "P3k3/8/8/8/8/8/8/4K3 w - - 0 1"},
+		{Name: "Opponent In Check", FEN: "4k3/8/8/8/8/8/8/4R1K1 w - - 0 1"},
+		{Name: "Castling Without Rook", FEN: "4k3/8/8/8/8/8/8/4K3 w K - 0 1"},
+		{Name: "En Passant On Wrong Rank", FEN: "4k3/8/8/8/4P3/8/8/4K3 w - e3 0 1"},
+		{Name: "En Passant Without Pawn", FEN: "4k3/8/8/8/8/8/8/4K3 b - e3 0 1"},
+		{Name: "Malformed En Passant", FEN: "4k3/8/8/8/8/8/8/4K3 w - z9 0 1"},
+	}
+
+	for _, tc := range fens {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			if _, err := parseFEN(Standard, tc.FEN); err == nil {
+				t.Errorf("expected %s to be rejected", tc.FEN)
+			}
+		})
+	}
+}
@@ -0,0 +1,264 @@
+// Package variant plays chess and its variants. Ordinary moves, SAN and
+// UCI come from notnil/chess; castling, crazyhouse drops and the variant
+// win conditions are handled here, where the library falls short.
+package variant
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/notnil/chess"
+)
+
+const (
+	Standard      = "standard"
+	Chess960      = "chess960"
+	KingOfTheHill = "king_of_the_hill"
+	ThreeCheck    = "three_check"
+	Crazyhouse    = "crazyhouse"
+)
+
+// Names lists every variant, standard first.
+var Names = []string{Standard, Chess960, KingOfTheHill, ThreeCheck, Crazyhouse}
+
+// pgnNames are the values of the PGN Variant tag.
+var pgnNames = map[string]string{
+	Standard:      "Standard",
+	Chess960:      "Chess960",
+	KingOfTheHill: "King of the Hill",
+	ThreeCheck:    "Three-check",
+	Crazyhouse:    "Crazyhouse",
+}
+
+// StandardFEN is the usual starting position.
+const StandardFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// Valid reports whether name is a known variant. The empty name is standard.
+func Valid(name string) bool {
+	_, ok := pgnNames[normalize(name)]
+	return ok
+}
+
+// PGNName returns the PGN Variant tag value for name.
+func PGNName(name string) string {
+	return pgnNames[normalize(name)]
+}
+
+// FromPGNName returns the variant whose PGN Variant tag is tag, ignoring
+// case. An empty tag is standard.
+func FromPGNName(tag string) (string, bool) {
+	if tag == "" {
+		return Standard, true
+	}
+
+	for name, pgnName := range pgnNames {
+		if strings.EqualFold(pgnName, tag) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// StartFEN returns the position a new game of name starts from: a random
+// Chess960 position, or the standard one for every other variant.
+func StartFEN(name string) string {
+	if normalize(name) == Chess960 {
+		return Chess960FEN(rand.Intn(960))
+	}
+	return StandardFEN
+}
+
+func normalize(name string) string {
+	if name == "" {
+		return Standard
+	}
+	return name
+}
+
+// Game is a game of one variant from its start position.
+type Game struct {
+	variant   string
+	positions []*Position
+	moves     []*Move
+	outcome   chess.Outcome
+	method    string
+}
+
+// NewGame starts a game of the variant name from fen, or from StartFEN if
+// fen is empty.
+func NewGame(name, fen string) (*Game, error) {
+	name = normalize(name)
+	if !Valid(name) {
+		return nil, fmt.Errorf("unknown variant %s", name)
+	}
+
+	if fen == "" {
+		fen = StartFEN(name)
+	}
+
+	pos, err := parseFEN(name, fen)
+	if err != nil {
+		return nil, err
+	}
+
+	game := &Game{
+		variant:   name,
+		positions: []*Position{pos},
+		outcome:   chess.NoOutcome,
+	}
+	game.updateOutcome()
+
+	return game, nil
+}
+
+func (g *Game) Variant() string {
+	return g.variant
+}
+
+// Position returns the current position.
+func (g *Game) Position() *Position {
+	return g.positions[len(g.positions)-1]
+}
+
+// Positions returns every position of the game, the start position first.
+func (g *Game) Positions() []*Position {
+	return append([]*Position(nil), g.positions...)
+}
+
+func (g *Game) Moves() []*Move {
+	return append([]*Move(nil), g.moves...)
+}
+
+// StartFEN returns the FEN the game started from.
+func (g *Game) StartFEN() string {
+	return g.positions[0].String()
+}
+
+func (g *Game) Outcome() chess.Outcome {
+	return g.outcome
+}
+
+// Method returns how the game ended, such as "checkmate", or "" while it is
+// in progress.
+func (g *Game) Method() string {
+	return g.method
+}
+
+// MoveStr plays a move given in SAN or UCI.
+func (g *Game) MoveStr(s string) error {
+	if g.outcome != chess.NoOutcome {
+		return fmt.Errorf("game is over: %s by %s", g.outcome, g.method)
+	}
+
+	move, err := g.Position().Decode(s)
+	if err != nil {
+		return err
+	}
+
+	g.moves = append(g.moves, move)
+	g.positions = append(g.positions, g.Position().Update(move))
+	g.updateOutcome()
+
+	return nil
+}
+
+// EligibleDraws returns the draws a player could claim in the current
+// position: threefold repetition and the fifty-move rule.
+func (g *Game) EligibleDraws() []string {
+	draws := []string{}
+
+	if g.repetitions() >= 3 {
+		draws = append(draws, "threefold_repetition")
+	}
+	if g.Position().HalfMoveClock() >= 100 {
+		draws = append(draws, "fifty_move_rule")
+	}
+
+	return draws
+}
+
+// DrawAgreement is the method of a game drawn by the players' agreement.
+const DrawAgreement = "draw_agreement"
+
+// Draw ends the game in a draw by method.
+func (g *Game) Draw(method string) {
+	g.outcome = chess.Draw
+	g.method = method
+}
+
+// CanWin reports whether color could still win the game on the board, used
+// to turn a timeout into a draw when the opponent cannot win.
+func (g *Game) CanWin(color chess.Color) bool {
+	board := g.Position().Board()
+
+	switch g.variant {
+	case KingOfTheHill, Crazyhouse:
+		// A bare king can still walk to the centre, and in crazyhouse
+		// captured pieces come back
+		return true
+	case ThreeCheck:
+		for _, piece := range board.SquareMap() {
+			if piece.Color() == color && piece.Type() != chess.King {
+				return true
+			}
+		}
+		return false
+	default:
+		return hasMatingMaterial(board, color)
+	}
+}
+
+// updateOutcome ends the game if the last move decided it. Variant wins come
+// first, then mate, then the draws nobody has to claim.
+func (g *Game) updateOutcome() {
+	pos := g.Position()
+	board := pos.Board()
+	mover := pos.Turn().Other()
+
+	switch {
+	case g.variant == KingOfTheHill && inCentre(kingSquare(board, mover)):
+		g.outcome, g.method = winner(mover), "king_in_centre"
+	case g.variant == ThreeCheck && pos.Checks(mover) >= 3:
+		g.outcome, g.method = winner(mover), "three_checks"
+	case len(pos.ValidMoves()) == 0 && pos.InCheck():
+		g.outcome, g.method = winner(mover), "checkmate"
+	case len(pos.ValidMoves()) == 0:
+		g.outcome, g.method = chess.Draw, "stalemate"
+	case (g.variant == Standard || g.variant == Chess960) && !sufficientMaterial(board):
+		g.outcome, g.method = chess.Draw, "insufficient_material"
+	case g.repetitions() >= 5:
+		g.outcome, g.method = chess.Draw, "fivefold_repetition"
+	case pos.HalfMoveClock() >= 150:
+		g.outcome, g.method = chess.Draw, "seventy_five_move_rule"
+	}
+}
+
+// repetitions counts how often the current position has occurred.
+func (g *Game) repetitions() int {
+	key := g.Position().key
+	count := 0
+
+	for _, pos := range g.positions {
+		if pos.key == key {
+			count++
+		}
+	}
+	return count
+}
+
+func inCentre(sq chess.Square) bool {
+	return sq == chess.D4 || sq == chess.E4 || sq == chess.D5 || sq == chess.E5
+}
+
+func winner(color chess.Color) chess.Outcome {
+	if color == chess.White {
+		return chess.WhiteWon
+	}
+	return chess.BlackWon
+}
+
+// pieceLetter is the SAN letter of a piece type, P for pawns.
+func pieceLetter(t chess.PieceType) string {
+	return strings.ToUpper(t.String())
+}
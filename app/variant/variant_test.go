@@ -0,0 +1,195 @@
+package variant
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/notnil/chess"
+)
+
+func TestOutcome(t *testing.T) {
+	games := []struct {
+		Name    string
+		Variant string
+		FEN     string
+		Moves   []string
+		Outcome chess.Outcome
+		Method  string
+	}{
+		{
+			Name:    "Fools Mate",
+			Variant: Standard,
+			Moves:   []string{"f3", "e5", "g4", "Qh4"},
+			Outcome: chess.BlackWon,
+			Method:  "checkmate",
+		},
+		{
+			Name:    "Stalemate",
+			Variant: Standard,
+			FEN:     "k7/8/1Q6/8/8/8/8/4K3 b - - 0 1",
+			Outcome: chess.Draw,
+			Method:  "stalemate",
+		},
+		{
+			Name:    "Insufficient Material",
+			Variant: Standard,
+			FEN:     "k7/8/8/8/8/8/8/4K1N1 w - - 0 1",
+			Outcome: chess.Draw,
+			Method:  "insufficient_material",
+		},
+		{
+			Name:    "King Reaches The Hill",
+			Variant: KingOfTheHill,
+			Moves:   []string{"e4", "d5", "exd5", "Kd7", "Ke2", "Kd6", "Ke3", "Kxd5"},
+			Outcome: chess.BlackWon,
+			Method:  "king_in_centre",
+		},
+		{
+			Name:    "Bare Kings On The Hill",
+			Variant: KingOfTheHill,
+			FEN:     "k7/8/8/8/8/8/8/4K1N1 w - - 0 1",
+			Outcome: chess.NoOutcome,
+		},
+		{
+			Name:    "Third Check",
+			Variant: ThreeCheck,
+			FEN:     "4k3/8/8/8/8/8/8/4K2R w K - 0 1 +2+0",
+			Moves:   []string{"Rh8"},
+			Outcome: chess.WhiteWon,
+			Method:  "three_checks",
+		},
+		{
+			Name:    "Second Check",
+			Variant: ThreeCheck,
+			FEN:     "4k3/8/8/8/8/8/8/4K2R w K - 0 1 +1+0",
+			Moves:   []string{"Rh8"},
+			Outcome: chess.NoOutcome,
+		},
+		{
+			Name:    "Drop Blocks Back Rank Mate",
+			Variant: Crazyhouse,
+			FEN:     "R6k/6pp/8/8/8/8/8/4K3[n] b - - 0 1",
+			Outcome: chess.NoOutcome,
+		},
+		{
+			Name:    "Back Rank Mate With An Empty Pocket",
+			Variant: Crazyhouse,
+			FEN:     "R6k/6pp/8/8/8/8/8/4K3[] b - - 0 1",
+			Outcome: chess.WhiteWon,
+			Method:  "checkmate",
+		},
+	}
+
+	for _, tc := range games {
+		t.Run(tc.Name, func(t *testing.T) {
+
+			game, err := NewGame(tc.Variant, tc.FEN)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, move := range tc.Moves {
+				if err := game.MoveStr(move); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if game.Outcome() != tc.Outcome || game.Method() != tc.Method {
+				t.Errorf("expected %s by %q, got %s by %q", tc.Outcome, tc.Method, game.Outcome(), game.Method())
+			}
+		})
+	}
+}
+
+func TestThreeCheckFEN(t *testing.T) {
+	game, err := NewGame(ThreeCheck, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, move := range []string{"e4", "d5", "Bb5+"} {
+		if err := game.MoveStr(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fen := game.Position().String(); !strings.HasSuffix(fen, " +1+0") {
+		t.Errorf("expected one check for white, got %s", fen)
+	}
+}
+
+func TestCrazyhouse(t *testing.T) {
+	game, err := NewGame(Crazyhouse, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, move := range []string{"e4", "d5", "exd5", "Qxd5", "Nc3", "Qa5", "P@d5"} {
+		if err := game.MoveStr(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pos := game.Position()
+	if pos.Pocket(chess.White, chess.Pawn) != 0 || pos.Pocket(chess.Black, chess.Pawn) != 1 {
+		t.Errorf("expected only black to hold a pawn, got %s", pos)
+	}
+
+	moves := game.Moves()
+	if san := game.Positions()[6].SAN(moves[6]); san != "P@d5" {
+		t.Errorf("expected P@d5, got %s", san)
+	}
+
+	if err := game.MoveStr("P@e1"); err == nil {
+		t.Error("expected a drop on an occupied square to be rejected")
+	}
+
+	if err := game.MoveStr("P@h1"); err == nil {
+		t.Error("expected a pawn drop on the first rank to be rejected")
+	}
+}
+
+func TestCrazyhousePromotedPieceDemotes(t *testing.T) {
+	game, err := NewGame(Crazyhouse, "1r2k3/P7/8/8/8/8/8/4K3[] w - - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := game.MoveStr("a8=Q"); err != nil {
+		t.Fatal(err)
+	}
+
+	if fen := game.Position().String(); fen != "Q~r2k3/8/8/8/8/8/8/4K3[] b - - 0 1" {
+		t.Errorf("expected a promoted queen on a8, got %s", fen)
+	}
+
+	if err := game.MoveStr("Rxa8"); err != nil {
+		t.Fatal(err)
+	}
+
+	pos := game.Position()
+	if pos.Pocket(chess.Black, chess.Pawn) != 1 || pos.Pocket(chess.Black, chess.Queen) != 0 {
+		t.Errorf("expected the promoted queen to go back as a pawn, got %s", pos)
+	}
+}
+
+func TestUnknownVariant(t *testing.T) {
+	if _, err := NewGame("atomic", ""); err == nil {
+		t.Error("expected an unknown variant to be rejected")
+	}
+}
+
+func TestFromPGNName(t *testing.T) {
+	for _, name := range Names {
+		if found, ok := FromPGNName(PGNName(name)); !ok || found != name {
+			t.Errorf("expected %s back from its PGN name, got %s", name, found)
+		}
+	}
+
+	if found, ok := FromPGNName("king of the hill"); !ok || found != KingOfTheHill {
+		t.Errorf("expected the Variant tag to ignore case, got %s", found)
+	}
+	if _, ok := FromPGNName("Bughouse"); ok {
+		t.Error("expected an unknown Variant tag to be rejected")
+	}
+}